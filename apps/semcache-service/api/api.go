@@ -0,0 +1,26 @@
+// Package api holds the service's API definitions: the OpenAPI specification and Swagger
+// UI assets embedded here, and the gRPC service under proto.
+package api
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// OpenAPISpec is the OpenAPI specification of the HTTP API
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
+
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// SwaggerUI returns the Swagger UI assets, vendored from the swagger-ui-dist package at the
+// version in swagger-ui/VERSION. Run the swagger-ui:semcache-service task to update them.
+func SwaggerUI() fs.FS {
+	assets, err := fs.Sub(swaggerUI, "swagger-ui")
+	if err != nil {
+		panic(err)
+	}
+	return assets
+}
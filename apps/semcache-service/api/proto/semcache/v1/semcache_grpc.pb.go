@@ -0,0 +1,287 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: semcache/v1/semcache.proto
+
+package semcachev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SemcacheService_Create_FullMethodName = "/semcache.v1.SemcacheService/Create"
+	SemcacheService_Get_FullMethodName    = "/semcache.v1.SemcacheService/Get"
+	SemcacheService_Delete_FullMethodName = "/semcache.v1.SemcacheService/Delete"
+	SemcacheService_Search_FullMethodName = "/semcache.v1.SemcacheService/Search"
+	SemcacheService_Lookup_FullMethodName = "/semcache.v1.SemcacheService/Lookup"
+)
+
+// SemcacheServiceClient is the client API for SemcacheService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SemcacheService mirrors the HTTP API under /v1
+type SemcacheServiceClient interface {
+	// Create creates an entry; fails with ALREADY_EXISTS if a live entry uses the key
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CacheEntry, error)
+	// Get returns the live entry stored under a key, or NOT_FOUND
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*CacheEntry, error)
+	// Delete removes the entry stored under a key, or returns NOT_FOUND
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Search finds live entries by key and metadata substrings
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// Lookup returns the live entries whose embeddings are most similar to the query
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+}
+
+type semcacheServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSemcacheServiceClient(cc grpc.ClientConnInterface) SemcacheServiceClient {
+	return &semcacheServiceClient{cc}
+}
+
+func (c *semcacheServiceClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CacheEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CacheEntry)
+	err := c.cc.Invoke(ctx, SemcacheService_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *semcacheServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*CacheEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CacheEntry)
+	err := c.cc.Invoke(ctx, SemcacheService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *semcacheServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, SemcacheService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *semcacheServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, SemcacheService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *semcacheServiceClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupResponse)
+	err := c.cc.Invoke(ctx, SemcacheService_Lookup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SemcacheServiceServer is the server API for SemcacheService service.
+// All implementations must embed UnimplementedSemcacheServiceServer
+// for forward compatibility.
+//
+// SemcacheService mirrors the HTTP API under /v1
+type SemcacheServiceServer interface {
+	// Create creates an entry; fails with ALREADY_EXISTS if a live entry uses the key
+	Create(context.Context, *CreateRequest) (*CacheEntry, error)
+	// Get returns the live entry stored under a key, or NOT_FOUND
+	Get(context.Context, *GetRequest) (*CacheEntry, error)
+	// Delete removes the entry stored under a key, or returns NOT_FOUND
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Search finds live entries by key and metadata substrings
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// Lookup returns the live entries whose embeddings are most similar to the query
+	Lookup(context.Context, *LookupRequest) (*LookupResponse, error)
+	mustEmbedUnimplementedSemcacheServiceServer()
+}
+
+// UnimplementedSemcacheServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSemcacheServiceServer struct{}
+
+func (UnimplementedSemcacheServiceServer) Create(context.Context, *CreateRequest) (*CacheEntry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedSemcacheServiceServer) Get(context.Context, *GetRequest) (*CacheEntry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedSemcacheServiceServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedSemcacheServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSemcacheServiceServer) Lookup(context.Context, *LookupRequest) (*LookupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedSemcacheServiceServer) mustEmbedUnimplementedSemcacheServiceServer() {}
+func (UnimplementedSemcacheServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeSemcacheServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SemcacheServiceServer will
+// result in compilation errors.
+type UnsafeSemcacheServiceServer interface {
+	mustEmbedUnimplementedSemcacheServiceServer()
+}
+
+func RegisterSemcacheServiceServer(s grpc.ServiceRegistrar, srv SemcacheServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSemcacheServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SemcacheService_ServiceDesc, srv)
+}
+
+func _SemcacheService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SemcacheServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SemcacheService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SemcacheServiceServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SemcacheService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SemcacheServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SemcacheService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SemcacheServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SemcacheService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SemcacheServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SemcacheService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SemcacheServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SemcacheService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SemcacheServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SemcacheService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SemcacheServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SemcacheService_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SemcacheServiceServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SemcacheService_Lookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SemcacheServiceServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SemcacheService_ServiceDesc is the grpc.ServiceDesc for SemcacheService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SemcacheService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "semcache.v1.SemcacheService",
+	HandlerType: (*SemcacheServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _SemcacheService_Create_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _SemcacheService_Get_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _SemcacheService_Delete_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _SemcacheService_Search_Handler,
+		},
+		{
+			MethodName: "Lookup",
+			Handler:    _SemcacheService_Lookup_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "semcache/v1/semcache.proto",
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/database"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+)
+
+func runStats(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("stats", "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+
+	stats, err := g.client.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	return g.printStats(stats)
+}
+
+func runInvalidate(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("invalidate", "KEY | -all")
+	all := fs.Bool("all", false, "empty the L1 tier of every replica")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *all && len(positional) == 0:
+		if err := g.client.InvalidateAll(ctx); err != nil {
+			return err
+		}
+		return g.printResult("Invalidated all entries", map[string]bool{"all": true})
+	case !*all && len(positional) == 1:
+		key := positional[0]
+		if err := g.client.Invalidate(ctx, key); err != nil {
+			return err
+		}
+		return g.printResult("Invalidated "+key, map[string]string{"key": key})
+	default:
+		return usageError(fs, "expected one key or -all")
+	}
+}
+
+// runMigrate manages the schema of the database configured by the server's environment
+// variables, like the server's own migrate subcommand
+func runMigrate(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("migrate", "[up | down [steps] | status]")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(positional) > 0 {
+		command = positional[0]
+	}
+
+	switch command {
+	case "up", "status":
+		if len(positional) > 1 {
+			return usageError(fs, "unexpected argument %q", positional[1])
+		}
+	case "down":
+		if len(positional) > 2 {
+			return usageError(fs, "unexpected argument %q", positional[2])
+		}
+	default:
+		return usageError(fs, "unknown migrate command %q", command)
+	}
+
+	steps := 1
+	if command == "down" && len(positional) > 1 {
+		steps, err = strconv.Atoi(positional[1])
+		if err != nil || steps < 1 {
+			return usageError(fs, "invalid steps %q", positional[1])
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	logger.InitLogger(cfg.Debug)
+	defer logger.Sync()
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		return g.printResult(fmt.Sprintf("Applied %d migrations", applied), map[string]int{"applied": applied})
+	case "down":
+		reverted, err := db.MigrateDown(ctx, steps)
+		if err != nil {
+			return err
+		}
+		return g.printResult(fmt.Sprintf("Reverted %d migrations", reverted), map[string]int{"reverted": reverted})
+	default:
+		statuses, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return g.printMigrations(statuses)
+	}
+}
+
+// migrationJSON is a migration status in JSON output
+type migrationJSON struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// printMigrations writes the status of every known migration
+func (g *globals) printMigrations(statuses []database.MigrationStatus) error {
+	if g.output == "json" {
+		migrations := make([]migrationJSON, 0, len(statuses))
+		for _, s := range statuses {
+			migrations = append(migrations, migrationJSON{Version: s.Version, Name: s.Name, AppliedAt: s.AppliedAt})
+		}
+		return g.printJSON(migrations)
+	}
+
+	w := tabwriter.NewWriter(g.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, formatTime(s.AppliedAt))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nextinterfaces/semcache-service/pkg/client"
+)
+
+func runGet(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("get", "KEY [-raw]")
+	raw := fs.Bool("raw", false, "write the raw value to stdout")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected one key")
+	}
+	key := positional[0]
+
+	if *raw {
+		value, err := g.client.GetValue(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer value.Close()
+		_, err = io.Copy(g.out, value)
+		return err
+	}
+
+	entry, err := g.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return g.printEntry(entry)
+}
+
+func runPut(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("put", "KEY [VALUE | -] [flags]")
+	file := fs.String("file", "", "read the value from this file")
+	ttl := fs.Duration("ttl", 0, "time to live, such as 1h; 0 for no expiry")
+	metadata := fs.String("metadata", "", "metadata to store with the entry")
+	contentType := fs.String("content-type", "", "content type of the value (default application/octet-stream for files and stdin)")
+	embedding := fs.String("embedding", "", "embedding as comma-separated numbers, a JSON array, or @file")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return usageError(fs, "expected a key and at most one value")
+	}
+	key := positional[0]
+
+	var value io.Reader
+	switch {
+	case len(positional) == 2 && *file != "":
+		return usageError(fs, "give the value as an argument or with -file, not both")
+	case len(positional) == 2 && positional[1] != "-":
+		value = strings.NewReader(positional[1])
+		if *contentType == "" {
+			*contentType = "text/plain; charset=utf-8"
+		}
+	case *file != "":
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		value = f
+	default:
+		value = os.Stdin
+	}
+
+	// Entries with an embedding go through the JSON API; others are streamed
+	if *embedding != "" {
+		vector, err := parseEmbedding(*embedding)
+		if err != nil {
+			return usageError(fs, "invalid -embedding: %v", err)
+		}
+		data, err := io.ReadAll(value)
+		if err != nil {
+			return err
+		}
+		entry, err := g.client.Create(ctx, client.CreateRequest{
+			Key:         key,
+			Value:       data,
+			ContentType: *contentType,
+			Metadata:    *metadata,
+			TTL:         *ttl,
+			Embedding:   vector,
+		})
+		if err != nil {
+			return err
+		}
+		return g.printResult("Created "+entry.Key, entry)
+	}
+
+	err = g.client.PutValue(ctx, key, value, &client.PutValueOptions{
+		ContentType: *contentType,
+		Metadata:    *metadata,
+		TTL:         *ttl,
+	})
+	if err != nil {
+		return err
+	}
+	return g.printResult("Created "+key, map[string]string{"key": key})
+}
+
+func runDelete(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("delete", "KEY... [-ignore-missing]")
+	ignoreMissing := fs.Bool("ignore-missing", false, "do not fail for keys that do not exist")
+	keys, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return usageError(fs, "expected at least one key")
+	}
+
+	var deleted, missing []string
+	for _, key := range keys {
+		err := g.client.Delete(ctx, key)
+		if errors.Is(err, client.ErrNotFound) {
+			missing = append(missing, key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete %q: %w", key, err)
+		}
+		deleted = append(deleted, key)
+	}
+
+	if g.output == "json" {
+		if deleted == nil {
+			deleted = []string{}
+		}
+		if missing == nil {
+			missing = []string{}
+		}
+		if err := g.printJSON(map[string][]string{"deleted": deleted, "missing": missing}); err != nil {
+			return err
+		}
+	} else {
+		for _, key := range deleted {
+			fmt.Fprintf(g.out, "Deleted %s\n", key)
+		}
+		for _, key := range missing {
+			fmt.Fprintf(g.out, "Not found %s\n", key)
+		}
+	}
+
+	if len(missing) > 0 && !*ignoreMissing {
+		return fmt.Errorf("%d of %d keys not found", len(missing), len(keys))
+	}
+	return nil
+}
+
+func runSearch(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("search", "[-key S] [-metadata S] [-limit N]")
+	key := fs.String("key", "", "key substring (case-insensitive)")
+	metadata := fs.String("metadata", "", "metadata substring (case-insensitive)")
+	limit := fs.Int("limit", 0, "maximum number of entries (service default when 0)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+
+	entries, err := g.client.Search(ctx, client.SearchRequest{Key: *key, Metadata: *metadata, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return g.printEntries(entries)
+}
+
+func runLookup(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("lookup", "-embedding V [-threshold F] [-limit N]")
+	embedding := fs.String("embedding", "", "embedding as comma-separated numbers, a JSON array, or @file (required)")
+	threshold := fs.Float64("threshold", 0, "minimum cosine similarity")
+	limit := fs.Int("limit", 0, "maximum number of results (service default when 0)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+	if *embedding == "" {
+		return usageError(fs, "-embedding is required")
+	}
+
+	vector, err := parseEmbedding(*embedding)
+	if err != nil {
+		return usageError(fs, "invalid -embedding: %v", err)
+	}
+
+	results, err := g.client.Lookup(ctx, client.LookupRequest{Embedding: vector, Threshold: *threshold, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return g.printLookupResults(results)
+}
+
+// parseEmbedding parses an embedding given as comma-separated numbers or a JSON array,
+// either directly or in a file named after '@'
+func parseEmbedding(s string) ([]float32, error) {
+	if name, ok := strings.CutPrefix(s, "@"); ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		s = string(bytes.TrimSpace(data))
+	}
+
+	var vector []float32
+	if strings.HasPrefix(s, "[") {
+		if err := json.Unmarshal([]byte(s), &vector); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, field := range strings.Split(s, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+			if err != nil {
+				return nil, err
+			}
+			vector = append(vector, float32(f))
+		}
+	}
+
+	if len(vector) == 0 {
+		return nil, errors.New("embedding is empty")
+	}
+	return vector, nil
+}
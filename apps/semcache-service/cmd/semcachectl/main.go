@@ -0,0 +1,178 @@
+// Command semcachectl is an operator tool for semcache-service. It talks to the HTTP API,
+// except for migrate, which connects to the database configured by the same environment
+// variables as the server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/pkg/client"
+)
+
+const usage = `usage: semcachectl [flags] <command> [args]
+
+Commands:
+  get KEY [-raw]                       show an entry, or write its raw value with -raw
+  put KEY [VALUE | -] [flags]          create an entry from VALUE, -file or stdin
+  delete KEY...                        delete entries
+  search [-key S] [-metadata S]        search entries by key and metadata substrings
+  lookup -embedding V [-threshold F]   semantic lookup, with scores
+  export [-prefix P] [-file F]         write live entries as NDJSON
+  import [-file F] [-overwrite]        create entries from NDJSON written by export
+  stats                                show entry counts and sizes
+  invalidate KEY | -all                evict entries from every replica's L1 tier
+  migrate [up | down [steps] | status] manage the database schema
+
+Flags:
+`
+
+// globals are the flags shared by all commands
+type globals struct {
+	url     string
+	apiKey  string
+	output  string
+	timeout time.Duration
+
+	client *client.Client
+	out    io.Writer
+}
+
+// commands maps command names to their implementations
+var commands = map[string]func(ctx context.Context, g *globals, args []string) error{
+	"get":        runGet,
+	"put":        runPut,
+	"delete":     runDelete,
+	"search":     runSearch,
+	"lookup":     runLookup,
+	"export":     runExport,
+	"import":     runImport,
+	"stats":      runStats,
+	"invalidate": runInvalidate,
+	"migrate":    runMigrate,
+}
+
+// errUsage reports a command line error, already described to the user
+var errUsage = errors.New("usage error")
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	g := &globals{out: os.Stdout}
+
+	fs := flag.NewFlagSet("semcachectl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&g.url, "url", getEnv("SEMCACHE_URL", "http://localhost:8080"), "service URL (env SEMCACHE_URL)")
+	fs.StringVar(&g.apiKey, "api-key", os.Getenv("SEMCACHE_API_KEY"), "API key or JWT, if the service requires one (env SEMCACHE_API_KEY)")
+	g.output = "table"
+	fs.Func("o", "output format: table or json (default table)", g.setOutput)
+	fs.DurationVar(&g.timeout, "timeout", 30*time.Second, "timeout for the whole command, 0 for none")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "semcachectl: unknown command %q\n\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	var err error
+	g.client, err = client.New(g.url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "semcachectl: %v\n", err)
+		return 2
+	}
+	if g.apiKey != "" {
+		g.client.UseAPIKey(g.apiKey)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	if err := cmd(ctx, g, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "semcachectl %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// parseFlags parses args with fs, allowing flags after positional arguments, and returns
+// the positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newFlagSet creates the flag set of a command, reporting errors to stderr. The output
+// format may also be given after the command.
+func (g *globals) newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: semcachectl %s %s\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	fs.Func("o", "output format: table or json", g.setOutput)
+	return fs
+}
+
+// setOutput sets the output format
+func (g *globals) setOutput(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("must be table or json")
+	}
+	g.output = format
+	return nil
+}
+
+// usageError prints a command line error with the command's usage
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) error {
+	fmt.Fprintf(fs.Output(), "semcachectl %s: %s\n", fs.Name(), fmt.Sprintf(format, args...))
+	fs.Usage()
+	return errUsage
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nextinterfaces/semcache-service/pkg/client"
+)
+
+// previewLength is how many characters of a value tables show
+const previewLength = 40
+
+// printJSON writes v as indented JSON
+func (g *globals) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(g.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printEntry writes one entry, with its whole value in table format if it is text
+func (g *globals) printEntry(entry *client.Entry) error {
+	if g.output == "json" {
+		return g.printJSON(entry)
+	}
+
+	w := tabwriter.NewWriter(g.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Key:\t%s\n", entry.Key)
+	fmt.Fprintf(w, "ID:\t%d\n", entry.ID)
+	fmt.Fprintf(w, "Content-Type:\t%s\n", orDash(entry.ContentType))
+	fmt.Fprintf(w, "Metadata:\t%s\n", orDash(entry.Metadata))
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(&entry.CreatedAt))
+	fmt.Fprintf(w, "Expires:\t%s\n", formatTime(entry.ExpiresAt))
+	fmt.Fprintf(w, "Embedding:\t%s\n", formatEmbedding(entry.Embedding))
+	fmt.Fprintf(w, "Size:\t%d bytes\n", len(entry.Value))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if isPrintable(entry.Value) {
+		_, err := fmt.Fprintf(g.out, "\n%s\n", entry.Value)
+		return err
+	}
+	_, err := fmt.Fprintf(g.out, "\n<binary value, use get -raw to read it>\n")
+	return err
+}
+
+// printEntries writes entries, one table row each
+func (g *globals) printEntries(entries []*client.Entry) error {
+	if g.output == "json" {
+		if entries == nil {
+			entries = []*client.Entry{}
+		}
+		return g.printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(g.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSIZE\tCONTENT-TYPE\tMETADATA\tCREATED\tEXPIRES\tVALUE")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", entry.Key, len(entry.Value), orDash(entry.ContentType),
+			orDash(preview(entry.Metadata)), formatTime(&entry.CreatedAt), formatTime(entry.ExpiresAt), valuePreview(entry.Value))
+	}
+	return w.Flush()
+}
+
+// printLookupResults writes lookup results with their scores, best first
+func (g *globals) printLookupResults(results []*client.LookupResult) error {
+	if g.output == "json" {
+		if results == nil {
+			results = []*client.LookupResult{}
+		}
+		return g.printJSON(results)
+	}
+
+	w := tabwriter.NewWriter(g.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCORE\tKEY\tSIZE\tMETADATA\tVALUE")
+	for _, result := range results {
+		fmt.Fprintf(w, "%.4f\t%s\t%d\t%s\t%s\n", result.Score, result.Key, len(result.Value),
+			orDash(preview(result.Metadata)), valuePreview(result.Value))
+	}
+	return w.Flush()
+}
+
+// printStats writes cache statistics
+func (g *globals) printStats(stats *client.Stats) error {
+	if g.output == "json" {
+		return g.printJSON(stats)
+	}
+
+	w := tabwriter.NewWriter(g.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Entries:\t%d\n", stats.Entries)
+	fmt.Fprintf(w, "Expired (not yet removed):\t%d\n", stats.Expired)
+	fmt.Fprintf(w, "Value bytes:\t%d\n", stats.ValueBytes)
+	if stats.L1 != nil {
+		fmt.Fprintf(w, "L1 entries:\t%d\n", stats.L1.Entries)
+		fmt.Fprintf(w, "L1 bytes:\t%d\n", stats.L1.Bytes)
+	}
+	if stats.CircuitBreaker != "" {
+		fmt.Fprintf(w, "Circuit breaker:\t%s\n", stats.CircuitBreaker)
+	}
+	return w.Flush()
+}
+
+// printResult writes the outcome of a command that returns no data: message in table
+// format, or result as JSON
+func (g *globals) printResult(message string, result interface{}) error {
+	if g.output == "json" {
+		return g.printJSON(result)
+	}
+	_, err := fmt.Fprintln(g.out, message)
+	return err
+}
+
+// valuePreview shortens a value for a table cell
+func valuePreview(value []byte) string {
+	if !isPrintable(value) {
+		return fmt.Sprintf("<%d bytes>", len(value))
+	}
+	return preview(string(value))
+}
+
+// preview shortens s to one line of at most previewLength characters
+func preview(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= previewLength {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:previewLength-3]) + "..."
+}
+
+// isPrintable reports whether value is text that can be written to a terminal
+func isPrintable(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+	for _, r := range string(value) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func formatEmbedding(embedding []float32) string {
+	if len(embedding) == 0 {
+		return "-"
+	}
+	return strconv.Itoa(len(embedding)) + " dimensions"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/pkg/client"
+)
+
+// importBatchSize is how many entries import reads before creating them
+const importBatchSize = 100
+
+// runExport writes entries as NDJSON whatever the output format, so that import can read
+// them back
+func runExport(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("export", "[-prefix P] [-file F]")
+	prefix := fs.String("prefix", "", "only export keys starting with this prefix")
+	file := fs.String("file", "", "write to this file instead of stdout")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+
+	out := g.out
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	encoder := json.NewEncoder(w)
+	exported := 0
+	err = g.client.Export(ctx, *prefix, func(entry *client.Entry) error {
+		exported++
+		return encoder.Encode(entry)
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d entries\n", exported)
+	return nil
+}
+
+// importStats counts the outcomes of an import
+type importStats struct {
+	Imported int `json:"imported"`
+	Expired  int `json:"expired"`
+	Existing int `json:"existing"`
+	Failed   int `json:"failed"`
+}
+
+// runImport creates entries from NDJSON as written by export. Entries keep their
+// remaining time to live; those that have expired since are skipped.
+func runImport(ctx context.Context, g *globals, args []string) error {
+	fs := g.newFlagSet("import", "[-file F] [-overwrite] [-concurrency N]")
+	file := fs.String("file", "", "read from this file instead of stdin")
+	overwrite := fs.Bool("overwrite", false, "replace entries that already exist instead of skipping them")
+	concurrency := fs.Int("concurrency", 8, "number of entries created at once")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError(fs, "unexpected argument %q", positional[0])
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	g.client.UseBatchConcurrency(*concurrency)
+
+	stats := &importStats{}
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	batch := make([]client.CreateRequest, 0, importBatchSize)
+	for {
+		var entry client.Entry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read entry %d: %w", stats.Imported+stats.Expired+stats.Existing+stats.Failed+len(batch)+1, err)
+		}
+
+		req, ok := importRequest(&entry, time.Now())
+		if !ok {
+			stats.Expired++
+			continue
+		}
+		batch = append(batch, req)
+
+		if len(batch) == importBatchSize {
+			g.importBatch(ctx, batch, *overwrite, stats)
+			batch = batch[:0]
+		}
+	}
+	g.importBatch(ctx, batch, *overwrite, stats)
+
+	if g.output == "json" {
+		if err := g.printJSON(stats); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(g.out, "Imported %d entries (%d expired, %d already existed, %d failed)\n",
+			stats.Imported, stats.Expired, stats.Existing, stats.Failed)
+	}
+
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d entries failed to import", stats.Failed)
+	}
+	return nil
+}
+
+// importBatch creates a batch of entries, replacing existing ones if overwrite is set
+func (g *globals) importBatch(ctx context.Context, batch []client.CreateRequest, overwrite bool, stats *importStats) {
+	for i, result := range g.client.Batch(ctx, batch) {
+		err := result.Err
+		if errors.Is(err, client.ErrKeyExists) && overwrite {
+			err = g.client.Delete(ctx, batch[i].Key)
+			if err == nil || errors.Is(err, client.ErrNotFound) {
+				_, err = g.client.Create(ctx, batch[i])
+			}
+		}
+
+		switch {
+		case err == nil:
+			stats.Imported++
+		case errors.Is(err, client.ErrKeyExists):
+			stats.Existing++
+		default:
+			stats.Failed++
+			fmt.Fprintf(os.Stderr, "Failed to import %q: %v\n", batch[i].Key, err)
+		}
+	}
+}
+
+// importRequest converts an exported entry into a create request, or reports false if it
+// has expired by now
+func importRequest(entry *client.Entry, now time.Time) (client.CreateRequest, bool) {
+	req := client.CreateRequest{
+		Key:         entry.Key,
+		Value:       entry.Value,
+		ContentType: entry.ContentType,
+		Metadata:    entry.Metadata,
+		Embedding:   entry.Embedding,
+	}
+	if entry.ExpiresAt != nil {
+		remaining := entry.ExpiresAt.Sub(now)
+		if remaining < time.Second {
+			return req, false
+		}
+		// Round up so entries do not lose their last partial second
+		req.TTL = (remaining + time.Second - 1).Truncate(time.Second)
+	}
+	return req, true
+}
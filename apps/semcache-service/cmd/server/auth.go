@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+)
+
+// startKeySet loads the JWKS tokens are verified with. If it cannot be loaded, tokens are
+// rejected with 503 until it is.
+func startKeySet(cfg *config.AuthConfig) *auth.KeySet {
+	keySet := auth.NewKeySet(cfg.JWKSURL, cfg.JWKSFile, cfg.JWKSRefresh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := keySet.Reload(ctx); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to load JWKS: %v", err))
+	} else {
+		logger.Logger.Info(fmt.Sprintf("Loaded %d JWKS keys", keySet.Len()))
+	}
+
+	return keySet
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/handlers"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+// authServer serves the API over a memory store with API keys required, holding keys
+// with each scope and a read/write key restricted to the "team" namespace
+type authServer struct {
+	e     *echo.Echo
+	store *models.MemoryStore
+}
+
+// Test API keys
+const (
+	testReadKey  = "sck_read"
+	testWriteKey = "sck_write"
+	testAdminKey = "sck_admin"
+	testTeamKey  = "sck_team"
+)
+
+func newAuthServer(t *testing.T) *authServer {
+	t.Helper()
+
+	store := models.NewMemoryStore(0, 0)
+	t.Cleanup(func() { store.Close() })
+
+	for secret, key := range map[string]*models.APIKey{
+		testReadKey:  {Name: "read", Scopes: []string{models.ScopeRead}},
+		testWriteKey: {Name: "write", Scopes: []string{models.ScopeWrite}},
+		testAdminKey: {Name: "admin", Scopes: []string{models.ScopeAdmin}},
+		testTeamKey:  {Name: "team", Scopes: []string{models.ScopeRead, models.ScopeWrite}, Namespaces: []string{"team"}},
+	} {
+		key.Hash = auth.HashKey(secret)
+		if err := store.CreateAPIKey(context.Background(), key); err != nil {
+			t.Fatalf("failed to create API key: %v", err)
+		}
+	}
+
+	h := handlers.New(store, "")
+	h.UseAPIKeys(store, nil)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.Validator = validation.New()
+	registerRoutes(e, h, auth.New(store, &config.AuthConfig{Enabled: true, CacheTTL: time.Minute}), false, false)
+
+	return &authServer{e: e, store: store}
+}
+
+// do sends a request authenticated with apiKey, with body encoded as JSON if set
+func (s *authServer) do(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, path, strings.NewReader(string(data)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	} else {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	if apiKey != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+apiKey)
+	}
+
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// create stores an entry directly in the store
+func (s *authServer) create(t *testing.T, key string, embedding []float32) {
+	t.Helper()
+	if _, err := s.store.Create(context.Background(), models.CreateRequest{Key: key, Value: "v", Embedding: embedding}); err != nil {
+		t.Fatalf("failed to create %s: %v", key, err)
+	}
+}
+
+// TestAuthScopes checks that each route needs a key, and a key with its scope
+func TestAuthScopes(t *testing.T) {
+	s := newAuthServer(t)
+	s.create(t, "a", nil)
+
+	lookup := map[string]interface{}{"embedding": []float32{1, 0}}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		apiKey string
+		body   interface{}
+		want   int
+	}{
+		{"no key", http.MethodGet, "/v1/entries/a", "", nil, http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "/v1/entries/a", "sck_unknown", nil, http.StatusUnauthorized},
+		{"read get", http.MethodGet, "/v1/entries/a", testReadKey, nil, http.StatusOK},
+		{"write get", http.MethodGet, "/v1/entries/a", testWriteKey, nil, http.StatusForbidden},
+		{"read create", http.MethodPost, "/v1/create", testReadKey, map[string]string{"key": "b", "value": "v"}, http.StatusForbidden},
+		{"write create", http.MethodPost, "/v1/create", testWriteKey, map[string]string{"key": "b", "value": "v"}, http.StatusCreated},
+		{"write search", http.MethodPost, "/v1/search", testWriteKey, map[string]string{}, http.StatusForbidden},
+		{"write lookup", http.MethodPost, "/v1/lookup", testWriteKey, lookup, http.StatusForbidden},
+		{"read delete", http.MethodDelete, "/v1/entries/a", testReadKey, nil, http.StatusForbidden},
+		{"read export", http.MethodGet, "/v1/admin/export", testReadKey, nil, http.StatusForbidden},
+		{"write export", http.MethodGet, "/v1/admin/export", testWriteKey, nil, http.StatusForbidden},
+		{"read keys", http.MethodGet, "/v1/admin/keys", testReadKey, nil, http.StatusForbidden},
+		{"admin get", http.MethodGet, "/v1/entries/a", testAdminKey, nil, http.StatusOK},
+		{"admin export", http.MethodGet, "/v1/admin/export", testAdminKey, nil, http.StatusOK},
+		{"write delete", http.MethodDelete, "/v1/entries/a", testWriteKey, nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, tt.apiKey, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+// TestAuthNamespaces checks that a key restricted to namespaces cannot reach entries in
+// others, and that searches and lookups fill their limit from its namespaces
+func TestAuthNamespaces(t *testing.T) {
+	s := newAuthServer(t)
+
+	// The team entry is the oldest and least similar, so without filtering before the
+	// limit it would be crowded out by the others
+	s.create(t, "team:mine", []float32{1, 1})
+	for _, key := range []string{"other:1", "other:2", "other:3", "unspaced"} {
+		time.Sleep(time.Millisecond)
+		s.create(t, key, []float32{1, 0})
+	}
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"create own", http.MethodPost, "/v1/create", map[string]string{"key": "team:new", "value": "v"}, http.StatusCreated},
+		{"create other", http.MethodPost, "/v1/create", map[string]string{"key": "other:new", "value": "v"}, http.StatusForbidden},
+		{"create unspaced", http.MethodPost, "/v1/create", map[string]string{"key": "new", "value": "v"}, http.StatusForbidden},
+		{"get own", http.MethodGet, "/v1/entries/team:mine", nil, http.StatusOK},
+		{"get other", http.MethodGet, "/v1/entries/other:1", nil, http.StatusForbidden},
+		{"get unspaced", http.MethodGet, "/v1/entries/unspaced", nil, http.StatusForbidden},
+		{"delete other", http.MethodDelete, "/v1/entries/other:1", nil, http.StatusForbidden},
+		{"delete own", http.MethodDelete, "/v1/entries/team:new", nil, http.StatusNoContent},
+		{"export", http.MethodGet, "/v1/admin/export", nil, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, testTeamKey, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	t.Run("search", func(t *testing.T) {
+		rec := s.do(http.MethodPost, "/v1/search", testTeamKey, map[string]interface{}{"limit": 1})
+		var entries []models.CacheEntry
+		if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+		if len(entries) != 1 || entries[0].Key != "team:mine" {
+			t.Errorf("got %+v, want team:mine", entries)
+		}
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		rec := s.do(http.MethodPost, "/v1/lookup", testTeamKey, map[string]interface{}{"embedding": []float32{1, 0}, "limit": 1})
+		var results []models.LookupResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+		if len(results) != 1 || results[0].Key != "team:mine" {
+			t.Errorf("got %+v, want team:mine", results)
+		}
+	})
+
+	// Export needs the admin scope, which cannot be restricted, so it covers every namespace
+	t.Run("admin export", func(t *testing.T) {
+		rec := s.do(http.MethodGet, "/v1/admin/export", testAdminKey, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+		n := 0
+		for scanner := bufio.NewScanner(rec.Body); scanner.Scan(); {
+			n++
+		}
+		if n != 5 {
+			t.Errorf("exported %d entries, want 5", n)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// expirySweepBatch is how many expired entries are deleted per statement
+const expirySweepBatch = 500
+
+// expirySweeper is a storage backend that keeps expired entries until they are swept
+type expirySweeper interface {
+	SweepExpired(ctx context.Context, batchSize int) (int, error)
+}
+
+// startExpirySweep deletes expired entries every interval until the returned function is
+// called
+func startExpirySweep(sweeper expirySweeper, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				swept, err := sweeper.SweepExpired(ctx, expirySweepBatch)
+				cancel()
+				if err != nil && !errors.Is(err, models.ErrUnavailable) {
+					logger.Logger.Warn(fmt.Sprintf("Failed to sweep expired entries: %v", err))
+				} else if swept > 0 {
+					logger.Logger.Debug(fmt.Sprintf("Swept %d expired entries", swept))
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
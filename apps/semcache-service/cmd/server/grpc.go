@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	semcachev1 "github.com/nextinterfaces/semcache-service/api/proto/semcache/v1"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/grpcapi"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	smmetrics "github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+)
+
+// startGRPC serves the gRPC API on cfg.Server.GRPCPort, with the same tracing, metrics
+// and, with authn and limiter set, authentication and rate limits as the HTTP API, and
+// returns the server so it can be stopped on shutdown
+func startGRPC(cfg *config.Config, store models.Store, authn *auth.Authenticator, limiter *ratelimit.Limiter) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GRPCPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on gRPC port: %w", err)
+	}
+
+	unary := []grpc.UnaryServerInterceptor{smmetrics.UnaryServerInterceptor()}
+	var stream []grpc.StreamServerInterceptor
+	if authn != nil {
+		unary = append(unary, authn.UnaryServerInterceptor(grpcapi.Scopes))
+		stream = append(stream, authn.StreamServerInterceptor(grpcapi.Scopes))
+	}
+
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+		// Leave room for the rest of the request, such as the embedding
+		grpc.MaxRecvMsgSize(cfg.Storage.LargestMaxValueBytes()+1<<20),
+	)
+
+	api := grpcapi.New(store)
+	api.UseValueLimits(&cfg.Storage)
+	if limiter != nil {
+		api.UseRateLimits(limiter)
+	}
+	semcachev1.RegisterSemcacheServiceServer(srv, api)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logger.Logger.Error(fmt.Sprintf("gRPC server error: %v", err))
+		}
+	}()
+
+	return srv, nil
+}
@@ -13,21 +13,41 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/breaker"
 	"github.com/nextinterfaces/semcache-service/internal/config"
 	smmetrics "github.com/nextinterfaces/semcache-service/internal/metrics"
 	"github.com/nextinterfaces/semcache-service/internal/database"
 	"github.com/nextinterfaces/semcache-service/internal/handlers"
 	"github.com/nextinterfaces/semcache-service/internal/logger"
 	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
 	"github.com/nextinterfaces/semcache-service/internal/util"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+	"github.com/nextinterfaces/semcache-service/internal/watch"
+	"github.com/nextinterfaces/semcache-service/internal/webhooks"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration error: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		if err := runReencrypt(os.Args[2:]); err != nil {
+			log.Fatalf("Re-encryption error: %v", err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatalf("Application error: %v", err)
 	}
@@ -66,30 +86,269 @@ func run() error {
 		logger.Logger.Warn(fmt.Sprintf("Failed to initialize Prometheus metrics: %v", err))
 	}
 
-	// Connect to database
-	db, err := database.New(&cfg.Database)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+	// Create storage backend
+	var store models.Store
+	var breakerState func() string
+	var publishInvalidation func(ctx context.Context, key string) error
+	var backend eventBackend
+	var sweeper expirySweeper
+	var partitions *database.PartitionManager
+	switch cfg.Storage.Backend {
+	case "memory":
+		memStore := models.NewMemoryStore(cfg.Storage.MemoryMaxEntries, cfg.Storage.MemorySweepInterval)
+		defer memStore.Close()
+		store = memStore
+		backend = memStore
+		logger.Logger.Info(fmt.Sprintf("Using in-memory storage (max entries: %d)", cfg.Storage.MemoryMaxEntries))
+	case "sqlite":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		sqliteStore, err := models.NewSQLiteStore(ctx, cfg.Storage.SQLitePath)
+		if err != nil {
+			return fmt.Errorf("failed to open sqlite store: %w", err)
+		}
+		defer sqliteStore.Close()
+		store = sqliteStore
+		backend = sqliteStore
+		sweeper = sqliteStore
+		logger.Logger.Info(fmt.Sprintf("Using SQLite storage: %s", cfg.Storage.SQLitePath))
+	default:
+		// Open database pools; connecting is retried below
+		db, err := database.Open(&cfg.Database)
+		if err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		defer db.Close()
+
+		// Create repositories
+		cacheRepo := models.NewCacheRepository(db.DB)
+		cacheRepo.UseAvailability(db.Ready)
+		cacheRepo.UseCompression(cfg.Storage.CompressionThreshold)
+		cacheRepo.UseChunking(cfg.Storage.ValueChunkSize)
+		if cfg.Storage.EncryptionEnabled() {
+			keyring, err := loadKeyring(&cfg.Storage)
+			if err != nil {
+				return err
+			}
+			cacheRepo.UseEncryption(keyring, cfg.Storage.EncryptMetadata)
+			logger.Logger.Info(fmt.Sprintf("Encrypting values with master key %q", keyring.PrimaryID()))
+		}
+		if len(cfg.Database.ReadReplicas) > 0 {
+			cacheRepo.UseReader(db.Reader)
+			logger.Logger.Info(fmt.Sprintf("Routing reads to %d read replicas", len(cfg.Database.ReadReplicas)))
+		}
+
+		// Keep semcache partitioned by created_at and drop partitions past retention
+		if cfg.Database.Partitioning != "" {
+			partitions = database.NewPartitionManager(db, &cfg.Database)
+			defer partitions.Close()
+			cacheRepo.UseKeyLocks()
+			logger.Logger.Info(fmt.Sprintf("Partitioning enabled (%s, retention: %s)", cfg.Database.Partitioning, cfg.Database.PartitionRetention))
+		}
+
+		// Runs once the database is reachable, at startup or after leaving degraded mode
+		prepare := func(ctx context.Context) error {
+			// Apply pending schema migrations; waits for any other pod already migrating
+			if cfg.Database.AutoMigrate {
+				applied, err := db.MigrateUp(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to migrate schema: %w", err)
+				}
+				logger.Logger.Info(fmt.Sprintf("Database schema up to date (%d migrations applied)", applied))
+			}
+
+			if partitions != nil {
+				if err := partitions.Start(ctx, time.Hour); err != nil {
+					return fmt.Errorf("failed to set up partitions: %w", err)
+				}
+			}
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		if err := db.Start(ctx, prepare); err != nil {
+			if !cfg.Database.DegradedMode {
+				return fmt.Errorf("failed to connect to database: %w", err)
+			}
+			logger.Logger.Warn(fmt.Sprintf("Starting in degraded mode, database unavailable: %v", err))
+		}
+
+		store = cacheRepo
+		backend = cacheRepo
+		sweeper = cacheRepo
+		publishInvalidation = cacheRepo.PublishInvalidation
+
+		// Fail fast while the database is slow or failing
+		if cfg.Database.BreakerEnabled {
+			cb := breaker.New(cfg.Database.BreakerThreshold, cfg.Database.BreakerOpenTimeout,
+				func(from, to breaker.State) {
+					logger.Logger.Warn(fmt.Sprintf("Database circuit breaker %s -> %s", from, to))
+					smmetrics.RecordBreakerTransition(context.Background(), from.String(), to.String())
+				},
+			)
+			if err := smmetrics.RegisterBreakerState(func() int64 { return int64(cb.State()) }); err != nil {
+				logger.Logger.Warn(fmt.Sprintf("Failed to register circuit breaker metrics: %v", err))
+			}
+			guarded := models.NewGuardedStore(store, cb, cfg.Database.BreakerCallTimeout)
+			if cfg.Database.BreakerMissOnOpen {
+				guarded.UseMissOnOpen()
+			}
+			breakerState = guarded.BreakerState
+			store = guarded
+		}
 	}
-	defer db.Close()
 
-	// Initialize database schema
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Put the in-process L1 tier in front of persistent backends
+	var tiered *models.TieredStore
+	if cfg.Storage.L1Enabled && cfg.Storage.Backend != "memory" {
+		tiered = models.NewTieredStore(store, int64(cfg.Storage.L1MaxBytes), cfg.Storage.L1TTL, cfg.Storage.L1LookupTTL)
+		if err := smmetrics.RegisterL1Usage(tiered.Usage); err != nil {
+			logger.Logger.Warn(fmt.Sprintf("Failed to register L1 metrics: %v", err))
+		}
+		store = tiered
+		logger.Logger.Info(fmt.Sprintf("L1 cache enabled (max bytes: %d, ttl: %s)", cfg.Storage.L1MaxBytes, cfg.Storage.L1TTL))
+
+		// Other replicas publish their writes; evict what they changed from our L1
+		if cfg.Storage.Backend == "postgres" {
+			listener := database.ListenChanges(&cfg.Database,
+				func(event models.ChangeEvent) {
+					if event.Op == models.ChangeFlush {
+						tiered.Flush()
+					} else {
+						tiered.Invalidate(event.Key)
+					}
+					smmetrics.RecordInvalidation(context.Background(), event.Op, time.Since(event.Time))
+				},
+				tiered.Flush,
+			)
+			defer listener.Close()
+		}
+	}
+
+	// Notify registered webhooks and watchers of entry events
+	var publishers []func(models.EntryEvent)
+	var dispatcher *webhooks.Dispatcher
+	if cfg.Webhooks.Enabled {
+		dispatcher = startWebhooks(&cfg.Webhooks, backend)
+		defer dispatcher.Close()
+		publishers = append(publishers, dispatcher.Publish)
+		logger.Logger.Info(fmt.Sprintf("Webhooks enabled (max attempts: %d, max pending: %d)", cfg.Webhooks.MaxAttempts, cfg.Webhooks.MaxPending))
+	}
+	var hub *watch.Hub
+	if cfg.Watch.Enabled {
+		hub = startWatch(&cfg.Watch, backend)
+		defer hub.Close()
+		publishers = append(publishers, hub.Publish)
+		logger.Logger.Info(fmt.Sprintf("Change feed enabled (retention: %s)", cfg.Watch.Retention))
+	}
+	var publish func(models.EntryEvent)
+	if len(publishers) > 0 {
+		publish = func(event models.EntryEvent) {
+			for _, p := range publishers {
+				p(event)
+			}
+		}
+		backend.UseEvents(publish)
+		store = models.NewEventStore(store, publish)
+	}
 
-	if err := db.InitSchema(ctx); err != nil {
-		return fmt.Errorf("failed to initialize schema: %w", err)
+	// Entries in dropped partitions are removed without going through the store, so flush
+	// every replica's L1 and report them as one evicted event without a key
+	if partitions != nil {
+		partitions.UseDropped(func(ctx context.Context) {
+			if tiered != nil {
+				tiered.Flush()
+			}
+			if err := publishInvalidation(ctx, ""); err != nil {
+				logger.Logger.Warn(fmt.Sprintf("Failed to flush L1 after dropping partitions: %v", err))
+			}
+			if publish != nil {
+				publish(models.EntryEvent{Type: models.EventEvicted, Time: time.Now().UTC()})
+			}
+		})
 	}
 
-	// Create repositories
-	cacheRepo := models.NewCacheRepository(db.DB)
+	// Delete expired entries from persistent backends, reporting them as expired events
+	if sweeper != nil && cfg.Storage.ExpirySweepInterval > 0 {
+		stopSweep := startExpirySweep(sweeper, cfg.Storage.ExpirySweepInterval)
+		defer stopSweep()
+	}
 
 	// Create handlers
-	h := handlers.New(cacheRepo, cfg.Server.CommitSHA)
+	h := handlers.New(store, cfg.Server.CommitSHA)
+	h.UseValueLimits(&cfg.Storage)
+	if breakerState != nil {
+		h.UseBreakerState(breakerState)
+	}
+	if tiered != nil {
+		h.UseL1Usage(tiered.Usage)
+		h.UseInvalidation(func(ctx context.Context, key string) error {
+			if key == "" {
+				tiered.Flush()
+			} else {
+				tiered.Invalidate(key)
+			}
+			if publishInvalidation != nil {
+				return publishInvalidation(ctx, key)
+			}
+			return nil
+		})
+	}
+	if hub != nil {
+		h.UseWatch(hub)
+	}
+	if dispatcher != nil {
+		h.UseWebhooks(backend, func(ctx context.Context) {
+			if err := dispatcher.Reload(ctx); err != nil {
+				logger.Logger.Warn(fmt.Sprintf("Failed to reload webhooks: %v", err))
+			}
+		})
+	}
+
+	// Require API keys on the HTTP API; keys can be managed either way
+	var authn *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authn = auth.New(backend, &cfg.Auth)
+		h.UseAPIKeys(backend, authn.Flush)
+		logger.Logger.Info(fmt.Sprintf("API key authentication enabled (admin key configured: %t)", cfg.Auth.AdminKey != ""))
+		if cfg.Auth.JWTEnabled() {
+			keySet := startKeySet(&cfg.Auth)
+			defer keySet.Close()
+			authn.UseTokens(keySet)
+			logger.Logger.Info(fmt.Sprintf("JWT authentication enabled (issuer: %s, audience: %s)", cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience))
+		}
+	} else {
+		h.UseAPIKeys(backend, nil)
+	}
+
+	// Limit the request rate and daily storage of each client, on every API
+	var limiter *ratelimit.Limiter
+	if cfg.Limits.Enabled {
+		limiter = ratelimit.New(&cfg.Limits)
+		h.UseRateLimits(limiter)
+		if err := smmetrics.RegisterRateLimitClients(limiter.Clients); err != nil {
+			logger.Logger.Warn(fmt.Sprintf("Failed to register rate limit metrics: %v", err))
+		}
+		logger.Logger.Info(fmt.Sprintf("Rate limiting enabled by %s (read: %d/s, write: %d/s)", cfg.Limits.By, cfg.Limits.ReadRate, cfg.Limits.WriteRate))
+	}
 
 	// Create Echo instance
 	e := echo.New()
 	e.HideBanner = true
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.Validator = validation.New()
+	// Client IPs key rate limits, so only trust X-Forwarded-For as set by proxies on
+	// private networks rather than any client's
+	if cfg.Limits.Enabled {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	}
+	// Watch streams never end on their own; close them so shutdown does not wait on them
+	if hub != nil {
+		e.Server.RegisterOnShutdown(hub.Close)
+	}
 
 	// Middleware
 	e.Use(otelecho.Middleware(cfg.OTEL.ServiceName))
@@ -99,19 +358,12 @@ func run() error {
 	// record http_server_* metrics compatible with dashboards
 	e.Use(smmetrics.Middleware())
 
-	e.GET("/v1/health", h.Health)
-
 	// Prometheus metrics endpoint
 	if metricsHandler != nil {
 		e.GET("/metrics", echo.WrapHandler(metricsHandler))
 	}
 
-	e.GET("/docs", h.ServeSwaggerUI)
-	e.GET("/api/openapi.yaml", h.ServeOpenAPISpec)
-
-	api := e.Group("/v1")
-	api.POST("/create", h.Create)
-	api.POST("/search", h.Search)
+	registerRoutes(e, h, authn, hub != nil, dispatcher != nil)
 
 	port := cfg.Server.Port
 	go func() {
@@ -123,9 +375,42 @@ func run() error {
 
 	logger.Logger.Info(fmt.Sprintf("Server started, endpoints:"))
 	logger.Logger.Info(fmt.Sprintf("  http://localhost:%d/v1/health", port))
+	logger.Logger.Info(fmt.Sprintf("  http://localhost:%d/v1/ready", port))
 	logger.Logger.Info(fmt.Sprintf("  http://localhost:%d/docs", port))
 	logger.Logger.Info(fmt.Sprintf("  POST http://localhost:%d/v1/create", port))
 	logger.Logger.Info(fmt.Sprintf("  POST http://localhost:%d/v1/search", port))
+	logger.Logger.Info(fmt.Sprintf("  POST http://localhost:%d/v1/lookup", port))
+	logger.Logger.Info(fmt.Sprintf("  GET/DELETE http://localhost:%d/v1/entries/{key}", port))
+	logger.Logger.Info(fmt.Sprintf("  GET/PUT http://localhost:%d/v1/entries/{key}/value", port))
+	logger.Logger.Info(fmt.Sprintf("  GET http://localhost:%d/v1/admin/stats", port))
+	logger.Logger.Info(fmt.Sprintf("  GET http://localhost:%d/v1/admin/export", port))
+	logger.Logger.Info(fmt.Sprintf("  POST http://localhost:%d/v1/admin/invalidate", port))
+	if hub != nil {
+		logger.Logger.Info(fmt.Sprintf("  GET http://localhost:%d/v1/watch (Server-Sent Events)", port))
+	}
+	if dispatcher != nil {
+		logger.Logger.Info(fmt.Sprintf("  GET/POST http://localhost:%d/v1/webhooks", port))
+		logger.Logger.Info(fmt.Sprintf("  DELETE http://localhost:%d/v1/webhooks/{id}", port))
+		logger.Logger.Info(fmt.Sprintf("  GET http://localhost:%d/v1/webhooks/{id}/dead-letters", port))
+	}
+
+	if cfg.Server.GRPCPort > 0 {
+		grpcServer, err := startGRPC(cfg, store, authn, limiter)
+		if err != nil {
+			return err
+		}
+		defer grpcServer.GracefulStop()
+		logger.Logger.Info(fmt.Sprintf("  gRPC localhost:%d (semcache.v1.SemcacheService)", cfg.Server.GRPCPort))
+	}
+
+	if cfg.Server.RESPPort > 0 {
+		respServer, err := startRESP(cfg, store, authn, limiter)
+		if err != nil {
+			return err
+		}
+		defer respServer.Close()
+		logger.Logger.Info(fmt.Sprintf("  redis://localhost:%d (GET, SET, DEL, EXISTS, TTL, EXPIRE, SCAN, MGET)", cfg.Server.RESPPort))
+	}
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
@@ -135,7 +420,7 @@ func run() error {
 	logger.Logger.Info(fmt.Sprintf("Shutting down server..."))
 
 	// Graceful shutdown with timeout
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := e.Shutdown(ctx); err != nil {
@@ -176,6 +461,8 @@ func initTracer(cfg *config.Config) (func(), error) {
 	)
 
 	otel.SetTracerProvider(tp)
+	// Join traces started by callers, such as services using pkg/client
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
 	return func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
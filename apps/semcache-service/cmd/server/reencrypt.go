@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/database"
+	"github.com/nextinterfaces/semcache-service/internal/envelope"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+const reencryptUsage = "usage: server reencrypt [batch-size]"
+
+// runReencrypt implements the reencrypt subcommand, which moves every row under the
+// primary master key so that retired master keys can be removed from the keyring
+func runReencrypt(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	logger.InitLogger(cfg.Debug)
+	defer logger.Sync()
+
+	batchSize := 500
+	if len(args) > 0 {
+		batchSize, err = strconv.Atoi(args[0])
+		if err != nil || batchSize < 1 {
+			return fmt.Errorf("invalid batch size %q: %s", args[0], reencryptUsage)
+		}
+	}
+
+	if !cfg.Storage.EncryptionEnabled() {
+		return fmt.Errorf("no master keys configured: set ENCRYPTION_KEYS or ENCRYPTION_KEYS_FILE")
+	}
+	keyring, err := loadKeyring(&cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	cacheRepo := models.NewCacheRepository(db.DB)
+	cacheRepo.UseCompression(cfg.Storage.CompressionThreshold)
+	cacheRepo.UseEncryption(keyring, cfg.Storage.EncryptMetadata)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	n, err := cacheRepo.Reencrypt(ctx, batchSize)
+	fmt.Printf("Re-encrypted %d rows under master key %q\n", n, keyring.PrimaryID())
+	return err
+}
+
+// loadKeyring loads the master keys configured in cfg
+func loadKeyring(cfg *config.StorageConfig) (*envelope.Keyring, error) {
+	keyring, err := envelope.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeysFile, cfg.EncryptionPrimaryKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+	return keyring, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+	"github.com/nextinterfaces/semcache-service/internal/resp"
+)
+
+// startRESP serves the Redis protocol listener on cfg.Server.RESPPort and returns the
+// server so it can be closed on shutdown. With authn set, clients AUTH with an API key or
+// JWT rather than RESP_PASSWORD. With limiter set, commands count against the same rate
+// limits and quotas as HTTP requests.
+func startRESP(cfg *config.Config, store models.Store, authn *auth.Authenticator, limiter *ratelimit.Limiter) (*resp.Server, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.RESPPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on RESP port: %w", err)
+	}
+
+	srv := resp.New(store)
+	srv.UseValueLimits(&cfg.Storage)
+	if limiter != nil {
+		srv.UseRateLimits(limiter)
+	}
+	switch {
+	case authn != nil:
+		if cfg.Server.RESPPassword != "" {
+			logger.Logger.Warn("RESP_PASSWORD is ignored with AUTH_ENABLED; RESP clients AUTH with an API key")
+		}
+		srv.UseAPIKeys(authn)
+	case cfg.Server.RESPPassword != "":
+		srv.UsePassword(cfg.Server.RESPPassword)
+	}
+
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logger.Logger.Error(fmt.Sprintf("RESP server error: %v", err))
+		}
+	}()
+
+	return srv, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/api"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/handlers"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// registerRoutes registers the routes of the HTTP API and its documentation. With authn
+// set, API routes require a key with the scope they need; health and documentation
+// routes stay open. The watch and webhook routes are only registered when those features
+// are enabled. Every route apart from the documentation itself must be described in
+// api/openapi.yaml.
+func registerRoutes(e *echo.Echo, h *handlers.Handler, authn *auth.Authenticator, watch, webhooks bool) {
+	require := func(scope string) []echo.MiddlewareFunc {
+		if authn == nil {
+			return nil
+		}
+		return []echo.MiddlewareFunc{authn.Require(scope)}
+	}
+	read, write, admin := require(models.ScopeRead), require(models.ScopeWrite), require(models.ScopeAdmin)
+
+	e.GET("/v1/health", h.Health)
+	e.GET("/v1/ready", h.Ready)
+
+	e.GET("/docs", h.ServeSwaggerUI)
+	e.StaticFS("/docs/assets", api.SwaggerUI())
+	e.GET("/api/openapi.yaml", h.ServeOpenAPISpec)
+
+	v1 := e.Group("/v1")
+	v1.POST("/create", h.Create, write...)
+	v1.POST("/search", h.Search, read...)
+	v1.POST("/lookup", h.Lookup, read...)
+	v1.GET("/entries/:key", h.Get, read...)
+	v1.DELETE("/entries/:key", h.Delete, write...)
+	v1.PUT("/entries/:key/value", h.PutValue, write...)
+	v1.GET("/entries/:key/value", h.GetValue, read...)
+
+	v1.GET("/admin/stats", h.Stats, admin...)
+	v1.GET("/admin/export", h.Export, admin...)
+	v1.POST("/admin/invalidate", h.Invalidate, admin...)
+	v1.POST("/admin/keys", h.CreateAPIKey, admin...)
+	v1.GET("/admin/keys", h.ListAPIKeys, admin...)
+	v1.DELETE("/admin/keys/:id", h.DeleteAPIKey, admin...)
+
+	if watch {
+		v1.GET("/watch", h.Watch, read...)
+	}
+	if webhooks {
+		v1.POST("/webhooks", h.CreateWebhook, admin...)
+		v1.GET("/webhooks", h.ListWebhooks, admin...)
+		v1.DELETE("/webhooks/:id", h.DeleteWebhook, admin...)
+		v1.GET("/webhooks/:id/dead-letters", h.ListDeadLetters, admin...)
+	}
+}
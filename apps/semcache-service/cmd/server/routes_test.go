@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/api"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/handlers"
+	"gopkg.in/yaml.v3"
+)
+
+// pathParam matches an Echo path parameter such as :key
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// operationMethods are the keys of an OpenAPI path item that are operations
+var operationMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+// TestRoutesDocumented checks that every route, with all optional features enabled, is in
+// the OpenAPI spec, and that the spec documents no routes that do not exist
+func TestRoutesDocumented(t *testing.T) {
+	e := echo.New()
+	registerRoutes(e, handlers.New(nil, ""), auth.New(nil, &config.AuthConfig{}), true, true)
+
+	var spec struct {
+		Paths map[string]map[string]yaml.Node `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(api.OpenAPISpec, &spec); err != nil {
+		t.Fatalf("failed to parse api/openapi.yaml: %v", err)
+	}
+
+	registered := make(map[string]bool)
+	for _, route := range e.Routes() {
+		// The documentation does not document itself
+		if route.Path == "/api/openapi.yaml" || strings.HasPrefix(route.Path, "/docs") {
+			continue
+		}
+
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		registered[route.Method+" "+path] = true
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is not documented in api/openapi.yaml", route.Method, path)
+		}
+	}
+
+	for path, item := range spec.Paths {
+		for _, method := range operationMethods {
+			if _, ok := item[strings.ToLower(method)]; ok && !registered[method+" "+path] {
+				t.Errorf("%s %s is documented in api/openapi.yaml but not registered", method, path)
+			}
+		}
+	}
+}
+
+// TestDocsServed checks that the Swagger UI page and its embedded assets are served
+func TestDocsServed(t *testing.T) {
+	e := echo.New()
+	registerRoutes(e, handlers.New(nil, ""), nil, false, false)
+
+	for _, path := range []string{
+		"/docs",
+		"/docs/assets/swagger-ui-bundle.js",
+		"/docs/assets/swagger-ui-standalone-preset.js",
+		"/docs/assets/swagger-ui.css",
+		"/api/openapi.yaml",
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: got status %d, want %d", path, rec.Code, http.StatusOK)
+		}
+		if rec.Body.Len() == 0 {
+			t.Errorf("GET %s: empty body", path)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	smmetrics "github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/watch"
+)
+
+// startWatch creates the hub recording entry events in log and streaming them to
+// watchers. Close the hub before the store so queued events are written.
+func startWatch(cfg *config.WatchConfig, log models.ChangeLog) *watch.Hub {
+	hub := watch.New(log, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := hub.Load(ctx); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to load change log, retrying in %s: %v", cfg.PollInterval, err))
+	}
+
+	if err := smmetrics.RegisterWatchers(hub.Watchers); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to register watch metrics: %v", err))
+	}
+
+	return hub
+}
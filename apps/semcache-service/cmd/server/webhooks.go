@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	smmetrics "github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/webhooks"
+)
+
+// eventBackend is a storage backend that stores webhooks, the change log and API keys,
+// and reports the entries it expires or evicts
+type eventBackend interface {
+	models.WebhookStore
+	models.ChangeLog
+	models.APIKeyStore
+	UseEvents(publish func(models.EntryEvent))
+}
+
+// startWebhooks creates the webhook dispatcher with webhooks stored in store. Close the
+// dispatcher before the store so pending deliveries can be dead-lettered.
+func startWebhooks(cfg *config.WebhookConfig, store models.WebhookStore) *webhooks.Dispatcher {
+	d := webhooks.New(store, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.Reload(ctx); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to load webhooks, retrying in %s: %v", cfg.RefreshInterval, err))
+	}
+
+	if err := smmetrics.RegisterWebhookPending(d.Pending); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to register webhook metrics: %v", err))
+	}
+
+	return d
+}
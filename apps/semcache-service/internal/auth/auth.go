@@ -0,0 +1,236 @@
+// Package auth authenticates HTTP and gRPC API requests by API key or JWT and checks the
+// scopes granted. Credentials are sent as "Authorization: Bearer <key>" or in the X-API-Key
+// header, or the matching gRPC metadata.
+// JWTs are verified against a JSON Web Key Set and mapped to an API key with the scopes and
+// namespaces their claims grant.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// KeyHeader carries an API key as an alternative to the Authorization header
+	KeyHeader = "X-API-Key"
+
+	// keyMarker starts every generated key, so leaked keys are easy to search for
+	keyMarker = "sck_"
+
+	// prefixLength is how much of a key is kept to identify it
+	prefixLength = len(keyMarker) + 8
+
+	// maxCachedKeys bounds the lookup cache; it is emptied when full
+	maxCachedKeys = 10000
+)
+
+// adminKeyName names the key configured with AUTH_ADMIN_KEY
+const adminKeyName = "admin (AUTH_ADMIN_KEY)"
+
+// keyContextKey is the context key of the authenticated API key
+type keyContextKey struct{}
+
+// NewKey generates an API key, returning it with its prefix and hash
+func NewKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = keyMarker + hex.EncodeToString(b)
+	return key, key[:prefixLength], HashKey(key), nil
+}
+
+// HashKey returns the hash an API key is stored under. Keys are random, so a fast hash
+// is enough.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromContext returns the API key a request was authenticated with, or nil if
+// authentication is disabled
+func FromContext(ctx context.Context) *models.APIKey {
+	key, _ := ctx.Value(keyContextKey{}).(*models.APIKey)
+	return key
+}
+
+// Authenticator checks API keys against a store, caching them for CacheTTL
+type Authenticator struct {
+	store models.APIKeyStore
+	cfg   *config.AuthConfig
+
+	// adminHash is the hash of cfg.AdminKey, if it is set
+	adminHash string
+
+	// keys verifies JWTs, if they are accepted
+	keys *KeySet
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+// cachedKey is a key found in the store and when to look it up again
+type cachedKey struct {
+	key     *models.APIKey
+	expires time.Time
+}
+
+// New creates an authenticator looking keys up in store
+func New(store models.APIKeyStore, cfg *config.AuthConfig) *Authenticator {
+	a := &Authenticator{
+		store: store,
+		cfg:   cfg,
+		cache: make(map[string]cachedKey),
+	}
+	if cfg.AdminKey != "" {
+		a.adminHash = HashKey(cfg.AdminKey)
+	}
+	return a
+}
+
+// Flush forgets cached keys, so a deleted key stops working on this replica at once
+func (a *Authenticator) Flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clear(a.cache)
+}
+
+// Require returns middleware rejecting requests without a valid key granting scope. The
+// key is recorded on the request's span and available to handlers through FromContext.
+func (a *Authenticator) Require(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			key, err := a.authenticate(ctx, c.Request())
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="semcache"`)
+				return err
+			}
+
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.Int("semcache.api_key.id", key.ID),
+				attribute.String("semcache.api_key.name", key.Name),
+				attribute.StringSlice("semcache.api_key.scopes", key.Scopes),
+			)
+
+			if !key.HasScope(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key does not have the "+scope+" scope")
+			}
+
+			c.SetRequest(c.Request().WithContext(NewContext(ctx, key)))
+			return next(c)
+		}
+	}
+}
+
+// authenticate returns the key a request carries, or an *echo.HTTPError
+func (a *Authenticator) authenticate(ctx context.Context, req *http.Request) (*models.APIKey, error) {
+	raw := req.Header.Get(KeyHeader)
+	if raw == "" {
+		raw = bearerToken(req.Header.Get(echo.HeaderAuthorization))
+	}
+	return a.Authenticate(ctx, raw)
+}
+
+// Authenticate returns the key for a raw API key or token, or an *echo.HTTPError with the
+// status to reject it with
+func (a *Authenticator) Authenticate(ctx context.Context, raw string) (*models.APIKey, error) {
+	if raw == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "API key required")
+	}
+
+	hash := HashKey(raw)
+	if a.adminHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.adminHash)) == 1 {
+		return &models.APIKey{Name: adminKeyName, Scopes: []string{models.ScopeAdmin}, Namespaces: []string{}}, nil
+	}
+
+	if a.keys != nil && isToken(raw) {
+		key, err := a.verifyToken(ctx, raw)
+		switch {
+		case errors.Is(err, errNoKeys):
+			return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "Token signing keys unavailable")
+		case errors.Is(err, errTokenExpired):
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Token expired")
+		case errors.Is(err, errTokenNoNamespaces):
+			return nil, echo.NewHTTPError(http.StatusForbidden, "Token does not grant access to any namespace")
+		case err != nil:
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid token").SetInternal(err)
+		}
+		return key, nil
+	}
+
+	key, err := a.lookup(ctx, hash)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+	if models.IsUnavailable(err) {
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "Cache storage unavailable")
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to check API key").SetInternal(err)
+	}
+
+	if key.Expired(time.Now()) {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "API key expired")
+	}
+	return key, nil
+}
+
+// bearerToken returns the credential of an Authorization header value using the Bearer
+// scheme, or "" if it uses another
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// NewContext returns ctx carrying key as the authenticated API key
+func NewContext(ctx context.Context, key *models.APIKey) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// lookup returns the stored key with hash, from the cache if it was read recently
+func (a *Authenticator) lookup(ctx context.Context, hash string) (*models.APIKey, error) {
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	key, err := a.store.APIKeyByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	if len(a.cache) >= maxCachedKeys {
+		clear(a.cache)
+	}
+	a.cache[hash] = cachedKey{key: key, expires: now.Add(a.cfg.CacheTTL)}
+	a.mu.Unlock()
+
+	return key, nil
+}
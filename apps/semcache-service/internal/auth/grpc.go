@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor rejects gRPC calls without a valid key granting the scope scopes
+// maps their method to. Methods not in scopes, such as health checks and reflection, are
+// left open.
+func (a *Authenticator) UnaryServerInterceptor(scopes map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		scope, ok := scopes[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		ctx, err := a.authorizeCall(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls
+func (a *Authenticator) StreamServerInterceptor(scopes map[string]string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		scope, ok := scopes[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+		ctx, err := a.authorizeCall(ss.Context(), scope)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizeCall authenticates the credential in a call's metadata and checks it grants
+// scope, returning ctx carrying the key or a gRPC status error
+func (a *Authenticator) authorizeCall(ctx context.Context, scope string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	raw := firstValue(md, strings.ToLower(KeyHeader))
+	if raw == "" {
+		raw = bearerToken(firstValue(md, "authorization"))
+	}
+
+	key, err := a.Authenticate(ctx, raw)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("semcache.api_key.id", key.ID),
+		attribute.String("semcache.api_key.name", key.Name),
+		attribute.StringSlice("semcache.api_key.scopes", key.Scopes),
+	)
+
+	if !key.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "API key does not have the "+scope+" scope")
+	}
+	return NewContext(ctx, key), nil
+}
+
+// grpcError converts an authentication error to a gRPC status error
+func grpcError(err error) error {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		return status.Error(codes.Internal, "failed to check API key")
+	}
+	msg, _ := he.Message.(string)
+	switch he.Code {
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, msg)
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, msg)
+	case http.StatusServiceUnavailable:
+		return status.Error(codes.Unavailable, msg)
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+// firstValue returns the first value of a metadata key, or ""
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authenticatedStream is a server stream whose context carries the authenticated key
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// newTestAuthenticator returns an authenticator over a memory store holding a key with
+// the read scope restricted to the "team" namespace
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+
+	store := models.NewMemoryStore(0, 0)
+	t.Cleanup(func() { store.Close() })
+
+	key := &models.APIKey{Name: "team", Hash: HashKey("sck_team"), Scopes: []string{models.ScopeRead}, Namespaces: []string{"team"}}
+	if err := store.CreateAPIKey(context.Background(), key); err != nil {
+		t.Fatalf("failed to create API key: %v", err)
+	}
+	return New(store, &config.AuthConfig{Enabled: true, AdminKey: "sck_admin", CacheTTL: time.Minute})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := newTestAuthenticator(t)
+	interceptor := a.UnaryServerInterceptor(map[string]string{
+		"/svc/Read":  models.ScopeRead,
+		"/svc/Write": models.ScopeWrite,
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		metadata []string
+		want     codes.Code
+		wantKey  string
+	}{
+		{"open method", "/svc/Health", nil, codes.OK, ""},
+		{"no credential", "/svc/Read", nil, codes.Unauthenticated, ""},
+		{"unknown key", "/svc/Read", []string{"x-api-key", "sck_unknown"}, codes.Unauthenticated, ""},
+		{"other scheme", "/svc/Read", []string{"authorization", "Basic sck_team"}, codes.Unauthenticated, ""},
+		{"api key header", "/svc/Read", []string{"x-api-key", "sck_team"}, codes.OK, "team"},
+		{"bearer", "/svc/Read", []string{"authorization", "Bearer sck_team"}, codes.OK, "team"},
+		{"missing scope", "/svc/Write", []string{"authorization", "Bearer sck_team"}, codes.PermissionDenied, ""},
+		{"admin key", "/svc/Write", []string{"authorization", "bearer sck_admin"}, codes.OK, adminKeyName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.metadata...))
+
+			var gotKey *models.APIKey
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				gotKey = FromContext(ctx)
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.want {
+				t.Fatalf("got code %v, want %v (%v)", code, tt.want, err)
+			}
+			if tt.wantKey != "" && (gotKey == nil || gotKey.Name != tt.wantKey) {
+				t.Errorf("got key %+v, want %s", gotKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+// testServerStream is a server stream with a context
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	a := newTestAuthenticator(t)
+	interceptor := a.StreamServerInterceptor(map[string]string{"/svc/Watch": models.ScopeRead})
+	info := &grpc.StreamServerInfo{FullMethod: "/svc/Watch"}
+
+	stream := &testServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs())}
+	err := interceptor(nil, stream, info, func(interface{}, grpc.ServerStream) error { return nil })
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("without a key: got code %v, want %v", code, codes.Unauthenticated)
+	}
+
+	stream.ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "sck_team"))
+	var gotKey *models.APIKey
+	err = interceptor(nil, stream, info, func(_ interface{}, ss grpc.ServerStream) error {
+		gotKey = FromContext(ss.Context())
+		return nil
+	})
+	if err != nil || gotKey == nil || gotKey.Name != "team" {
+		t.Errorf("with a key: got %v and key %+v", err, gotKey)
+	}
+}
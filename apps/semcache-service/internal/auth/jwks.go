@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+)
+
+const (
+	// maxJWKSBytes bounds the size of a key set
+	maxJWKSBytes = 1 << 20
+
+	// minKeyReload is how soon the key set may be reloaded again for a token signed by an
+	// unknown key, so tokens with made-up key ids cannot flood the issuer
+	minKeyReload = time.Minute
+
+	// minRSABits is the smallest RSA key accepted
+	minRSABits = 2048
+)
+
+// errNoKeys is returned while no key set has been loaded
+var errNoKeys = errors.New("JWKS not loaded")
+
+// KeySet is a JSON Web Key Set loaded from a URL or file, holding the public keys tokens
+// are signed with. It is reloaded periodically, and when a token names a key it does not
+// hold, so keys the issuer rotates in are picked up.
+type KeySet struct {
+	url  string
+	file string
+
+	client *http.Client
+
+	mu       sync.RWMutex
+	keys     []*publicKey
+	loaded   bool
+	reloaded time.Time
+
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// publicKey is a key of the set usable for verifying signatures
+type publicKey struct {
+	id  string
+	alg string // the algorithm the key is restricted to, if any
+	key crypto.PublicKey
+}
+
+// jsonWebKey is a key as found in a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewKeySet creates a key set read from url, or from file if url is empty, and starts
+// reloading it every interval. Call Reload to load it straight away.
+func NewKeySet(url, file string, interval time.Duration) *KeySet {
+	s := &KeySet{
+		url:    url,
+		file:   file,
+		client: &http.Client{Timeout: 10 * time.Second},
+		stop:   make(chan struct{}),
+	}
+
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.refreshLoop(interval)
+	}
+
+	return s
+}
+
+// Reload reads the key set. On failure the keys already loaded are kept.
+func (s *KeySet) Reload(ctx context.Context) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.reload(ctx)
+}
+
+// reloadIfStale reloads the key set unless it was reloaded in the last minKeyReload
+func (s *KeySet) reloadIfStale(ctx context.Context) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.RLock()
+	recent := time.Since(s.reloaded) < minKeyReload
+	s.mu.RUnlock()
+	if recent {
+		return
+	}
+
+	if err := s.reload(ctx); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to reload JWKS: %v", err))
+	}
+}
+
+// reload reads the key set; reloadMu must be held
+func (s *KeySet) reload(ctx context.Context) error {
+	s.mu.Lock()
+	s.reloaded = time.Now()
+	s.mu.Unlock()
+
+	data, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.loaded = true
+	s.mu.Unlock()
+	return nil
+}
+
+// Close stops reloading the key set
+func (s *KeySet) Close() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Len returns the number of keys loaded
+func (s *KeySet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// lookup returns the keys that may have signed a token with the key id kid and algorithm
+// alg. A token without a key id may have been signed by any key. If no key has kid, or no
+// key set has been loaded, the set is reloaded first, at most once every minKeyReload.
+func (s *KeySet) lookup(ctx context.Context, kid, alg string) ([]*publicKey, error) {
+	keys, loaded := s.find(kid, alg)
+	if loaded && (len(keys) > 0 || kid == "") {
+		return keys, nil
+	}
+
+	s.reloadIfStale(ctx)
+	keys, loaded = s.find(kid, alg)
+	if !loaded {
+		return nil, errNoKeys
+	}
+	return keys, nil
+}
+
+// find returns the loaded keys matching kid, if set, and usable with alg, and whether a
+// key set has been loaded
+func (s *KeySet) find(kid, alg string) ([]*publicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []*publicKey
+	for _, key := range s.keys {
+		if kid != "" && key.id != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, s.loaded
+}
+
+// read returns the key set document
+func (s *KeySet) read(ctx context.Context) ([]byte, error) {
+	if s.url == "" {
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	if len(data) > maxJWKSBytes {
+		return nil, fmt.Errorf("JWKS is larger than %d bytes", maxJWKSBytes)
+	}
+	return data, nil
+}
+
+// refreshLoop reloads the key set until Close is called
+func (s *KeySet) refreshLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := s.Reload(ctx); err != nil {
+				logger.Logger.Warn(fmt.Sprintf("Failed to reload JWKS: %v", err))
+			}
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// parseKeySet returns the signing keys of a JWKS document. Keys that are invalid or of
+// unsupported types, and keys meant for encryption, are skipped.
+func parseKeySet(data []byte) ([]*publicKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	var keys []*publicKey
+	for i, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			logger.Logger.Warn(fmt.Sprintf("Skipping invalid JWKS key %d (kid %q): %v", i, jwk.Kid, err))
+			continue
+		}
+		if key != nil {
+			keys = append(keys, &publicKey{id: jwk.Kid, alg: jwk.Alg, key: key})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key, returning nil for unsupported key types
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		if n.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key is shorter than %d bits", minRSABits)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var checker ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, checker = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, checker = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, checker = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("coordinates have the wrong length")
+		}
+		// ecdh checks the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := checker.NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Ed25519 key has the wrong length")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+// decodeBigInt decodes a base64url big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
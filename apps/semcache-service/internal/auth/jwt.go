@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // SHA-256 for RS256, PS256 and ES256
+	_ "crypto/sha512" // SHA-384 and SHA-512 for the other algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// maxTokenBytes bounds the size of a token
+const maxTokenBytes = 16 << 10
+
+// Token verification errors
+var (
+	errTokenMalformed = errors.New("malformed token")
+	errTokenSignature = errors.New("invalid token signature")
+	errTokenExpired   = errors.New("token expired")
+
+	// errTokenNoNamespaces is returned for a token whose namespace claim grants no namespace
+	errTokenNoNamespaces = errors.New("token does not grant access to any namespace")
+)
+
+// signingMethods are the supported JWS algorithms (RFC 7518). HMAC is left out, as it
+// would need a shared secret, and so is "none".
+var signingMethods = map[string]struct {
+	hash crypto.Hash
+	// verify reports whether sig is a valid signature of digest, or of the signing input
+	// for EdDSA, by key
+	verify func(key crypto.PublicKey, hash crypto.Hash, input, digest, sig []byte) bool
+}{
+	"RS256": {crypto.SHA256, verifyPKCS1},
+	"RS384": {crypto.SHA384, verifyPKCS1},
+	"RS512": {crypto.SHA512, verifyPKCS1},
+	"PS256": {crypto.SHA256, verifyPSS},
+	"PS384": {crypto.SHA384, verifyPSS},
+	"PS512": {crypto.SHA512, verifyPSS},
+	"ES256": {crypto.SHA256, verifyECDSA},
+	"ES384": {crypto.SHA384, verifyECDSA},
+	"ES512": {crypto.SHA512, verifyECDSA},
+	"EdDSA": {0, verifyEd25519},
+}
+
+// UseTokens accepts JWT bearer tokens signed by a key in keys, besides API keys
+func (a *Authenticator) UseTokens(keys *KeySet) {
+	a.keys = keys
+}
+
+// isToken reports whether a bearer credential is a JWT rather than an API key
+func isToken(raw string) bool {
+	return strings.Count(raw, ".") == 2
+}
+
+// verifyToken checks a JWT's signature and claims, returning it as an API key with the
+// scopes and namespaces it grants
+func (a *Authenticator) verifyToken(ctx context.Context, raw string) (*models.APIKey, error) {
+	if len(raw) > maxTokenBytes {
+		return nil, errTokenMalformed
+	}
+	parts := strings.Split(raw, ".")
+
+	var header struct {
+		Alg  string          `json:"alg"`
+		Kid  string          `json:"kid"`
+		Crit json.RawMessage `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	// No extensions are understood, so tokens that need one must be rejected
+	if header.Crit != nil {
+		return nil, fmt.Errorf("%w: unsupported crit header", errTokenMalformed)
+	}
+	method, ok := signingMethods[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported alg %q", errTokenMalformed, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errTokenMalformed, err)
+	}
+	keys, err := a.keys.lookup(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	input := []byte(parts[0] + "." + parts[1])
+	var digest []byte
+	if method.hash != 0 {
+		h := method.hash.New()
+		h.Write(input)
+		digest = h.Sum(nil)
+	}
+	verified := false
+	for _, key := range keys {
+		if method.verify(key.key, method.hash, input, digest, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errTokenSignature
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return a.tokenKey(claims, time.Now())
+}
+
+// tokenKey checks the claims of a verified token and maps them to an API key
+func (a *Authenticator) tokenKey(claims map[string]interface{}, now time.Time) (*models.APIKey, error) {
+	leeway := a.cfg.JWTLeeway
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", errTokenMalformed)
+	}
+	if !now.Before(exp.Add(leeway)) {
+		return nil, errTokenExpired
+	}
+	if _, present := claims["nbf"]; present {
+		nbf, ok := numericDate(claims["nbf"])
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid nbf", errTokenMalformed)
+		}
+		if now.Add(leeway).Before(nbf) {
+			return nil, fmt.Errorf("%w: token not valid yet", errTokenMalformed)
+		}
+	}
+
+	if iss, _ := claims["iss"].(string); iss != a.cfg.JWTIssuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", errTokenMalformed, iss)
+	}
+	audiences := stringList(claims["aud"])
+	if aud, ok := claims["aud"].(string); ok {
+		audiences = []string{aud}
+	}
+	if !slices.Contains(audiences, a.cfg.JWTAudience) {
+		return nil, fmt.Errorf("%w: audience does not include %q", errTokenMalformed, a.cfg.JWTAudience)
+	}
+
+	var scopes []string
+	for _, value := range stringList(claim(claims, a.cfg.JWTScopeClaim)) {
+		scope, ok := strings.CutPrefix(value, a.cfg.JWTScopePrefix)
+		if ok && slices.Contains(models.Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	slices.Sort(scopes)
+
+	subject, _ := claims["sub"].(string)
+	key := &models.APIKey{
+		Name:       "token " + subject,
+		Scopes:     scopes,
+		Namespaces: []string{},
+		ExpiresAt:  &exp,
+	}
+
+	// Admin scope reaches every namespace through the admin endpoints, so it is not limited
+	if a.cfg.JWTNamespaceClaim != "" && !key.HasScope(models.ScopeAdmin) {
+		key.Namespaces = a.tokenNamespaces(claim(claims, a.cfg.JWTNamespaceClaim))
+		if len(key.Namespaces) == 0 {
+			return nil, errTokenNoNamespaces
+		}
+	}
+
+	return key, nil
+}
+
+// tokenNamespaces maps the values of a token's namespace claim to namespaces. Values that
+// are not in JWTNamespaceMap, when it is set, or are not valid namespaces are ignored.
+func (a *Authenticator) tokenNamespaces(value interface{}) []string {
+	var namespaces []string
+	for _, v := range stringList(value) {
+		namespace := v
+		if len(a.cfg.JWTNamespaceMap) > 0 {
+			var ok bool
+			if namespace, ok = a.cfg.JWTNamespaceMap[v]; !ok {
+				continue
+			}
+		}
+		if namespace == "" || strings.Contains(namespace, ":") {
+			continue
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces)
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errTokenMalformed, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errTokenMalformed, err)
+	}
+	return nil
+}
+
+// claim returns the claim at a dotted path, such as "realm_access.roles"
+func claim(claims map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	// A claim whose name contains dots is matched first
+	if value, ok := claims[path]; ok {
+		return value
+	}
+
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// stringList returns a claim that is a space-separated string or an array of strings as a list
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// numericDate returns a claim holding seconds since the epoch as a time
+func numericDate(value interface{}) (time.Time, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), true
+}
+
+func verifyPKCS1(key crypto.PublicKey, hash crypto.Hash, _, digest, sig []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	return ok && rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil
+}
+
+func verifyPSS(key crypto.PublicKey, hash crypto.Hash, _, digest, sig []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	return ok && rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+}
+
+// verifyECDSA checks a signature encoded as the fixed-size r and s (RFC 7518 section 3.4).
+// The curve must match the hash: P-256 with SHA-256 and so on.
+func verifyECDSA(key crypto.PublicKey, hash crypto.Hash, _, digest, sig []byte) bool {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	bits := pub.Curve.Params().BitSize
+	switch {
+	case hash == crypto.SHA256 && bits == 256,
+		hash == crypto.SHA384 && bits == 384,
+		hash == crypto.SHA512 && bits == 521:
+	default:
+		return false
+	}
+
+	size := (bits + 7) / 8
+	if len(sig) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(pub, digest, r, s)
+}
+
+func verifyEd25519(key crypto.PublicKey, _ crypto.Hash, input, _, sig []byte) bool {
+	pub, ok := key.(ed25519.PublicKey)
+	return ok && ed25519.Verify(pub, input, sig)
+}
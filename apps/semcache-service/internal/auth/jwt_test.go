@@ -0,0 +1,518 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// Test token settings
+const (
+	testIssuer   = "https://issuer.example"
+	testAudience = "semcache"
+)
+
+// testKeys are the signing keys of the test key set, generated once
+var testKeys = struct {
+	rsa   *rsa.PrivateKey
+	p256  *ecdsa.PrivateKey
+	p384  *ecdsa.PrivateKey
+	ed    ed25519.PrivateKey
+	other *rsa.PrivateKey // not in the key set
+}{}
+
+func init() {
+	// Skipped keys and failed reloads are logged
+	logger.Logger = zap.NewNop()
+
+	var err error
+	if testKeys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testKeys.other, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testKeys.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+	if testKeys.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		panic(err)
+	}
+	if _, testKeys.ed, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// jwk returns the JWKS entry for the public half of key
+func jwk(kid string, key crypto.Signer) map[string]string {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC", "kid": kid, "crv": pub.Curve.Params().Name,
+			"x": b64(pub.X.FillBytes(make([]byte, size))), "y": b64(pub.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(pub)}
+	}
+	panic("unsupported key type")
+}
+
+// writeKeySet writes a JWKS of keys, by key id, to path
+func writeKeySet(t *testing.T, path string, keys map[string]crypto.Signer) {
+	t.Helper()
+
+	var doc struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		doc.Keys = append(doc.Keys, jwk(kid, key))
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sign returns a token with header and claims, signed with key using alg. A nil key
+// leaves the signature empty.
+func sign(t *testing.T, alg string, key crypto.Signer, header, claims map[string]interface{}) string {
+	t.Helper()
+
+	h := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	for name, value := range header {
+		h[name] = value
+	}
+	hdr, _ := json.Marshal(h)
+	body, _ := json.Marshal(claims)
+	input := b64(hdr) + "." + b64(body)
+	if key == nil {
+		return input + "."
+	}
+
+	var sig []byte
+	var err error
+	method, ok := signingMethods[alg]
+	if !ok {
+		t.Fatalf("unsupported alg %s", alg)
+	}
+	var digest []byte
+	if method.hash != 0 {
+		hash := method.hash.New()
+		hash.Write([]byte(input))
+		digest = hash.Sum(nil)
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			sig, err = rsa.SignPSS(rand.Reader, k, method.hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, method.hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64(sig)
+}
+
+// validClaims returns the claims of a token valid for an hour
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "user-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "semcache:read",
+	}
+}
+
+// newTokenAuthenticator returns an authenticator accepting tokens signed by the test keys,
+// loaded from a file in a temporary directory that is returned too
+func newTokenAuthenticator(t *testing.T, cfg config.AuthConfig) (*Authenticator, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeKeySet(t, path, map[string]crypto.Signer{
+		"rsa": testKeys.rsa, "p256": testKeys.p256, "p384": testKeys.p384, "ed": testKeys.ed,
+	})
+	keys := NewKeySet("", path, 0)
+	if err := keys.Reload(context.Background()); err != nil {
+		t.Fatalf("failed to load key set: %v", err)
+	}
+
+	cfg.JWTIssuer = testIssuer
+	cfg.JWTAudience = testAudience
+	cfg.JWTScopeClaim = "scope"
+	cfg.JWTScopePrefix = "semcache:"
+	if cfg.JWTLeeway == 0 {
+		cfg.JWTLeeway = time.Minute
+	}
+	a := New(nil, &cfg)
+	a.UseTokens(keys)
+	return a, path
+}
+
+func TestVerifyTokenSignature(t *testing.T) {
+	a, _ := newTokenAuthenticator(t, config.AuthConfig{})
+
+	es256 := sign(t, "ES256", testKeys.p256, map[string]interface{}{"kid": "p256"}, validClaims())
+	input := es256[:strings.LastIndex(es256, ".")]
+	digest := sha256.Sum256([]byte(input))
+
+	// An ASN.1 DER signature, as some libraries wrongly produce, must not verify
+	der, err := ecdsa.SignASN1(rand.Reader, testKeys.p256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An HMAC keyed with the public key must not verify: HS256 is not accepted
+	hs256 := sign(t, "HS256", nil, map[string]interface{}{"kid": "rsa"}, validClaims())
+	mac := hmac.New(sha256.New, x509.MarshalPKCS1PublicKey(&testKeys.rsa.PublicKey))
+	mac.Write([]byte(strings.TrimSuffix(hs256, ".")))
+	hs256 += b64(mac.Sum(nil))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error // nil if the token is valid
+	}{
+		{"RS256", sign(t, "RS256", testKeys.rsa, map[string]interface{}{"kid": "rsa"}, validClaims()), nil},
+		{"PS384", sign(t, "PS384", testKeys.rsa, map[string]interface{}{"kid": "rsa"}, validClaims()), nil},
+		{"ES256", es256, nil},
+		{"ES384", sign(t, "ES384", testKeys.p384, map[string]interface{}{"kid": "p384"}, validClaims()), nil},
+		{"EdDSA", sign(t, "EdDSA", testKeys.ed, map[string]interface{}{"kid": "ed"}, validClaims()), nil},
+		{"no kid", sign(t, "RS256", testKeys.rsa, nil, validClaims()), nil},
+
+		{"alg none", sign(t, "none", nil, map[string]interface{}{"kid": "rsa"}, validClaims()), errTokenMalformed},
+		{"alg HS256", hs256, errTokenMalformed},
+		{"crit header", sign(t, "RS256", testKeys.rsa, map[string]interface{}{"kid": "rsa", "crit": []string{"exp"}}, validClaims()), errTokenMalformed},
+		{"RS256 alg with EC key", sign(t, "ES256", testKeys.p256, map[string]interface{}{"kid": "p256", "alg": "RS256"}, validClaims()), errTokenSignature},
+		{"ES256 alg with P-384 key", sign(t, "ES384", testKeys.p384, map[string]interface{}{"kid": "p384", "alg": "ES256"}, validClaims()), errTokenSignature},
+		{"EdDSA alg with RSA key", sign(t, "RS256", testKeys.rsa, map[string]interface{}{"kid": "rsa", "alg": "EdDSA"}, validClaims()), errTokenSignature},
+		{"ES256 DER signature", input + "." + b64(der), errTokenSignature},
+		{"ES256 truncated signature", es256[:len(es256)-4], errTokenSignature},
+		{"unknown key", sign(t, "RS256", testKeys.other, map[string]interface{}{"kid": "rsa"}, validClaims()), errTokenSignature},
+		{"tampered claims", strings.Replace(es256, strings.Split(es256, ".")[1], b64([]byte(`{"iss":"x"}`)), 1), errTokenSignature},
+		{"bad base64", "a.b.c!", errTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := a.verifyToken(context.Background(), tt.token)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				if key.Name != "token user-1" || !slices.Equal(key.Scopes, []string{models.ScopeRead}) {
+					t.Errorf("got key %+v", key)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTokenClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	a, _ := newTokenAuthenticator(t, config.AuthConfig{JWTLeeway: 30 * time.Second})
+
+	// claims returns valid claims as decoded from a token, with changes applied
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   testIssuer,
+			"aud":   testAudience,
+			"exp":   json.Number("1700003600"),
+			"scope": "semcache:read",
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		err    error
+	}{
+		{"valid", claims(nil), nil},
+		{"missing exp", claims(map[string]interface{}{"exp": nil}), errTokenMalformed},
+		{"exp not a number", claims(map[string]interface{}{"exp": "tomorrow"}), errTokenMalformed},
+		{"expired within leeway", claims(map[string]interface{}{"exp": json.Number("1699999980")}), nil},
+		{"expired beyond leeway", claims(map[string]interface{}{"exp": json.Number("1699999960")}), errTokenExpired},
+		{"expired at leeway", claims(map[string]interface{}{"exp": json.Number("1699999970.0")}), errTokenExpired},
+		{"fractional exp", claims(map[string]interface{}{"exp": json.Number("1699999970.5")}), nil},
+		{"nbf passed", claims(map[string]interface{}{"nbf": json.Number("1699999000")}), nil},
+		{"nbf within leeway", claims(map[string]interface{}{"nbf": json.Number("1700000020")}), nil},
+		{"nbf beyond leeway", claims(map[string]interface{}{"nbf": json.Number("1700000040")}), errTokenMalformed},
+		{"nbf not a number", claims(map[string]interface{}{"nbf": "soon"}), errTokenMalformed},
+		{"wrong issuer", claims(map[string]interface{}{"iss": "https://other.example"}), errTokenMalformed},
+		{"missing issuer", claims(map[string]interface{}{"iss": nil}), errTokenMalformed},
+		{"aud array", claims(map[string]interface{}{"aud": []interface{}{"other", testAudience}}), nil},
+		{"aud array without audience", claims(map[string]interface{}{"aud": []interface{}{"other"}}), errTokenMalformed},
+		{"wrong aud", claims(map[string]interface{}{"aud": "other"}), errTokenMalformed},
+		{"aud containing audience", claims(map[string]interface{}{"aud": "other " + testAudience}), errTokenMalformed},
+		{"missing aud", claims(map[string]interface{}{"aud": nil}), errTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.tokenKey(tt.claims, now)
+			if tt.err == nil && err != nil {
+				t.Fatalf("got error %v, want none", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTokenScopesAndNamespaces(t *testing.T) {
+	now := time.Now()
+	exp := json.Number("99999999999")
+
+	tests := []struct {
+		name       string
+		cfg        config.AuthConfig
+		claims     map[string]interface{}
+		scopes     []string
+		namespaces []string
+		err        error
+	}{
+		{
+			name:       "space-separated scopes",
+			claims:     map[string]interface{}{"scope": "openid semcache:write semcache:read semcache:read"},
+			scopes:     []string{"read", "write"},
+			namespaces: []string{},
+		},
+		{
+			name:       "scope array without prefix ignored",
+			claims:     map[string]interface{}{"scope": []interface{}{"read", "semcache:admin", "semcache:owner", 42}},
+			scopes:     []string{"admin"},
+			namespaces: []string{},
+		},
+		{
+			name:       "nested scope claim",
+			cfg:        config.AuthConfig{JWTScopeClaim: "realm_access.roles"},
+			claims:     map[string]interface{}{"realm_access": map[string]interface{}{"roles": []interface{}{"semcache:read"}}},
+			scopes:     []string{"read"},
+			namespaces: []string{},
+		},
+		{
+			name:       "namespace claim",
+			cfg:        config.AuthConfig{JWTNamespaceClaim: "groups"},
+			claims:     map[string]interface{}{"scope": "semcache:read", "groups": []interface{}{"team", "ops", "bad:ns", "", "team"}},
+			scopes:     []string{"read"},
+			namespaces: []string{"ops", "team"},
+		},
+		{
+			name:       "namespace map",
+			cfg:        config.AuthConfig{JWTNamespaceClaim: "groups", JWTNamespaceMap: map[string]string{"eng": "team", "ops": "ops"}},
+			claims:     map[string]interface{}{"scope": "semcache:read", "groups": "eng sales"},
+			scopes:     []string{"read"},
+			namespaces: []string{"team"},
+		},
+		{
+			name:   "no namespaces granted",
+			cfg:    config.AuthConfig{JWTNamespaceClaim: "groups"},
+			claims: map[string]interface{}{"scope": "semcache:read", "groups": []interface{}{}},
+			err:    errTokenNoNamespaces,
+		},
+		{
+			name:   "namespace claim missing",
+			cfg:    config.AuthConfig{JWTNamespaceClaim: "groups"},
+			claims: map[string]interface{}{"scope": "semcache:write"},
+			err:    errTokenNoNamespaces,
+		},
+		{
+			name:       "admin not limited to namespaces",
+			cfg:        config.AuthConfig{JWTNamespaceClaim: "groups"},
+			claims:     map[string]interface{}{"scope": "semcache:admin"},
+			scopes:     []string{"admin"},
+			namespaces: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTScopePrefix = testIssuer, testAudience, "semcache:"
+			if cfg.JWTScopeClaim == "" {
+				cfg.JWTScopeClaim = "scope"
+			}
+			a := New(nil, &cfg)
+
+			claims := map[string]interface{}{"iss": testIssuer, "aud": testAudience, "exp": exp, "sub": "user-1"}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+
+			key, err := a.tokenKey(claims, now)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !slices.Equal(key.Scopes, tt.scopes) {
+				t.Errorf("got scopes %v, want %v", key.Scopes, tt.scopes)
+			}
+			if !slices.Equal(key.Namespaces, tt.namespaces) {
+				t.Errorf("got namespaces %v, want %v", key.Namespaces, tt.namespaces)
+			}
+		})
+	}
+}
+
+// TestKeySetReload checks that a token signed by an unknown key reloads the key set, at
+// most once every minKeyReload
+func TestKeySetReload(t *testing.T) {
+	a, path := newTokenAuthenticator(t, config.AuthConfig{})
+	ctx := context.Background()
+
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKeySet(t, path, map[string]crypto.Signer{"rsa": testKeys.rsa, "rotated": rotated})
+	token := sign(t, "RS256", rotated, map[string]interface{}{"kid": "rotated"}, validClaims())
+
+	// The key set was just loaded, so it is not read again yet
+	if _, err := a.verifyToken(ctx, token); !errors.Is(err, errTokenSignature) {
+		t.Fatalf("right after loading: got error %v, want %v", err, errTokenSignature)
+	}
+
+	a.keys.mu.Lock()
+	a.keys.reloaded = time.Now().Add(-minKeyReload)
+	a.keys.mu.Unlock()
+	if _, err := a.verifyToken(ctx, token); err != nil {
+		t.Fatalf("after minKeyReload: got error %v, want none", err)
+	}
+	if n := a.keys.Len(); n != 2 {
+		t.Errorf("got %d keys after reloading, want 2", n)
+	}
+
+	// Further unknown key ids cannot make it read the key set again straight away
+	writeKeySet(t, path, map[string]crypto.Signer{"rsa": testKeys.rsa, "rotated": rotated, "next": testKeys.other})
+	next := sign(t, "RS256", testKeys.other, map[string]interface{}{"kid": "next"}, validClaims())
+	for i := 0; i < 3; i++ {
+		if _, err := a.verifyToken(ctx, next); !errors.Is(err, errTokenSignature) {
+			t.Fatalf("within minKeyReload of the last reload: got error %v, want %v", err, errTokenSignature)
+		}
+	}
+
+	// A failed reload keeps the keys already loaded
+	os.Remove(path)
+	a.keys.mu.Lock()
+	a.keys.reloaded = time.Now().Add(-minKeyReload)
+	a.keys.mu.Unlock()
+	if _, err := a.verifyToken(ctx, next); !errors.Is(err, errTokenSignature) {
+		t.Errorf("after a failed reload: got error %v, want %v", err, errTokenSignature)
+	}
+	if _, err := a.verifyToken(ctx, token); err != nil {
+		t.Errorf("after a failed reload: got error %v for a loaded key, want none", err)
+	}
+}
+
+func TestKeySetNotLoaded(t *testing.T) {
+	keys := NewKeySet("", filepath.Join(t.TempDir(), "missing.json"), 0)
+	a := New(nil, &config.AuthConfig{JWTIssuer: testIssuer, JWTAudience: testAudience, JWTScopeClaim: "scope"})
+	a.UseTokens(keys)
+
+	token := sign(t, "RS256", testKeys.rsa, map[string]interface{}{"kid": "rsa"}, validClaims())
+	if _, err := a.verifyToken(context.Background(), token); !errors.Is(err, errNoKeys) {
+		t.Errorf("got error %v, want %v", err, errNoKeys)
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := jwk("off", testKeys.p256)
+	offCurve["y"] = offCurve["x"]
+
+	tests := []struct {
+		name string
+		keys []map[string]string
+		want []string // ids of the keys parsed; none means an error
+	}{
+		{"valid", []map[string]string{jwk("rsa", testKeys.rsa), jwk("p256", testKeys.p256), jwk("ed", testKeys.ed)}, []string{"rsa", "p256", "ed"}},
+		{"short RSA key skipped", []map[string]string{jwk("small", small), jwk("rsa", testKeys.rsa)}, []string{"rsa"}},
+		{"point off curve skipped", []map[string]string{offCurve, jwk("ed", testKeys.ed)}, []string{"ed"}},
+		{"encryption key skipped", []map[string]string{{"kty": "RSA", "use": "enc"}, jwk("ed", testKeys.ed)}, []string{"ed"}},
+		{"symmetric key skipped", []map[string]string{{"kty": "oct", "k": "c2VjcmV0"}, jwk("ed", testKeys.ed)}, []string{"ed"}},
+		{"no signing keys", []map[string]string{{"kty": "oct", "k": "c2VjcmV0"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(map[string]interface{}{"keys": tt.keys})
+			keys, err := parseKeySet(data)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("got %d keys, want an error", len(keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			var ids []string
+			for _, key := range keys {
+				ids = append(ids, key.id)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("got keys %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
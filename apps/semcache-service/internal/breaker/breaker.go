@@ -0,0 +1,141 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker is rejecting calls
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// Closed lets every call through and counts consecutive failures
+	Closed State = iota
+	// HalfOpen lets a single probe call through to test whether the dependency recovered
+	HalfOpen
+	// Open rejects every call until the open timeout has passed
+	Open
+)
+
+// String returns the lowercase name of the state
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Breaker is a consecutive-failure circuit breaker
+type Breaker struct {
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// generation counts state transitions. Calls are stamped with the generation they were
+	// allowed under, so outcomes of calls allowed before a transition are ignored.
+	generation uint64
+
+	threshold   int
+	openTimeout time.Duration
+	onChange    func(from, to State)
+}
+
+// New creates a breaker that opens after threshold consecutive failures and stays open
+// for openTimeout before letting a probe through. onChange, if not nil, is called on
+// every state transition while the breaker's lock is held.
+func New(threshold int, openTimeout time.Duration, onChange func(from, to State)) *Breaker {
+	return &Breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		onChange:    onChange,
+	}
+}
+
+// Allow reports whether a call may proceed, returning the generation it is allowed under.
+// Every allowed call must be followed by Record with that generation.
+func (b *Breaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return 0, ErrOpen
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+	case HalfOpen:
+		if b.probing {
+			return 0, ErrOpen
+		}
+		b.probing = true
+	}
+
+	return b.generation, nil
+}
+
+// Record reports the outcome of a call allowed under generation. Outcomes of calls
+// allowed before the last transition are ignored: only the half-open probe closes an
+// open breaker, and a slow call from before it opened cannot close it early.
+func (b *Breaker) Record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+		if success {
+			b.setState(Closed)
+		} else {
+			b.open()
+		}
+	case Closed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// open trips the breaker; callers hold b.mu
+func (b *Breaker) open() {
+	b.failures = 0
+	b.openedAt = time.Now()
+	b.setState(Open)
+}
+
+// setState transitions to state and notifies onChange; callers hold b.mu
+func (b *Breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	b.generation++
+	if b.onChange != nil && from != state {
+		b.onChange(from, state)
+	}
+}
@@ -0,0 +1,96 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// allow calls b.Allow and fails the test if the call is rejected
+func allow(t *testing.T, b *Breaker) uint64 {
+	t.Helper()
+	generation, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Allow() = %v, want the call allowed", err)
+	}
+	return generation
+}
+
+// expectState fails the test unless b is in state
+func expectState(t *testing.T, b *Breaker, state State) {
+	t.Helper()
+	if got := b.State(); got != state {
+		t.Fatalf("State() = %s, want %s", got, state)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	var transitions []string
+	b := New(2, 10*time.Millisecond, func(from, to State) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+
+	// A success resets the count of consecutive failures
+	b.Record(allow(t, b), false)
+	b.Record(allow(t, b), true)
+	b.Record(allow(t, b), false)
+	expectState(t, b, Closed)
+
+	b.Record(allow(t, b), false)
+	expectState(t, b, Open)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() while open = %v, want ErrOpen", err)
+	}
+
+	// After the open timeout, one probe is let through
+	time.Sleep(15 * time.Millisecond)
+	probe := allow(t, b)
+	expectState(t, b, HalfOpen)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() while probing = %v, want ErrOpen", err)
+	}
+
+	// A failed probe opens the breaker again; a successful one closes it
+	b.Record(probe, false)
+	expectState(t, b, Open)
+	time.Sleep(15 * time.Millisecond)
+	b.Record(allow(t, b), true)
+	expectState(t, b, Closed)
+
+	want := []string{"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestStaleOutcomesIgnored(t *testing.T) {
+	b := New(1, 10*time.Millisecond, nil)
+
+	// A slow call allowed while closed finishes after the breaker opened
+	slow := allow(t, b)
+	b.Record(allow(t, b), false)
+	expectState(t, b, Open)
+
+	b.Record(slow, true)
+	expectState(t, b, Open)
+
+	// ...or while the probe is in flight, which alone decides the outcome
+	time.Sleep(15 * time.Millisecond)
+	probe := allow(t, b)
+	b.Record(slow, true)
+	expectState(t, b, HalfOpen)
+	b.Record(slow, false)
+	expectState(t, b, HalfOpen)
+
+	b.Record(probe, true)
+	expectState(t, b, Closed)
+
+	// Once closed again, the stale failure does not count either
+	b.Record(slow, false)
+	expectState(t, b, Closed)
+}
@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
+	Storage  StorageConfig
+	Webhooks WebhookConfig
+	Watch    WatchConfig
+	Auth     AuthConfig
+	Limits   RateLimitConfig
 	OTEL     OTELConfig
 	Debug    bool
 }
@@ -18,6 +25,15 @@ type Config struct {
 type ServerConfig struct {
 	Port      int
 	CommitSHA string
+
+	// GRPCPort serves the gRPC API on its own port; 0, the default, disables it
+	GRPCPort int
+
+	// RESPPort serves a subset of the Redis protocol for exact-key caching; 0 disables it.
+	// With RESPPassword set, clients must AUTH first. With auth enabled, they AUTH with an
+	// API key instead and RESPPassword is ignored.
+	RESPPort     int
+	RESPPassword string
 }
 
 // DatabaseConfig holds database configuration
@@ -28,6 +44,208 @@ type DatabaseConfig struct {
 	Password string
 	Database string
 	SSLMode  string
+
+	// ReadReplicas are connection strings for replicas serving get, search and lookup queries
+	ReadReplicas []string
+
+	// ConnectAttempts pings the database up to this many times on startup, waiting
+	// ConnectBackoff after the first failure and doubling up to ConnectMaxBackoff
+	ConnectAttempts   int
+	ConnectBackoff    time.Duration
+	ConnectMaxBackoff time.Duration
+
+	// DegradedMode keeps the service up (reporting not-ready) when the database is
+	// unavailable at startup, reconnecting in the background
+	DegradedMode bool
+
+	// AutoMigrate applies pending schema migrations on startup
+	AutoMigrate bool
+
+	// Breaker* configure the circuit breaker around database calls. It opens after
+	// BreakerThreshold consecutive failures (calls slower than BreakerCallTimeout count as
+	// failures) and lets a probe through after BreakerOpenTimeout. While it is open, calls
+	// fail fast with 503s; BreakerMissOnOpen, off by default, answers reads as cache misses
+	// instead.
+	BreakerEnabled     bool
+	BreakerThreshold   int
+	BreakerOpenTimeout time.Duration
+	BreakerCallTimeout time.Duration
+	BreakerMissOnOpen  bool
+
+	// Partitioning range-partitions semcache by created_at: "" (off), "daily" or "weekly".
+	// Whole partitions older than PartitionRetention are dropped, so entries whose TTL
+	// outlives the retention horizon are removed early.
+	Partitioning       string
+	PartitionRetention time.Duration
+	PartitionPremake   int
+}
+
+// StorageConfig selects and tunes the cache storage backend
+type StorageConfig struct {
+	Backend             string // "postgres", "memory" or "sqlite"
+	MemoryMaxEntries    int
+	MemorySweepInterval time.Duration
+	SQLitePath          string
+
+	// ExpirySweepInterval is how often postgres and sqlite delete expired entries (0 disables).
+	// The memory backend sweeps every MemorySweepInterval instead.
+	ExpirySweepInterval time.Duration
+
+	// MaxValueBytes caps the size of a single value, however it is uploaded.
+	// NamespaceMaxValueBytes overrides it per namespace (the key prefix before ':').
+	MaxValueBytes          int
+	NamespaceMaxValueBytes map[string]int
+
+	// ValueChunkSize is the largest value kept in a single postgres row; larger values
+	// uploaded as raw bodies are split into chunks of this size
+	ValueChunkSize int
+
+	// L1 is an in-process tier in front of the postgres and sqlite backends
+	L1Enabled   bool
+	L1MaxBytes  int
+	L1TTL       time.Duration
+	L1LookupTTL time.Duration
+
+	// CompressionThreshold gzips values of at least this many bytes in postgres (0 disables)
+	CompressionThreshold int
+
+	// EncryptionKeys ("id:base64key,...") or the file EncryptionKeysFile holds the master
+	// keys that wrap per-value data keys in postgres; encryption is off when neither is set.
+	// EncryptionPrimaryKey picks the key for new data (default: the last one listed).
+	EncryptionKeys       string
+	EncryptionKeysFile   string
+	EncryptionPrimaryKey string
+	EncryptMetadata      bool
+}
+
+// MaxValueBytesFor returns the maximum value size in namespace
+func (c *StorageConfig) MaxValueBytesFor(namespace string) int {
+	if limit, ok := c.NamespaceMaxValueBytes[namespace]; ok {
+		return limit
+	}
+	return c.MaxValueBytes
+}
+
+// LargestMaxValueBytes returns the largest maximum value size of any namespace
+func (c *StorageConfig) LargestMaxValueBytes() int {
+	largest := c.MaxValueBytes
+	for _, limit := range c.NamespaceMaxValueBytes {
+		if limit > largest {
+			largest = limit
+		}
+	}
+	return largest
+}
+
+// EncryptionEnabled reports whether master keys are configured
+func (c *StorageConfig) EncryptionEnabled() bool {
+	return c.EncryptionKeys != "" || c.EncryptionKeysFile != ""
+}
+
+// WebhookConfig tunes the delivery of entry events to webhooks
+type WebhookConfig struct {
+	Enabled bool
+
+	// A delivery is attempted up to MaxAttempts times, waiting MinBackoff after the first
+	// failure and doubling up to MaxBackoff, before it is dead-lettered
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+
+	// MaxPending bounds deliveries waiting to be sent or retried; further ones are
+	// dead-lettered straight away. Concurrency bounds requests in flight.
+	MaxPending  int
+	Concurrency int
+
+	// RefreshInterval is how often webhooks registered through other replicas are picked up
+	RefreshInterval time.Duration
+}
+
+// WatchConfig tunes the change log behind the watch endpoint
+type WatchConfig struct {
+	Enabled bool
+
+	// Retention is how long changes are kept for watchers resuming from an earlier position
+	Retention time.Duration
+
+	// PollInterval is how often changes made through other replicas are picked up
+	PollInterval time.Duration
+
+	// MaxPending bounds changes waiting to be written to the log; further ones are dropped
+	MaxPending int
+}
+
+// AuthConfig controls API key and JWT authentication of the HTTP, gRPC and RESP APIs
+type AuthConfig struct {
+	Enabled bool
+
+	// AdminKey, if set, is accepted as a key with the admin scope, to create the first
+	// stored keys with
+	AdminKey string
+
+	// CacheTTL is how long a looked-up key is trusted before it is read again, so a
+	// deleted key can keep working on other replicas for up to this long
+	CacheTTL time.Duration
+
+	// JWKSURL or JWKSFile, if one is set, enables JWT bearer tokens signed by the keys of
+	// the JSON Web Key Set it holds. The set is reloaded every JWKSRefresh.
+	JWKSURL     string
+	JWKSFile    string
+	JWKSRefresh time.Duration
+
+	// JWTIssuer and JWTAudience must match the iss and aud claims of a token
+	JWTIssuer   string
+	JWTAudience string
+
+	// JWTScopeClaim holds a token's scopes, as a space-separated string or an array. Only
+	// values starting with JWTScopePrefix count, with the prefix removed.
+	JWTScopeClaim  string
+	JWTScopePrefix string
+
+	// JWTNamespaceClaim, if set, is the claim listing the namespaces a token may access, a
+	// dotted path for nested claims. JWTNamespaceMap maps its values to namespaces; if it
+	// is empty, values are used as namespaces.
+	JWTNamespaceClaim string
+	JWTNamespaceMap   map[string]string
+
+	// JWTLeeway allows for clock skew when checking the exp and nbf claims
+	JWTLeeway time.Duration
+}
+
+// Rate limit clients, as set in RATE_LIMIT_BY
+const (
+	RateLimitByAPIKey    = "api_key"
+	RateLimitByNamespace = "namespace"
+	RateLimitByIP        = "ip"
+)
+
+// RateLimitConfig controls per-client rate limits and daily quotas on the HTTP, gRPC and
+// RESP APIs, which share each client's counts. Both are counted by each replica separately.
+type RateLimitConfig struct {
+	Enabled bool
+
+	// By is what requests are counted against: the API key (the client IP without one),
+	// the namespace of the entry key (the API key without one) or the client IP
+	By string
+
+	// ReadRate and WriteRate are the requests per second refilling each client's read and
+	// write token buckets, which hold up to ReadBurst and WriteBurst requests. A zero rate
+	// leaves the class unlimited.
+	ReadRate   int
+	ReadBurst  int
+	WriteRate  int
+	WriteBurst int
+
+	// DailyEntries and DailyBytes bound the entries and value bytes each client may store
+	// per UTC day; zero is unlimited
+	DailyEntries int64
+	DailyBytes   int64
+}
+
+// JWTEnabled reports whether JWT bearer tokens are accepted
+func (c *AuthConfig) JWTEnabled() bool {
+	return c.JWKSURL != "" || c.JWKSFile != ""
 }
 
 // OTELConfig holds OpenTelemetry configuration
@@ -44,11 +262,328 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid PORT: %w", err)
 	}
 
+	grpcPort, err := getEnvAsInt("GRPC_PORT", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRPC_PORT: %w", err)
+	}
+
+	respPort, err := getEnvAsInt("RESP_PORT", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESP_PORT: %w", err)
+	}
+
 	dbPort, err := getEnvAsInt("DB_PORT", 5432)
 	if err != nil {
 		return nil, fmt.Errorf("invalid DB_PORT: %w", err)
 	}
 
+	connectAttempts, err := getEnvAsInt("DB_CONNECT_ATTEMPTS", 5)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONNECT_ATTEMPTS: %w", err)
+	}
+	if connectAttempts < 1 {
+		return nil, fmt.Errorf("invalid DB_CONNECT_ATTEMPTS: must be at least 1")
+	}
+
+	connectBackoff, err := getEnvAsDuration("DB_CONNECT_BACKOFF", time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONNECT_BACKOFF: %w", err)
+	}
+
+	connectMaxBackoff, err := getEnvAsDuration("DB_CONNECT_MAX_BACKOFF", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONNECT_MAX_BACKOFF: %w", err)
+	}
+
+	degradedMode, err := getEnvAsBool("DB_DEGRADED_MODE", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_DEGRADED_MODE: %w", err)
+	}
+
+	autoMigrate, err := getEnvAsBool("DB_AUTO_MIGRATE", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_AUTO_MIGRATE: %w", err)
+	}
+
+	breakerEnabled, err := getEnvAsBool("DB_BREAKER_ENABLED", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_BREAKER_ENABLED: %w", err)
+	}
+
+	breakerThreshold, err := getEnvAsInt("DB_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_BREAKER_THRESHOLD: %w", err)
+	}
+
+	breakerOpenTimeout, err := getEnvAsDuration("DB_BREAKER_OPEN_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_BREAKER_OPEN_TIMEOUT: %w", err)
+	}
+
+	breakerCallTimeout, err := getEnvAsDuration("DB_BREAKER_CALL_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_BREAKER_CALL_TIMEOUT: %w", err)
+	}
+
+	breakerMissOnOpen, err := getEnvAsBool("DB_BREAKER_MISS_ON_OPEN", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_BREAKER_MISS_ON_OPEN: %w", err)
+	}
+
+	partitioning := getEnv("DB_PARTITIONING", "")
+	switch partitioning {
+	case "", "daily", "weekly":
+	default:
+		return nil, fmt.Errorf("invalid DB_PARTITIONING: %q", partitioning)
+	}
+
+	partitionRetention, err := getEnvAsDuration("DB_PARTITION_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_PARTITION_RETENTION: %w", err)
+	}
+
+	partitionPremake, err := getEnvAsInt("DB_PARTITION_PREMAKE", 3)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_PARTITION_PREMAKE: %w", err)
+	}
+
+	memoryMaxEntries, err := getEnvAsInt("MEMORY_MAX_ENTRIES", 10000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MEMORY_MAX_ENTRIES: %w", err)
+	}
+
+	memorySweepInterval, err := getEnvAsDuration("MEMORY_SWEEP_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MEMORY_SWEEP_INTERVAL: %w", err)
+	}
+
+	expirySweepInterval, err := getEnvAsDuration("EXPIRY_SWEEP_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EXPIRY_SWEEP_INTERVAL: %w", err)
+	}
+
+	l1Enabled, err := getEnvAsBool("L1_ENABLED", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid L1_ENABLED: %w", err)
+	}
+
+	l1MaxBytes, err := getEnvAsInt("L1_MAX_BYTES", 32<<20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid L1_MAX_BYTES: %w", err)
+	}
+
+	l1TTL, err := getEnvAsDuration("L1_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid L1_TTL: %w", err)
+	}
+
+	l1LookupTTL, err := getEnvAsDuration("L1_LOOKUP_TTL", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid L1_LOOKUP_TTL: %w", err)
+	}
+
+	compressionThreshold, err := getEnvAsInt("COMPRESSION_THRESHOLD", 4096)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMPRESSION_THRESHOLD: %w", err)
+	}
+
+	maxValueBytes, err := getEnvAsInt("MAX_VALUE_BYTES", 10<<20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_VALUE_BYTES: %w", err)
+	}
+	if maxValueBytes < 1 {
+		return nil, fmt.Errorf("invalid MAX_VALUE_BYTES: must be at least 1")
+	}
+
+	namespaceMaxValueBytes, err := getEnvAsIntMap("NAMESPACE_MAX_VALUE_BYTES")
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAMESPACE_MAX_VALUE_BYTES: %w", err)
+	}
+	for namespace, limit := range namespaceMaxValueBytes {
+		if limit < 1 {
+			return nil, fmt.Errorf("invalid NAMESPACE_MAX_VALUE_BYTES: %s must be at least 1", namespace)
+		}
+	}
+
+	valueChunkSize, err := getEnvAsInt("VALUE_CHUNK_SIZE", 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VALUE_CHUNK_SIZE: %w", err)
+	}
+	if valueChunkSize < 1 {
+		return nil, fmt.Errorf("invalid VALUE_CHUNK_SIZE: must be at least 1")
+	}
+
+	encryptMetadata, err := getEnvAsBool("ENCRYPT_METADATA", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENCRYPT_METADATA: %w", err)
+	}
+
+	backend := getEnv("STORAGE_BACKEND", "postgres")
+	switch backend {
+	case "postgres", "memory", "sqlite":
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND: %q", backend)
+	}
+
+	webhooksEnabled, err := getEnvAsBool("WEBHOOKS_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOKS_ENABLED: %w", err)
+	}
+
+	webhookMaxAttempts, err := getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %w", err)
+	}
+	if webhookMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: must be at least 1")
+	}
+
+	webhookMinBackoff, err := getEnvAsDuration("WEBHOOK_MIN_BACKOFF", time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MIN_BACKOFF: %w", err)
+	}
+
+	webhookMaxBackoff, err := getEnvAsDuration("WEBHOOK_MAX_BACKOFF", 5*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_BACKOFF: %w", err)
+	}
+
+	webhookTimeout, err := getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT: %w", err)
+	}
+
+	webhookMaxPending, err := getEnvAsInt("WEBHOOK_MAX_PENDING", 10000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_PENDING: %w", err)
+	}
+
+	webhookConcurrency, err := getEnvAsInt("WEBHOOK_CONCURRENCY", 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_CONCURRENCY: %w", err)
+	}
+	if webhookConcurrency < 1 {
+		return nil, fmt.Errorf("invalid WEBHOOK_CONCURRENCY: must be at least 1")
+	}
+
+	webhookRefreshInterval, err := getEnvAsDuration("WEBHOOK_REFRESH_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_REFRESH_INTERVAL: %w", err)
+	}
+
+	watchEnabled, err := getEnvAsBool("WATCH_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCH_ENABLED: %w", err)
+	}
+
+	watchRetention, err := getEnvAsDuration("WATCH_RETENTION", time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCH_RETENTION: %w", err)
+	}
+
+	watchPollInterval, err := getEnvAsDuration("WATCH_POLL_INTERVAL", 500*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCH_POLL_INTERVAL: %w", err)
+	}
+	if watchPollInterval <= 0 {
+		return nil, fmt.Errorf("invalid WATCH_POLL_INTERVAL: must be positive")
+	}
+
+	watchMaxPending, err := getEnvAsInt("WATCH_MAX_PENDING", 10000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCH_MAX_PENDING: %w", err)
+	}
+
+	authEnabled, err := getEnvAsBool("AUTH_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_ENABLED: %w", err)
+	}
+
+	authCacheTTL, err := getEnvAsDuration("AUTH_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_CACHE_TTL: %w", err)
+	}
+
+	jwksURL := getEnv("AUTH_JWKS_URL", "")
+	jwksFile := getEnv("AUTH_JWKS_FILE", "")
+	if jwksURL != "" && jwksFile != "" {
+		return nil, fmt.Errorf("invalid AUTH_JWKS_FILE: cannot be set with AUTH_JWKS_URL")
+	}
+	if jwksURL != "" && !strings.HasPrefix(jwksURL, "https://") && !strings.HasPrefix(jwksURL, "http://") {
+		return nil, fmt.Errorf("invalid AUTH_JWKS_URL: must be an http or https URL")
+	}
+	jwtIssuer := getEnv("AUTH_JWT_ISSUER", "")
+	jwtAudience := getEnv("AUTH_JWT_AUDIENCE", "")
+	if jwksURL != "" || jwksFile != "" {
+		if !authEnabled {
+			return nil, fmt.Errorf("invalid AUTH_JWKS_URL or AUTH_JWKS_FILE: requires AUTH_ENABLED")
+		}
+		if jwtIssuer == "" || jwtAudience == "" {
+			return nil, fmt.Errorf("invalid AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE: both are required with a JWKS")
+		}
+	}
+
+	jwksRefresh, err := getEnvAsDuration("AUTH_JWKS_REFRESH", time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_JWKS_REFRESH: %w", err)
+	}
+
+	jwtNamespaceMap, err := getEnvAsStringMap("AUTH_JWT_NAMESPACE_MAP")
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_JWT_NAMESPACE_MAP: %w", err)
+	}
+
+	jwtLeeway, err := getEnvAsDuration("AUTH_JWT_LEEWAY", time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_JWT_LEEWAY: %w", err)
+	}
+
+	rateLimitEnabled, err := getEnvAsBool("RATE_LIMIT_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ENABLED: %w", err)
+	}
+
+	rateLimitBy := getEnv("RATE_LIMIT_BY", RateLimitByAPIKey)
+	if rateLimitBy != RateLimitByAPIKey && rateLimitBy != RateLimitByNamespace && rateLimitBy != RateLimitByIP {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BY: %q", rateLimitBy)
+	}
+
+	readRate, err := getEnvAsInt("RATE_LIMIT_READ_RPS", 100)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_READ_RPS: %w", err)
+	}
+
+	readBurst, err := getEnvAsInt("RATE_LIMIT_READ_BURST", 2*readRate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_READ_BURST: %w", err)
+	}
+	if readRate > 0 && readBurst < 1 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_READ_BURST: must be at least 1")
+	}
+
+	writeRate, err := getEnvAsInt("RATE_LIMIT_WRITE_RPS", 20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_WRITE_RPS: %w", err)
+	}
+
+	writeBurst, err := getEnvAsInt("RATE_LIMIT_WRITE_BURST", 2*writeRate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_WRITE_BURST: %w", err)
+	}
+	if writeRate > 0 && writeBurst < 1 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_WRITE_BURST: must be at least 1")
+	}
+
+	quotaEntries, err := getEnvAsInt("QUOTA_DAILY_ENTRIES", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTA_DAILY_ENTRIES: %w", err)
+	}
+
+	quotaBytes, err := getEnvAsInt("QUOTA_DAILY_BYTES", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTA_DAILY_BYTES: %w", err)
+	}
+
 	otelEnabled, err := getEnvAsBool("OTEL_ENABLED", true)
 	if err != nil {
 		return nil, fmt.Errorf("invalid OTEL_ENABLED: %w", err)
@@ -56,8 +591,11 @@ func Load() (*Config, error) {
 
 	return &Config{
 		Server: ServerConfig{
-			Port:      port,
-			CommitSHA: getEnv("COMMIT_SHA", "unknown"),
+			Port:         port,
+			CommitSHA:    getEnv("COMMIT_SHA", "unknown"),
+			GRPCPort:     grpcPort,
+			RESPPort:     respPort,
+			RESPPassword: getEnv("RESP_PASSWORD", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -66,6 +604,83 @@ func Load() (*Config, error) {
 			Password: getEnv("DB_PASSWORD", "postgres"),
 			Database: getEnv("DB_NAME", "itemsdb"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+			ReadReplicas:       getEnvAsList("DB_READ_REPLICAS"),
+			ConnectAttempts:    connectAttempts,
+			ConnectBackoff:     connectBackoff,
+			ConnectMaxBackoff:  connectMaxBackoff,
+			DegradedMode:       degradedMode,
+			AutoMigrate:        autoMigrate,
+			BreakerEnabled:     breakerEnabled,
+			BreakerThreshold:   breakerThreshold,
+			BreakerOpenTimeout: breakerOpenTimeout,
+			BreakerCallTimeout: breakerCallTimeout,
+			BreakerMissOnOpen:  breakerMissOnOpen,
+			Partitioning:       partitioning,
+			PartitionRetention: partitionRetention,
+			PartitionPremake:   partitionPremake,
+		},
+		Storage: StorageConfig{
+			Backend:             backend,
+			MemoryMaxEntries:    memoryMaxEntries,
+			MemorySweepInterval: memorySweepInterval,
+			SQLitePath:          getEnv("SQLITE_PATH", "semcache.db"),
+			ExpirySweepInterval: expirySweepInterval,
+			MaxValueBytes:       maxValueBytes,
+			ValueChunkSize:      valueChunkSize,
+			L1Enabled:           l1Enabled,
+			L1MaxBytes:          l1MaxBytes,
+			L1TTL:               l1TTL,
+			L1LookupTTL:         l1LookupTTL,
+
+			NamespaceMaxValueBytes: namespaceMaxValueBytes,
+
+			CompressionThreshold: compressionThreshold,
+			EncryptionKeys:       getEnv("ENCRYPTION_KEYS", ""),
+			EncryptionKeysFile:   getEnv("ENCRYPTION_KEYS_FILE", ""),
+			EncryptionPrimaryKey: getEnv("ENCRYPTION_PRIMARY_KEY", ""),
+			EncryptMetadata:      encryptMetadata,
+		},
+		Webhooks: WebhookConfig{
+			Enabled:         webhooksEnabled,
+			MaxAttempts:     webhookMaxAttempts,
+			MinBackoff:      webhookMinBackoff,
+			MaxBackoff:      webhookMaxBackoff,
+			Timeout:         webhookTimeout,
+			MaxPending:      webhookMaxPending,
+			Concurrency:     webhookConcurrency,
+			RefreshInterval: webhookRefreshInterval,
+		},
+		Watch: WatchConfig{
+			Enabled:      watchEnabled,
+			Retention:    watchRetention,
+			PollInterval: watchPollInterval,
+			MaxPending:   watchMaxPending,
+		},
+		Auth: AuthConfig{
+			Enabled:           authEnabled,
+			AdminKey:          getEnv("AUTH_ADMIN_KEY", ""),
+			CacheTTL:          authCacheTTL,
+			JWKSURL:           jwksURL,
+			JWKSFile:          jwksFile,
+			JWKSRefresh:       jwksRefresh,
+			JWTIssuer:         jwtIssuer,
+			JWTAudience:       jwtAudience,
+			JWTScopeClaim:     getEnv("AUTH_JWT_SCOPE_CLAIM", "scope"),
+			JWTScopePrefix:    getEnv("AUTH_JWT_SCOPE_PREFIX", ""),
+			JWTNamespaceClaim: getEnv("AUTH_JWT_NAMESPACE_CLAIM", ""),
+			JWTNamespaceMap:   jwtNamespaceMap,
+			JWTLeeway:         jwtLeeway,
+		},
+		Limits: RateLimitConfig{
+			Enabled:      rateLimitEnabled,
+			By:           rateLimitBy,
+			ReadRate:     readRate,
+			ReadBurst:    readBurst,
+			WriteRate:    writeRate,
+			WriteBurst:   writeBurst,
+			DailyEntries: int64(quotaEntries),
+			DailyBytes:   int64(quotaBytes),
 		},
 		OTEL: OTELConfig{
 			Enabled:     otelEnabled,
@@ -110,6 +725,60 @@ func getEnvAsBool(key string, defaultValue bool) (bool, error) {
 	return value, nil
 }
 
+// getEnvAsDuration gets an environment variable as a time.Duration or returns a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list, skipping empty items
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsIntMap parses a comma-separated list of name=integer pairs
+func getEnvAsIntMap(key string) (map[string]int, error) {
+	values := make(map[string]int)
+	for _, item := range getEnvAsList(key) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=value, got %q", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values[strings.TrimSpace(name)] = n
+	}
+	return values, nil
+}
+
+// getEnvAsStringMap parses a comma-separated list of name=value pairs
+func getEnvAsStringMap(key string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, item := range getEnvAsList(key) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=value, got %q", item)
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
 // ConnectionString returns the PostgreSQL connection string
 func (c *DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf(
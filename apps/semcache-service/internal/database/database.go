@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -11,13 +12,25 @@ import (
 	"github.com/nextinterfaces/semcache-service/internal/logger"
 )
 
-// DB wraps the database connection
+// monitorInterval is how often a ready database is pinged to detect outages
+const monitorInterval = 5 * time.Second
+
+// DB wraps the database connection to the primary and any read replicas
 type DB struct {
 	*sql.DB
+
+	cfg         *config.DatabaseConfig
+	replicas    []*replica
+	nextReplica atomic.Uint32
+	stop        chan struct{}
+
+	// ready is true while the primary answers pings and startup preparation has completed
+	ready    atomic.Bool
+	prepared atomic.Bool
 }
 
-// New creates a new database connection
-func New(cfg *config.DatabaseConfig) (*DB, error) {
+// Open creates the connection pools without contacting the database
+func Open(cfg *config.DatabaseConfig) (*DB, error) {
 	connStr := cfg.ConnectionString()
 
 	db, err := sql.Open("postgres", connStr)
@@ -30,52 +43,157 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	// Test the connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	replicas, err := openReplicas(cfg.ReadReplicas)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	logger.Logger.Info(fmt.Sprintf("Connected to database: %s:%d/%s", cfg.Host, cfg.Port, cfg.Database))
+	d := &DB{DB: db, cfg: cfg, replicas: replicas, stop: make(chan struct{})}
+	if len(replicas) > 0 {
+		go d.checkReplicas(d.stop)
+	}
 
-	return &DB{db}, nil
+	return d, nil
 }
 
-// InitSchema initializes the database schema
-func (db *DB) InitSchema(ctx context.Context) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS semcache (
-			id SERIAL PRIMARY KEY,
-			key VARCHAR(255) NOT NULL,
-			value TEXT NOT NULL,
-			metadata TEXT,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			expires_at TIMESTAMP,
-			CONSTRAINT unique_key UNIQUE (key)
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_semcache_key ON semcache(key);
-		CREATE INDEX IF NOT EXISTS idx_semcache_expires_at ON semcache(expires_at);
-		CREATE INDEX IF NOT EXISTS idx_semcache_metadata ON semcache(metadata);
-	`
-
-	_, err := db.ExecContext(ctx, query)
+// New creates a new database connection, retrying the initial ping with backoff
+func New(cfg *config.DatabaseConfig) (*DB, error) {
+	db, err := Open(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return nil, err
+	}
+
+	if err := db.connect(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.prepared.Store(true)
+	db.ready.Store(true)
+
+	return db, nil
+}
+
+// Start connects to the database with retries and runs prepare (migrations and the like)
+// once it is reachable. Whatever the outcome, a background monitor keeps retrying the
+// connection and prepare, and tracks readiness until Close. The returned error reports
+// whether the database was ready at startup; callers may choose to run degraded instead.
+func (db *DB) Start(ctx context.Context, prepare func(ctx context.Context) error) error {
+	err := db.connect(ctx)
+	if err == nil {
+		err = db.runPrepare(ctx, prepare)
+	}
+
+	go db.monitor(prepare)
+
+	return err
+}
+
+// Ready reports whether the database is reachable and prepared
+func (db *DB) Ready() bool {
+	return db.ready.Load()
+}
+
+// connect pings the primary until it answers, backing off exponentially between attempts
+func (db *DB) connect(ctx context.Context) error {
+	backoff := db.cfg.ConnectBackoff
+	var err error
+
+	for attempt := 1; attempt <= db.cfg.ConnectAttempts; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			logger.Logger.Info(fmt.Sprintf("Connected to database: %s:%d/%s", db.cfg.Host, db.cfg.Port, db.cfg.Database))
+			return nil
+		}
+
+		if attempt == db.cfg.ConnectAttempts {
+			break
+		}
+		logger.Logger.Warn(fmt.Sprintf("Database not reachable (attempt %d/%d), retrying in %s: %v",
+			attempt, db.cfg.ConnectAttempts, backoff, err))
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ping database: %w", ctx.Err())
+		}
+		backoff = nextBackoff(backoff, db.cfg.ConnectMaxBackoff)
+	}
+
+	return fmt.Errorf("failed to ping database after %d attempts: %w", db.cfg.ConnectAttempts, err)
+}
+
+// runPrepare runs prepare unless it already succeeded, and marks the database ready
+func (db *DB) runPrepare(ctx context.Context, prepare func(ctx context.Context) error) error {
+	if !db.prepared.Load() {
+		if err := prepare(ctx); err != nil {
+			return err
+		}
+		db.prepared.Store(true)
 	}
 
-	logger.Logger.Info(fmt.Sprintf("Database schema initialized"))
+	db.ready.Store(true)
 	return nil
 }
 
+// monitor reconnects and prepares a database that is down, and notices when a ready one goes away
+func (db *DB) monitor(prepare func(ctx context.Context) error) {
+	backoff := db.cfg.ConnectBackoff
+
+	for {
+		wait := monitorInterval
+		if !db.ready.Load() {
+			wait = backoff
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-db.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := db.PingContext(ctx)
+		if err == nil && !db.ready.Load() {
+			err = db.runPrepare(ctx, prepare)
+			if err == nil {
+				logger.Logger.Info(fmt.Sprintf("Database is available again, leaving degraded mode"))
+			}
+		}
+		cancel()
+
+		if err != nil {
+			if db.ready.Swap(false) {
+				logger.Logger.Warn(fmt.Sprintf("Database unavailable, entering degraded mode: %v", err))
+			}
+			backoff = nextBackoff(backoff, db.cfg.ConnectMaxBackoff)
+			continue
+		}
+		backoff = db.cfg.ConnectBackoff
+	}
+}
+
+// nextBackoff doubles backoff up to max
+func nextBackoff(backoff, max time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
 // HealthCheck checks if the database is healthy
 func (db *DB) HealthCheck(ctx context.Context) error {
 	return db.PingContext(ctx)
 }
 
-// Close closes the database connection
+// Close closes the primary and replica connections
 func (db *DB) Close() error {
+	close(db.stop)
+	for _, r := range db.replicas {
+		r.db.Close()
+	}
 	return db.DB.Close()
 }
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// ChangeListener receives cache change events published by other replicas over LISTEN/NOTIFY
+type ChangeListener struct {
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+// ListenChanges subscribes to models.ChangeChannel on a dedicated connection. onChange is called
+// for every event published by another replica. Notifications sent while the connection is down
+// are lost, so onResync is called after every (re)connect to let callers drop what they cached.
+func ListenChanges(cfg *config.DatabaseConfig, onChange func(models.ChangeEvent), onResync func()) *ChangeListener {
+	listener := pq.NewListener(cfg.ConnectionString(), time.Second, 30*time.Second,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventConnected:
+				logger.Logger.Info(fmt.Sprintf("Change listener connected, listening on %q", models.ChangeChannel))
+			case pq.ListenerEventDisconnected:
+				logger.Logger.Warn(fmt.Sprintf("Change listener disconnected: %v", err))
+			case pq.ListenerEventReconnected:
+				logger.Logger.Info(fmt.Sprintf("Change listener reconnected"))
+			case pq.ListenerEventConnectionAttemptFailed:
+				logger.Logger.Warn(fmt.Sprintf("Change listener connection attempt failed: %v", err))
+			}
+		},
+	)
+
+	l := &ChangeListener{
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go l.run(onChange, onResync)
+
+	return l
+}
+
+// run dispatches notifications until Close is called
+func (l *ChangeListener) run(onChange func(models.ChangeEvent), onResync func()) {
+	defer close(l.done)
+
+	// Listen blocks until the first connection succeeds; the listener re-issues it on reconnect
+	if err := l.listener.Listen(models.ChangeChannel); err != nil {
+		logger.Logger.Error(fmt.Sprintf("Failed to listen on %q: %v", models.ChangeChannel, err))
+		return
+	}
+	onResync()
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification signals a reconnect, after which events may have been missed
+			if n == nil {
+				onResync()
+				continue
+			}
+
+			var event models.ChangeEvent
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				logger.Logger.Warn(fmt.Sprintf("Ignoring malformed change notification: %v", err))
+				continue
+			}
+			if event.Origin == models.InstanceID() {
+				continue
+			}
+			onChange(event)
+		case <-ping.C:
+			// Detect half-open connections that would otherwise never deliver again
+			go l.listener.Ping()
+		}
+	}
+}
+
+// Close stops listening and closes the dedicated connection
+func (l *ChangeListener) Close() error {
+	err := l.listener.Close()
+	<-l.done
+	return err
+}
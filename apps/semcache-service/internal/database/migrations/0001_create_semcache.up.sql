@@ -0,0 +1,14 @@
+-- IF NOT EXISTS keeps this safe on databases created before versioned migrations
+CREATE TABLE IF NOT EXISTS semcache (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(255) NOT NULL,
+    value TEXT NOT NULL,
+    metadata TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,
+    CONSTRAINT unique_key UNIQUE (key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_semcache_key ON semcache(key);
+CREATE INDEX IF NOT EXISTS idx_semcache_expires_at ON semcache(expires_at);
+CREATE INDEX IF NOT EXISTS idx_semcache_metadata ON semcache(metadata);
@@ -0,0 +1 @@
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS embedding REAL[];
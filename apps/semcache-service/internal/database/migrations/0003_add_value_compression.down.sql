@@ -0,0 +1,2 @@
+ALTER TABLE semcache DROP COLUMN IF EXISTS value_compressed;
+ALTER TABLE semcache DROP COLUMN IF EXISTS value_codec;
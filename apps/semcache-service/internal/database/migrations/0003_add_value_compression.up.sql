@@ -0,0 +1,3 @@
+-- value_compressed holds the encoded value (and value is empty) when value_codec is set
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS value_codec VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS value_compressed BYTEA;
@@ -0,0 +1,4 @@
+ALTER TABLE semcache DROP COLUMN IF EXISTS metadata_encrypted;
+ALTER TABLE semcache DROP COLUMN IF EXISTS encrypted_data_key;
+ALTER TABLE semcache DROP COLUMN IF EXISTS encryption_key_id;
+ALTER TABLE semcache RENAME COLUMN value_data TO value_compressed;
@@ -0,0 +1,7 @@
+-- value_data holds the encoded value (compressed and/or encrypted) when value_codec is set
+ALTER TABLE semcache RENAME COLUMN value_compressed TO value_data;
+-- encrypted_data_key is the per-row data key wrapped by master key encryption_key_id
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS encryption_key_id VARCHAR(64);
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS encrypted_data_key BYTEA;
+-- metadata_encrypted holds the encrypted metadata (and metadata is empty) when set
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS metadata_encrypted BYTEA;
@@ -0,0 +1 @@
+ALTER TABLE semcache DROP COLUMN IF EXISTS content_type;
@@ -0,0 +1 @@
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS content_type VARCHAR(255) NOT NULL DEFAULT '';
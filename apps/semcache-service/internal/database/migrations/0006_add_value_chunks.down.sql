@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS semcache_chunks;
+ALTER TABLE semcache DROP COLUMN IF EXISTS value_size;
+ALTER TABLE semcache DROP COLUMN IF EXISTS value_chunks;
@@ -0,0 +1,12 @@
+-- value_chunks > 0 means the value is split across that many rows of semcache_chunks
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS value_chunks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE semcache ADD COLUMN IF NOT EXISTS value_size BIGINT NOT NULL DEFAULT 0;
+
+-- Chunks are encoded individually, each with its own codec; entry_id is semcache.id
+CREATE TABLE IF NOT EXISTS semcache_chunks (
+    entry_id INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    codec VARCHAR(16) NOT NULL DEFAULT '',
+    data BYTEA NOT NULL,
+    PRIMARY KEY (entry_id, seq)
+);
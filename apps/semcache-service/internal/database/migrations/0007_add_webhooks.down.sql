@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS semcache_webhook_dead_letters;
+DROP TABLE IF EXISTS semcache_webhooks;
@@ -0,0 +1,26 @@
+-- Endpoints notified of entry changes; events lists the delivered event types, empty for all
+CREATE TABLE IF NOT EXISTS semcache_webhooks (
+    id SERIAL PRIMARY KEY,
+    namespace VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Deliveries given up on after exhausting their retries
+CREATE TABLE IF NOT EXISTS semcache_webhook_dead_letters (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES semcache_webhooks(id) ON DELETE CASCADE,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_status INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_semcache_webhook_dead_letters_webhook_id
+    ON semcache_webhook_dead_letters(webhook_id, id);
@@ -0,0 +1,9 @@
+-- Entry events in the order they happened; watchers resume from a seq they have seen
+CREATE TABLE IF NOT EXISTS semcache_changes (
+    seq BIGSERIAL PRIMARY KEY,
+    type VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_semcache_changes_created_at ON semcache_changes(created_at);
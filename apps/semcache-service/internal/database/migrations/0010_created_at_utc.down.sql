@@ -0,0 +1 @@
+ALTER TABLE semcache ALTER COLUMN created_at SET DEFAULT NOW();
@@ -0,0 +1,4 @@
+-- created_at is a TIMESTAMP and the partition key, and partition bounds are UTC dates, so
+-- record it in UTC whatever the session time zone, as the repository does for expires_at.
+-- Existing rows keep their values.
+ALTER TABLE semcache ALTER COLUMN created_at SET DEFAULT (NOW() AT TIME ZONE 'UTC');
@@ -0,0 +1,333 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+)
+
+// partitionLockID is the pg_advisory_lock key held while converting or maintaining partitions
+const partitionLockID = 0x73656d7061727473 // "semparts"
+
+const (
+	partitionPrefix = "semcache_p"       // semcache_pYYYYMMDD covers one interval from that date
+	legacyPrefix    = "semcache_before_" // semcache_before_YYYYMMDD holds rows from before partitioning
+	partitionLayout = "20060102"
+)
+
+// PartitionManager keeps semcache range-partitioned by created_at: it converts the table on
+// first use, creates upcoming partitions ahead of time and drops partitions past retention.
+type PartitionManager struct {
+	db        *DB
+	interval  string // "daily" or "weekly"
+	retention time.Duration
+	premake   int
+
+	// mu guards dropped and dropPending, set when partitions were dropped before dropped
+	mu          sync.Mutex
+	dropped     func(context.Context)
+	dropPending bool
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewPartitionManager creates a manager for the partitioning settings in cfg
+func NewPartitionManager(db *DB, cfg *config.DatabaseConfig) *PartitionManager {
+	return &PartitionManager{
+		db:        db,
+		interval:  cfg.Partitioning,
+		retention: cfg.PartitionRetention,
+		premake:   cfg.PartitionPremake,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// UseDropped calls fn after partitions are dropped, since their entries are removed
+// without going through the store. If partitions were dropped before fn was set, fn is
+// called straight away.
+func (pm *PartitionManager) UseDropped(fn func(ctx context.Context)) {
+	pm.mu.Lock()
+	pm.dropped = fn
+	pending := pm.dropPending
+	pm.dropPending = false
+	pm.mu.Unlock()
+
+	if pending {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		fn(ctx)
+	}
+}
+
+// notifyDropped reports dropped partitions to the function set by UseDropped, or records
+// them until one is set
+func (pm *PartitionManager) notifyDropped(ctx context.Context) {
+	pm.mu.Lock()
+	fn := pm.dropped
+	if fn == nil {
+		pm.dropPending = true
+	}
+	pm.mu.Unlock()
+
+	if fn != nil {
+		fn(ctx)
+	}
+}
+
+// Start runs maintenance once and then every checkInterval until Close is called.
+// If the first run fails nothing is started and Start may be called again.
+func (pm *PartitionManager) Start(ctx context.Context, checkInterval time.Duration) error {
+	if err := pm.Maintain(ctx); err != nil {
+		return err
+	}
+
+	pm.started.Store(true)
+	go func() {
+		defer close(pm.done)
+
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				if err := pm.Maintain(ctx); err != nil {
+					logger.Logger.Warn(fmt.Sprintf("Partition maintenance failed: %v", err))
+				}
+				cancel()
+			case <-pm.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops background maintenance
+func (pm *PartitionManager) Close() error {
+	select {
+	case <-pm.stop:
+	default:
+		close(pm.stop)
+	}
+	if pm.started.Load() {
+		<-pm.done
+	}
+	return nil
+}
+
+// Maintain converts semcache to a partitioned table if needed, creates the current and
+// upcoming partitions and drops expired ones. Only one replica does this at a time.
+func (pm *PartitionManager) Maintain(ctx context.Context) error {
+	conn, err := pm.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", partitionLockID).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire partition lock: %w", err)
+	}
+	if !locked {
+		// Another replica is already maintaining partitions
+		return nil
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", partitionLockID); err != nil {
+			logger.Logger.Warn(fmt.Sprintf("Failed to release partition lock: %v", err))
+		}
+	}()
+
+	// Bounds are UTC, like created_at, which defaults to NOW() AT TIME ZONE 'UTC'
+	now := time.Now().UTC()
+	start := pm.periodStart(now)
+
+	partitioned, err := isPartitioned(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		if err := convertToPartitioned(ctx, conn, start); err != nil {
+			return fmt.Errorf("failed to partition semcache: %w", err)
+		}
+		logger.Logger.Info(fmt.Sprintf("Converted semcache to a %s partitioned table", pm.interval))
+	}
+
+	for i := 0; i <= pm.premake; i++ {
+		from := pm.addPeriods(start, i)
+		to := pm.addPeriods(from, 1)
+		name := partitionPrefix + from.Format(partitionLayout)
+		query := fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s PARTITION OF semcache FOR VALUES FROM ('%s') TO ('%s')",
+			name, from.Format(time.DateTime), to.Format(time.DateTime),
+		)
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+	}
+
+	if pm.retention > 0 {
+		if err := pm.dropExpired(ctx, conn, now.Add(-pm.retention)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropExpired drops every partition whose whole range ends before horizon
+func (pm *PartitionManager) dropExpired(ctx context.Context, conn *sql.Conn, horizon time.Time) error {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'semcache'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	var expired []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan partition: %w", err)
+		}
+		if end, ok := pm.partitionEnd(name); ok && !end.After(horizon) {
+			expired = append(expired, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating partitions: %w", err)
+	}
+
+	for i, name := range expired {
+		if _, err := conn.ExecContext(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+			if i > 0 {
+				pm.notifyDropped(ctx)
+			}
+			return fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+		logger.Logger.Info(fmt.Sprintf("Dropped expired partition %s", name))
+	}
+	if len(expired) > 0 {
+		pm.notifyDropped(ctx)
+	}
+
+	// Chunks cannot reference a partitioned table, so remove those left by dropped entries
+	if len(expired) > 0 {
+		res, err := conn.ExecContext(ctx, `
+			DELETE FROM semcache_chunks c
+			WHERE NOT EXISTS (SELECT 1 FROM semcache s WHERE s.id = c.entry_id)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to delete orphaned chunks: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			logger.Logger.Info(fmt.Sprintf("Deleted %d chunks of dropped entries", n))
+		}
+	}
+
+	return nil
+}
+
+// partitionEnd returns the exclusive upper bound encoded in a partition name
+func (pm *PartitionManager) partitionEnd(name string) (time.Time, bool) {
+	switch {
+	case strings.HasPrefix(name, legacyPrefix):
+		end, err := time.Parse(partitionLayout, strings.TrimPrefix(name, legacyPrefix))
+		return end, err == nil
+	case strings.HasPrefix(name, partitionPrefix):
+		start, err := time.Parse(partitionLayout, strings.TrimPrefix(name, partitionPrefix))
+		return pm.addPeriods(start, 1), err == nil
+	}
+	return time.Time{}, false
+}
+
+// periodStart truncates t to the start of its day, or of its ISO week (Monday) when weekly
+func (pm *PartitionManager) periodStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if pm.interval == "weekly" {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// addPeriods moves t forward by n partition intervals
+func (pm *PartitionManager) addPeriods(t time.Time, n int) time.Time {
+	if pm.interval == "weekly" {
+		return t.AddDate(0, 0, 7*n)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// isPartitioned reports whether semcache is already a partitioned table
+func isPartitioned(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var relkind string
+	err := conn.QueryRowContext(ctx,
+		"SELECT relkind FROM pg_class WHERE oid = 'semcache'::regclass",
+	).Scan(&relkind)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect semcache table: %w", err)
+	}
+	return relkind == "p", nil
+}
+
+// convertToPartitioned swaps the plain semcache table for a partitioned one. The existing
+// table is kept, without copying rows, as the partition holding everything before start,
+// so it is dropped by retention like any other partition once start falls past the horizon.
+//
+// Partitioned tables cannot carry UNIQUE (key), so key uniqueness is enforced by
+// CacheRepository.Create with per-key advisory locks instead.
+func convertToPartitioned(ctx context.Context, conn *sql.Conn, start time.Time) error {
+	legacy := legacyPrefix + start.Format(partitionLayout)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"LOCK TABLE semcache IN ACCESS EXCLUSIVE MODE",
+		"ALTER TABLE semcache RENAME TO " + legacy,
+		"ALTER TABLE " + legacy + " DROP CONSTRAINT semcache_pkey",
+		"ALTER TABLE " + legacy + " ALTER COLUMN id SET NOT NULL",
+		"ALTER INDEX IF EXISTS idx_semcache_key RENAME TO " + legacy + "_key_idx",
+		"ALTER INDEX IF EXISTS idx_semcache_expires_at RENAME TO " + legacy + "_expires_at_idx",
+		"ALTER INDEX IF EXISTS idx_semcache_metadata RENAME TO " + legacy + "_metadata_idx",
+		// LIKE keeps every column added by migrations, including the id sequence default
+		"CREATE TABLE semcache (LIKE " + legacy + " INCLUDING DEFAULTS, PRIMARY KEY (id, created_at)) PARTITION BY RANGE (created_at)",
+		"ALTER SEQUENCE semcache_id_seq OWNED BY semcache.id",
+		"CREATE INDEX idx_semcache_key ON semcache(key)",
+		"CREATE INDEX idx_semcache_expires_at ON semcache(expires_at)",
+		"CREATE INDEX idx_semcache_metadata ON semcache(metadata)",
+		fmt.Sprintf("ALTER TABLE semcache ATTACH PARTITION %s FOR VALUES FROM (MINVALUE) TO ('%s')",
+			legacy, start.Format(time.DateTime)),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: %w", strings.SplitN(strings.TrimSpace(stmt), "\n", 2)[0], err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+)
+
+// TestUseDropped checks that dropped partitions are reported whether they are dropped
+// before or after the function is set, and only once
+func TestUseDropped(t *testing.T) {
+	pm := NewPartitionManager(nil, &config.DatabaseConfig{Partitioning: "daily"})
+	ctx := context.Background()
+
+	// Dropped at startup, before the caller could set a function
+	pm.notifyDropped(ctx)
+
+	calls := 0
+	pm.UseDropped(func(context.Context) { calls++ })
+	if calls != 1 {
+		t.Fatalf("calls after UseDropped = %d, want 1 for the earlier drop", calls)
+	}
+
+	pm.notifyDropped(ctx)
+	if calls != 2 {
+		t.Fatalf("calls after a later drop = %d, want 2", calls)
+	}
+
+	pm.UseDropped(func(context.Context) { calls++ })
+	if calls != 2 {
+		t.Fatalf("calls after setting the function again = %d, want 2", calls)
+	}
+}
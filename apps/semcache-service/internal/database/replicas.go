@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+)
+
+// replicaCheckInterval is how often read replicas are pinged to update their health
+const replicaCheckInterval = 5 * time.Second
+
+// replica is a read-only connection pool with its last known health
+type replica struct {
+	db      *sql.DB
+	name    string
+	healthy atomic.Bool
+}
+
+// openReplicas opens a pool per read-replica DSN. Replicas that cannot be reached yet are
+// kept and marked unhealthy so the health loop can bring them in later.
+func openReplicas(dsns []string) ([]*replica, error) {
+	var replicas []*replica
+	for i, dsn := range dsns {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			for _, r := range replicas {
+				r.db.Close()
+			}
+			return nil, fmt.Errorf("failed to open read replica %d: %w", i, err)
+		}
+
+		db.SetMaxOpenConns(25)
+		db.SetMaxIdleConns(5)
+		db.SetConnMaxLifetime(5 * time.Minute)
+
+		r := &replica{db: db, name: fmt.Sprintf("replica-%d", i)}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+		if err != nil {
+			logger.Logger.Warn(fmt.Sprintf("Read %s unavailable, reads will use the primary: %v", r.name, err))
+		} else {
+			r.healthy.Store(true)
+			logger.Logger.Info(fmt.Sprintf("Connected to read %s", r.name))
+		}
+
+		replicas = append(replicas, r)
+	}
+
+	return replicas, nil
+}
+
+// Reader returns a healthy read replica, rotating between them, or the primary when none is healthy
+func (db *DB) Reader() *sql.DB {
+	n := len(db.replicas)
+	if n == 0 {
+		return db.DB
+	}
+
+	start := int(db.nextReplica.Add(1))
+	for i := 0; i < n; i++ {
+		r := db.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return db.DB
+}
+
+// checkReplicas pings every replica on an interval and flips its health, until stop is closed
+func (db *DB) checkReplicas(stop <-chan struct{}) {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, r := range db.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				err := r.db.PingContext(ctx)
+				cancel()
+
+				healthy := err == nil
+				if r.healthy.Swap(healthy) != healthy {
+					if healthy {
+						logger.Logger.Info(fmt.Sprintf("Read %s is healthy again", r.name))
+					} else {
+						logger.Logger.Warn(fmt.Sprintf("Read %s is unhealthy, failing over to the primary: %v", r.name, err))
+					}
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dataKeySize is the size of the per-value AES-256 data keys
+const dataKeySize = 32
+
+// ErrUnknownKey is returned when data was wrapped by a master key that is not in the keyring
+var ErrUnknownKey = errors.New("unknown master key id")
+
+// Keyring holds the master keys used to wrap data keys. All keys can unwrap; only the
+// primary key wraps new data keys, so rotating means adding a key and making it primary.
+type Keyring struct {
+	keys    map[string]cipher.AEAD
+	primary string
+}
+
+// ParseKeyring parses master keys given as comma- or newline-separated "id:base64key"
+// pairs. Keys must decode to 16, 24 or 32 bytes. primary names the key used for new data;
+// when empty the last key listed is primary.
+func ParseKeyring(spec, primary string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	var last string
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid master key entry: expected id:base64key")
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate master key id %q", id)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+
+		k.keys[id] = aead
+		last = id
+	}
+
+	if len(k.keys) == 0 {
+		return nil, errors.New("no master keys configured")
+	}
+
+	if primary == "" {
+		primary = last
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("primary master key %q is not configured", primary)
+	}
+	k.primary = primary
+
+	return k, nil
+}
+
+// LoadKeyring reads the keyring from path if set, otherwise from spec
+func LoadKeyring(spec, path, primary string) (*Keyring, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master keys: %w", err)
+		}
+		spec = string(data)
+	}
+	return ParseKeyring(spec, primary)
+}
+
+// PrimaryID returns the id of the master key that wraps new data keys
+func (k *Keyring) PrimaryID() string {
+	return k.primary
+}
+
+// NewDataKey generates a random data key and returns it along with its wrapped form and
+// the id of the master key that wrapped it
+func (k *Keyring) NewDataKey() (dataKey, wrapped []byte, keyID string, err error) {
+	dataKey = make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, "", err
+	}
+
+	wrapped, err = seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return dataKey, wrapped, k.primary, nil
+}
+
+// UnwrapDataKey recovers a data key wrapped by the master key keyID
+func (k *Keyring) UnwrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// Rewrap re-wraps a data key under the primary master key without touching the data it protects
+func (k *Keyring) Rewrap(keyID string, wrapped []byte) ([]byte, string, error) {
+	dataKey, err := k.UnwrapDataKey(keyID, wrapped)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rewrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rewrapped, k.primary, nil
+}
+
+// Seal encrypts plaintext with a data key. aad is authenticated but not encrypted; binding
+// it to the row key stops ciphertexts from being swapped between rows.
+func Seal(dataKey, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return seal(aead, plaintext, aad)
+}
+
+// Open decrypts ciphertext produced by Seal with the same data key and aad
+func Open(dataKey, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, ciphertext, aad)
+}
+
+// newAEAD creates an AES-GCM cipher for key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext under a random nonce and returns nonce || ciphertext
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open splits nonce || ciphertext and decrypts it
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,177 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// testKey returns a base64 master key of size bytes, all set to b
+func testKey(b byte, size int) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, size))
+}
+
+func TestSealOpen(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	otherKey := bytes.Repeat([]byte{8}, dataKeySize)
+	sealed, err := Seal(dataKey, []byte("secret"), []byte("value:k"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	flip := func(i int) []byte {
+		tampered := bytes.Clone(sealed)
+		tampered[i] ^= 1
+		return tampered
+	}
+
+	tests := []struct {
+		name       string
+		dataKey    []byte
+		ciphertext []byte
+		aad        string
+		ok         bool
+	}{
+		{"round trip", dataKey, sealed, "value:k", true},
+		{"other aad", dataKey, sealed, "value:other", false},
+		{"other data key", otherKey, sealed, "value:k", false},
+		{"tampered nonce", dataKey, flip(0), "value:k", false},
+		{"tampered ciphertext", dataKey, flip(len(sealed) / 2), "value:k", false},
+		{"tampered tag", dataKey, flip(len(sealed) - 1), "value:k", false},
+		{"truncated", dataKey, sealed[:8], "value:k", false},
+		{"empty", dataKey, nil, "value:k", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := Open(tt.dataKey, tt.ciphertext, []byte(tt.aad))
+			if !tt.ok {
+				if err == nil {
+					t.Fatalf("Open succeeded, want an error")
+				}
+				return
+			}
+			if err != nil || string(plaintext) != "secret" {
+				t.Fatalf("Open = %q, %v, want %q", plaintext, err, "secret")
+			}
+		})
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	a, err := Seal(dataKey, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	b, err := Seal(dataKey, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("sealing the same plaintext twice gave the same ciphertext")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		primary string
+		want    string // primary id, or "" if parsing fails
+	}{
+		{"last key is primary", "old:" + testKey(1, 32) + ",new:" + testKey(2, 32), "", "new"},
+		{"explicit primary", "old:" + testKey(1, 32) + ",new:" + testKey(2, 32), "old", "old"},
+		{"newlines and comments", "# rotated\nold:" + testKey(1, 16) + "\n\nnew:" + testKey(2, 24) + "\n", "", "new"},
+		{"empty", "", "", ""},
+		{"missing id", ":" + testKey(1, 32), "", ""},
+		{"duplicate id", "a:" + testKey(1, 32) + ",a:" + testKey(2, 32), "", ""},
+		{"invalid base64", "a:not base64", "", ""},
+		{"invalid key size", "a:" + testKey(1, 20), "", ""},
+		{"unknown primary", "a:" + testKey(1, 32), "b", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKeyring(tt.spec, tt.primary)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ParseKeyring succeeded with primary %q, want an error", k.PrimaryID())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyring: %v", err)
+			}
+			if k.PrimaryID() != tt.want {
+				t.Fatalf("PrimaryID() = %q, want %q", k.PrimaryID(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDataKeys(t *testing.T) {
+	old, err := ParseKeyring("old:"+testKey(1, 32), "")
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	rotated, err := ParseKeyring("old:"+testKey(1, 32)+",new:"+testKey(2, 32), "")
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+
+	dataKey, wrapped, keyID, err := old.NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey: %v", err)
+	}
+	if keyID != "old" || len(dataKey) != dataKeySize {
+		t.Fatalf("NewDataKey = %d byte key wrapped by %q, want %d bytes wrapped by %q", len(dataKey), keyID, dataKeySize, "old")
+	}
+
+	rewrapped, newID, err := rotated.Rewrap(keyID, wrapped)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if newID != "new" {
+		t.Fatalf("Rewrap key id = %q, want %q", newID, "new")
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		keyID   string
+		wrapped []byte
+		err     error // nil for success; errAny for any error
+	}{
+		{"original", old, keyID, wrapped, nil},
+		{"original after rotation", rotated, keyID, wrapped, nil},
+		{"rewrapped", rotated, newID, rewrapped, nil},
+		{"rewrapped without the new key", old, newID, rewrapped, ErrUnknownKey},
+		{"unknown key id", rotated, "missing", wrapped, ErrUnknownKey},
+		{"wrong key id", rotated, "new", wrapped, errAny},
+		{"tampered", old, keyID, append(bytes.Clone(wrapped[:len(wrapped)-1]), wrapped[len(wrapped)-1]^1), errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.UnwrapDataKey(tt.keyID, tt.wrapped)
+			switch {
+			case tt.err == nil:
+				if err != nil || !bytes.Equal(got, dataKey) {
+					t.Fatalf("UnwrapDataKey = %x, %v, want %x", got, err, dataKey)
+				}
+			case tt.err == errAny:
+				if err == nil {
+					t.Fatal("UnwrapDataKey succeeded, want an error")
+				}
+			case !errors.Is(err, tt.err):
+				t.Fatalf("UnwrapDataKey error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, _, err := old.Rewrap("missing", wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Rewrap with an unknown key id = %v, want ErrUnknownKey", err)
+	}
+}
+
+// errAny stands for any error in test tables
+var errAny = errors.New("any error")
@@ -0,0 +1,86 @@
+package grpcapi
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+)
+
+// UseRateLimits limits the call rate of each client, and what it stores per day, with
+// limiter, counting calls against the same clients as HTTP requests
+func (s *Server) UseRateLimits(limiter *ratelimit.Limiter) {
+	s.limiter = limiter
+}
+
+// allowCall takes a call of class from the rate limit of the client. key is the entry key
+// the call is for, if any. Once the client has run out of calls it returns
+// ResourceExhausted, with a retry-after header.
+func (s *Server) allowCall(ctx context.Context, class ratelimit.Class, key string) error {
+	if s.limiter == nil {
+		return nil
+	}
+	d, limited := s.limiter.Allow(class, s.rateLimitClient(ctx, key), time.Now())
+	if !limited {
+		return nil
+	}
+	metrics.RecordRateLimit(ctx, string(class), d.Allowed)
+
+	if d.Allowed {
+		return nil
+	}
+	setRetryAfter(ctx, d.RetryAfter)
+	return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+}
+
+// reserveQuota counts storing size bytes under key against the client's daily quotas,
+// returning ResourceExhausted, with a retry-after header, if that would exceed one. A
+// reservation for a write that fails is returned with refundQuota.
+func (s *Server) reserveQuota(ctx context.Context, key string, size int64) error {
+	if s.limiter == nil || !s.limiter.HasQuotas() {
+		return nil
+	}
+	quota, reset := s.limiter.Reserve(s.rateLimitClient(ctx, key), size, time.Now())
+	if quota == "" {
+		return nil
+	}
+	metrics.RecordQuotaRejection(ctx, quota)
+	setRetryAfter(ctx, reset)
+	return status.Error(codes.ResourceExhausted, "daily "+quota+" quota exceeded")
+}
+
+// refundQuota returns what reserveQuota counted for a write of size bytes under key that failed
+func (s *Server) refundQuota(ctx context.Context, key string, size int64) {
+	if s.limiter != nil && s.limiter.HasQuotas() {
+		s.limiter.Adjust(s.rateLimitClient(ctx, key), -1, -size, time.Now())
+	}
+}
+
+// rateLimitClient identifies the client a call for key is counted against
+func (s *Server) rateLimitClient(ctx context.Context, key string) string {
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	return s.limiter.Client(key, auth.FromContext(ctx), ip)
+}
+
+// setRetryAfter sends how long to wait before retrying as the retry-after header, in
+// whole seconds rounded up
+func setRetryAfter(ctx context.Context, d time.Duration) {
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(d.Seconds())))))
+}
@@ -0,0 +1,241 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	semcachev1 "github.com/nextinterfaces/semcache-service/api/proto/semcache/v1"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+// validator checks requests against the same limits as the HTTP API
+var validator = validation.New()
+
+// Scopes maps each method to the API key scope it needs, as the HTTP routes do
+var Scopes = map[string]string{
+	semcachev1.SemcacheService_Create_FullMethodName: models.ScopeWrite,
+	semcachev1.SemcacheService_Get_FullMethodName:    models.ScopeRead,
+	semcachev1.SemcacheService_Delete_FullMethodName: models.ScopeWrite,
+	semcachev1.SemcacheService_Search_FullMethodName: models.ScopeRead,
+	semcachev1.SemcacheService_Lookup_FullMethodName: models.ScopeRead,
+}
+
+// ValueLimits reports the maximum value size per namespace
+type ValueLimits interface {
+	MaxValueBytesFor(namespace string) int
+}
+
+// Server implements the gRPC SemcacheService on top of the same store as the HTTP handlers
+type Server struct {
+	semcachev1.UnimplementedSemcacheServiceServer
+	store       models.Store
+	valueLimits ValueLimits
+
+	// limiter applies rate limits and daily quotas, if enabled (see UseRateLimits)
+	limiter *ratelimit.Limiter
+}
+
+// New creates a new gRPC server over store
+func New(store models.Store) *Server {
+	return &Server{store: store}
+}
+
+// UseValueLimits rejects values over the size limit of their key's namespace with ResourceExhausted
+func (s *Server) UseValueLimits(limits ValueLimits) {
+	s.valueLimits = limits
+}
+
+func (s *Server) Create(ctx context.Context, req *semcachev1.CreateRequest) (*semcachev1.CacheEntry, error) {
+	createReq := models.CreateRequest{
+		Key:         req.GetKey(),
+		Value:       string(req.GetValue()),
+		ContentType: req.GetContentType(),
+		Metadata:    req.GetMetadata(),
+		Embedding:   req.GetEmbedding(),
+	}
+	if req.GetTtlSeconds() > 0 {
+		ttl := int(req.GetTtlSeconds())
+		createReq.TTL = &ttl
+	}
+
+	if err := validator.Validate(&createReq); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !allowsKey(ctx, createReq.Key) {
+		return nil, errKeyForbidden
+	}
+	if err := s.allowCall(ctx, ratelimit.Write, createReq.Key); err != nil {
+		return nil, err
+	}
+	if s.valueLimits != nil && len(createReq.Value) > s.valueLimits.MaxValueBytesFor(models.Namespace(createReq.Key)) {
+		return nil, status.Error(codes.ResourceExhausted, "value too large")
+	}
+	size := int64(len(createReq.Value))
+	if err := s.reserveQuota(ctx, createReq.Key, size); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	entry, err := s.store.Create(ctx, createReq)
+	if err != nil {
+		s.refundQuota(ctx, createReq.Key, size)
+		return nil, storeError(err, "failed to create cache entry")
+	}
+
+	return toProtoEntry(entry), nil
+}
+
+func (s *Server) Get(ctx context.Context, req *semcachev1.GetRequest) (*semcachev1.CacheEntry, error) {
+	if !allowsKey(ctx, req.GetKey()) {
+		return nil, errKeyForbidden
+	}
+	if err := s.allowCall(ctx, ratelimit.Read, req.GetKey()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	entry, err := s.store.Get(ctx, req.GetKey())
+	if err != nil {
+		return nil, storeError(err, "failed to get cache entry")
+	}
+
+	return toProtoEntry(entry), nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *semcachev1.DeleteRequest) (*semcachev1.DeleteResponse, error) {
+	if !allowsKey(ctx, req.GetKey()) {
+		return nil, errKeyForbidden
+	}
+	if err := s.allowCall(ctx, ratelimit.Write, req.GetKey()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.store.Delete(ctx, req.GetKey()); err != nil {
+		return nil, storeError(err, "failed to delete cache entry")
+	}
+
+	return &semcachev1.DeleteResponse{}, nil
+}
+
+func (s *Server) Search(ctx context.Context, req *semcachev1.SearchRequest) (*semcachev1.SearchResponse, error) {
+	if err := s.allowCall(ctx, ratelimit.Read, ""); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	entries, err := s.store.Search(ctx, models.SearchRequest{
+		Key:        req.GetKey(),
+		Metadata:   req.GetMetadata(),
+		Limit:      int(req.GetLimit()),
+		Namespaces: allowedNamespaces(ctx),
+	})
+	if err != nil {
+		return nil, storeError(err, "failed to search cache entries")
+	}
+
+	response := &semcachev1.SearchResponse{Entries: make([]*semcachev1.CacheEntry, len(entries))}
+	for i, entry := range entries {
+		response.Entries[i] = toProtoEntry(entry)
+	}
+
+	return response, nil
+}
+
+func (s *Server) Lookup(ctx context.Context, req *semcachev1.LookupRequest) (*semcachev1.LookupResponse, error) {
+	if len(req.GetEmbedding()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "embedding is required")
+	}
+	if err := s.allowCall(ctx, ratelimit.Read, ""); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	results, err := s.store.Lookup(ctx, models.LookupRequest{
+		Embedding:  req.GetEmbedding(),
+		Threshold:  req.GetThreshold(),
+		Limit:      int(req.GetLimit()),
+		Namespaces: allowedNamespaces(ctx),
+	})
+	if err != nil {
+		return nil, storeError(err, "failed to lookup cache entries")
+	}
+
+	response := &semcachev1.LookupResponse{Results: make([]*semcachev1.LookupResult, len(results))}
+	for i, result := range results {
+		response.Results[i] = &semcachev1.LookupResult{Entry: toProtoEntry(result.CacheEntry), Score: result.Score}
+	}
+
+	return response, nil
+}
+
+// errKeyForbidden rejects a key outside the namespaces of the call's API key
+var errKeyForbidden = status.Error(codes.PermissionDenied, "API key does not allow access to this namespace")
+
+// allowsKey reports whether the call's API key, if there is one, may access key
+func allowsKey(ctx context.Context, key string) bool {
+	apiKey := auth.FromContext(ctx)
+	return apiKey == nil || apiKey.AllowsNamespace(models.Namespace(key))
+}
+
+// allowedNamespaces returns the namespaces the call's API key is restricted to, or nil if
+// it may access every namespace
+func allowedNamespaces(ctx context.Context) []string {
+	if apiKey := auth.FromContext(ctx); apiKey != nil && len(apiKey.Namespaces) > 0 {
+		return apiKey.Namespaces
+	}
+	return nil
+}
+
+// storeError maps store errors to gRPC status codes the way the HTTP handlers map them to statuses
+func storeError(err error, msg string) error {
+	switch {
+	case errors.Is(err, models.ErrUnavailable):
+		return status.Error(codes.Unavailable, "cache storage unavailable")
+	case errors.Is(err, models.ErrNotFound):
+		return status.Error(codes.NotFound, "cache entry not found")
+	case errors.Is(err, models.ErrKeyExists):
+		return status.Error(codes.AlreadyExists, "cache entry already exists")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, msg)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, msg)
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+// toProtoEntry converts a cache entry to its protobuf message. Values are bytes on the
+// wire, so binary values need no encoding.
+func toProtoEntry(entry *models.CacheEntry) *semcachev1.CacheEntry {
+	pb := &semcachev1.CacheEntry{
+		Id:          int64(entry.ID),
+		Key:         entry.Key,
+		Value:       []byte(entry.Value),
+		ContentType: entry.ContentType,
+		Metadata:    entry.Metadata,
+		CreatedAt:   timestamppb.New(entry.CreatedAt),
+		Embedding:   entry.Embedding,
+	}
+	if entry.ExpiresAt != nil {
+		pb.ExpiresAt = timestamppb.New(*entry.ExpiresAt)
+	}
+	return pb
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// StatsResponse reports what the cache holds
+type StatsResponse struct {
+	models.Stats
+	L1             *L1Stats `json:"l1,omitempty"`
+	CircuitBreaker string   `json:"circuit_breaker,omitempty"`
+}
+
+// L1Stats reports the contents of this replica's L1 tier
+type L1Stats struct {
+	Entries int64 `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// InvalidateRequest selects what to evict from L1: one key, or everything with All
+type InvalidateRequest struct {
+	Key string `json:"key,omitempty"`
+	All bool   `json:"all,omitempty"`
+}
+
+// UseL1Usage includes L1 usage in stats responses
+func (h *Handler) UseL1Usage(usage func() (entries, bytes int64)) {
+	h.l1Usage = usage
+}
+
+// UseInvalidation makes the invalidate endpoint evict keys with invalidate, which is
+// given an empty key to flush everything
+func (h *Handler) UseInvalidation(invalidate func(ctx context.Context, key string) error) {
+	h.invalidate = invalidate
+}
+
+// Stats reports entry counts and sizes
+func (h *Handler) Stats(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	stats, err := h.store.Stats(ctx)
+	if err != nil {
+		return storeError(c, err, "Failed to get cache stats")
+	}
+
+	response := StatsResponse{Stats: *stats}
+	if h.l1Usage != nil {
+		entries, bytes := h.l1Usage()
+		response.L1 = &L1Stats{Entries: entries, Bytes: bytes}
+	}
+	if h.breakerState != nil {
+		response.CircuitBreaker = h.breakerState()
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Export streams every live entry whose key starts with the prefix query parameter as
+// newline-delimited JSON, in the same form as the other endpoints return entries
+func (h *Handler) Export(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), streamTimeout)
+	defer cancel()
+
+	res := c.Response()
+	encoder := json.NewEncoder(res)
+	started := false
+
+	err := h.store.Export(ctx, c.QueryParam("prefix"), 0, func(entry *models.CacheEntry) error {
+		if !started {
+			res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+			res.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := encoder.Encode(jsonEntry(entry)); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	})
+
+	if started {
+		// Once streaming has started, a failure can only cut the response short
+		return nil
+	}
+	if err != nil {
+		return storeError(c, err, "Failed to export cache entries")
+	}
+
+	return c.Blob(http.StatusOK, "application/x-ndjson", nil)
+}
+
+// Invalidate evicts a key, or everything, from the L1 tier of every replica. Entries
+// stay in the store.
+func (h *Handler) Invalidate(c echo.Context) error {
+	var req InvalidateRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	if req.Key == "" && !req.All {
+		return badRequest(c, "Key or all is required")
+	}
+	if req.Key != "" && req.All {
+		return badRequest(c, "Key and all are mutually exclusive")
+	}
+
+	if h.invalidate == nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.invalidate(ctx, req.Key); err != nil {
+		return internalError(c, "Failed to invalidate cache entries")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+// UseAPIKeys enables the API key endpoints, storing keys in store and calling deleted
+// after one is deleted
+func (h *Handler) UseAPIKeys(store models.APIKeyStore, deleted func()) {
+	h.apiKeys = store
+	h.apiKeyDeleted = deleted
+}
+
+// CreateAPIKey creates an API key. The response includes the key, which is not returned
+// again.
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	var errs validation.Errors
+	if err := c.Validate(&req); err != nil && !errors.As(err, &errs) {
+		return invalidRequest(c, err)
+	}
+	if errs = append(errs, validateAPIKey(&req)...); len(errs) > 0 {
+		return invalidRequest(c, errs)
+	}
+
+	secret, prefix, hash, err := auth.NewKey()
+	if err != nil {
+		return internalError(c, "Failed to create API key")
+	}
+	key := &models.APIKey{
+		Name:       req.Name,
+		Prefix:     prefix,
+		Scopes:     req.Scopes,
+		Namespaces: req.Namespaces,
+		ExpiresAt:  req.ExpiresAt,
+		Hash:       hash,
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.apiKeys.CreateAPIKey(ctx, key); err != nil {
+		return storeError(c, err, "Failed to create API key")
+	}
+	key.Key = secret
+
+	return c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys returns every API key, without the keys themselves
+func (h *Handler) ListAPIKeys(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	keys, err := h.apiKeys.ListAPIKeys(ctx)
+	if err != nil {
+		return storeError(c, err, "Failed to list API keys")
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// DeleteAPIKey removes an API key
+func (h *Handler) DeleteAPIKey(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return badRequest(c, "Invalid API key id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	err = h.apiKeys.DeleteAPIKey(ctx, id)
+	if errors.Is(err, models.ErrNotFound) {
+		return notFound(c, "API key not found")
+	}
+	if err != nil {
+		return storeError(c, err, "Failed to delete API key")
+	}
+	if h.apiKeyDeleted != nil {
+		h.apiKeyDeleted()
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// validateAPIKey checks the parts of an API key request that its validate tags do not
+func validateAPIKey(req *models.CreateAPIKeyRequest) validation.Errors {
+	var errs validation.Errors
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			errs = append(errs, validation.FieldError{Field: "scopes", Message: "has unknown scope " + scope})
+			break
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	for _, namespace := range req.Namespaces {
+		if namespace == "" || strings.Contains(namespace, ":") {
+			errs = append(errs, validation.FieldError{Field: "namespaces", Message: "must be non-empty and not contain ':'"})
+			break
+		}
+	}
+	slices.Sort(req.Namespaces)
+	req.Namespaces = slices.Compact(req.Namespaces)
+
+	// Admin endpoints reach every namespace, so restricting an admin key would mislead
+	if len(req.Namespaces) > 0 && slices.Contains(req.Scopes, models.ScopeAdmin) {
+		errs = append(errs, validation.FieldError{Field: "namespaces", Message: "cannot restrict a key with the admin scope"})
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errs = append(errs, validation.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+
+	return errs
+}
+
+// allowsKey reports whether the request's API key, if there is one, may access key
+func allowsKey(c echo.Context, key string) bool {
+	apiKey := auth.FromContext(c.Request().Context())
+	return apiKey == nil || apiKey.AllowsNamespace(models.Namespace(key))
+}
+
+// allowedNamespaces returns the namespaces the request's API key is restricted to, or nil
+// if it may access every namespace
+func allowedNamespaces(c echo.Context) []string {
+	if apiKey := auth.FromContext(c.Request().Context()); apiKey != nil && len(apiKey.Namespaces) > 0 {
+		return apiKey.Namespaces
+	}
+	return nil
+}
+
+// keyForbidden responds with 403 for a key outside the namespaces of the request's API key
+func keyForbidden(c echo.Context) error {
+	return forbidden(c, "API key does not allow access to this namespace")
+}
@@ -2,102 +2,263 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+	"github.com/nextinterfaces/semcache-service/internal/watch"
 )
 
 type Handler struct {
-	cacheRepo *models.CacheRepository
+	store     models.Store
 	commitSHA string
+
+	// breakerState reports the database circuit breaker state, if there is one
+	breakerState func() string
+
+	// valueLimits caps value sizes per namespace, if set (see UseValueLimits)
+	valueLimits ValueLimits
+
+	// l1Usage and invalidate expose the L1 tier to the admin endpoints, if there is one
+	l1Usage    func() (entries, bytes int64)
+	invalidate func(ctx context.Context, key string) error
+
+	// webhooks stores webhook registrations, if webhooks are enabled (see UseWebhooks)
+	webhooks        models.WebhookStore
+	webhooksChanged func(ctx context.Context)
+
+	// watch streams changes to the watch endpoint, if enabled (see UseWatch)
+	watch *watch.Hub
+
+	// apiKeys stores API keys for the key endpoints (see UseAPIKeys)
+	apiKeys       models.APIKeyStore
+	apiKeyDeleted func()
+
+	// limiter applies rate limits and daily quotas, if enabled (see UseRateLimits)
+	limiter *ratelimit.Limiter
 }
 
-func New(cacheRepo *models.CacheRepository, commitSHA string) *Handler {
+func New(store models.Store, commitSHA string) *Handler {
 	return &Handler{
-		cacheRepo: cacheRepo,
+		store:     store,
 		commitSHA: commitSHA,
 	}
 }
 
+// UseBreakerState includes the database circuit breaker state in health responses
+func (h *Handler) UseBreakerState(state func() string) {
+	h.breakerState = state
+}
+
 type HealthResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
-	CommitSHA string `json:"commit_sha"`
-	Database  string `json:"database"`
+	Status         string `json:"status"`
+	Timestamp      string `json:"timestamp"`
+	CommitSHA      string `json:"commit_sha"`
+	Database       string `json:"database"`
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
 }
 
 func (h *Handler) Health(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.healthStatus(c))
+}
+
+// Ready reports 503 while the storage backend is unavailable (degraded mode), so that
+// the pod is taken out of rotation without being restarted
+func (h *Handler) Ready(c echo.Context) error {
+	response := h.healthStatus(c)
+	if response.Database != "healthy" {
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) healthStatus(c echo.Context) HealthResponse {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
 	defer cancel()
 
+	status := "ok"
 	dbStatus := "healthy"
-	err := h.cacheRepo.HealthCheck(ctx)
+	err := h.store.HealthCheck(ctx)
 	if err != nil {
+		status = "degraded"
 		dbStatus = "unhealthy"
 	}
 
-	response := HealthResponse{
-		Status:    "ok",
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		CommitSHA: h.commitSHA,
-		Database:  dbStatus,
+	var breakerState string
+	if h.breakerState != nil {
+		breakerState = h.breakerState()
+		if breakerState == "open" {
+			status = "degraded"
+		}
 	}
 
-	return c.JSON(http.StatusOK, response)
+	return HealthResponse{
+		Status:         status,
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		CommitSHA:      h.commitSHA,
+		Database:       dbStatus,
+		CircuitBreaker: breakerState,
+	}
 }
 
 func (h *Handler) Create(c echo.Context) error {
+	if h.valueLimits != nil {
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.maxJSONBodyBytes())
+	}
+
 	var req models.CreateRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return valueTooLarge(c)
+		}
+		return badRequest(c, "Invalid request body")
 	}
 
-	if req.Key == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Key is required",
-		})
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(c, err)
+	}
+	if !allowsKey(c, req.Key) {
+		return keyForbidden(c)
+	}
+	if !h.allowRequest(c, ratelimit.Write, req.Key) {
+		return rateLimited(c)
 	}
 
-	if req.Value == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Value is required",
-		})
+	if err := decodeRequestValue(&req); err != nil {
+		return invalidRequest(c, validation.Errors{{Field: "value", Message: "must be valid base64"}})
+	}
+
+	if h.valueLimits != nil && len(req.Value) > h.valueLimits.MaxValueBytesFor(models.Namespace(req.Key)) {
+		return valueTooLarge(c)
+	}
+	size := int64(len(req.Value))
+	if quota := h.reserveQuota(c, req.Key, size); quota != "" {
+		return quotaExceeded(c, quota)
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	entry, err := h.cacheRepo.Create(ctx, req)
+	entry, err := h.store.Create(ctx, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create cache entry",
-		})
+		h.adjustQuota(c, req.Key, -1, -size)
+		return storeError(c, err, "Failed to create cache entry")
 	}
 
-	return c.JSON(http.StatusCreated, entry)
+	return c.JSON(http.StatusCreated, jsonEntry(entry))
 }
 
 func (h *Handler) Search(c echo.Context) error {
 	var req models.SearchRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return badRequest(c, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(c, err)
+	}
+	if !h.allowRequest(c, ratelimit.Read, "") {
+		return rateLimited(c)
+	}
+	req.Namespaces = allowedNamespaces(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	entries, err := h.store.Search(ctx, req)
+	if err != nil {
+		return storeError(c, err, "Failed to search cache entries")
+	}
+
+	return c.JSON(http.StatusOK, jsonEntries(entries))
+}
+
+func (h *Handler) Get(c echo.Context) error {
+	key := keyParam(c)
+	if !allowsKey(c, key) {
+		return keyForbidden(c)
+	}
+	if !h.allowRequest(c, ratelimit.Read, key) {
+		return rateLimited(c)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	entry, err := h.store.Get(ctx, key)
+	if err != nil {
+		return storeError(c, err, "Failed to get cache entry")
+	}
+
+	return c.JSON(http.StatusOK, jsonEntry(entry))
+}
+
+func (h *Handler) Delete(c echo.Context) error {
+	key := keyParam(c)
+	if !allowsKey(c, key) {
+		return keyForbidden(c)
+	}
+	if !h.allowRequest(c, ratelimit.Write, key) {
+		return rateLimited(c)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	err := h.store.Delete(ctx, key)
+	if err != nil {
+		return storeError(c, err, "Failed to delete cache entry")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) Lookup(c echo.Context) error {
+	var req models.LookupRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "Invalid request body")
 	}
 
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(c, err)
+	}
+	if !h.allowRequest(c, ratelimit.Read, "") {
+		return rateLimited(c)
+	}
+	req.Namespaces = allowedNamespaces(c)
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	entries, err := h.cacheRepo.Search(ctx, req)
+	results, err := h.store.Lookup(ctx, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to search cache entries",
-		})
+		return storeError(c, err, "Failed to lookup cache entries")
+	}
+
+	response := make([]*models.LookupResult, len(results))
+	for i, result := range results {
+		response[i] = &models.LookupResult{CacheEntry: jsonEntry(result.CacheEntry), Score: result.Score}
 	}
 
-	return c.JSON(http.StatusOK, entries)
+	return c.JSON(http.StatusOK, response)
+}
+
+// keyParam returns the entry key from the path. Echo routes on the raw path when it
+// contains escapes such as %2F, leaving them in the parameter, so keys containing '/'
+// are unescaped here.
+func keyParam(c echo.Context) string {
+	key := c.Param("key")
+	if c.Request().URL.RawPath == "" {
+		return key
+	}
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
 }
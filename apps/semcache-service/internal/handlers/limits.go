@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+)
+
+// UseRateLimits limits the request rate of each client, and what it stores per day, with
+// limiter
+func (h *Handler) UseRateLimits(limiter *ratelimit.Limiter) {
+	h.limiter = limiter
+}
+
+// allowRequest takes a request of class from the rate limit of the client, setting the
+// RateLimit headers. key is the entry key the request is for, if any. It reports false,
+// after setting Retry-After, once the client has run out of requests.
+func (h *Handler) allowRequest(c echo.Context, class ratelimit.Class, key string) bool {
+	if h.limiter == nil {
+		return true
+	}
+	d, limited := h.limiter.Allow(class, h.rateLimitClient(c, key), time.Now())
+	if !limited {
+		return true
+	}
+	metrics.RecordRateLimit(c.Request().Context(), string(class), d.Allowed)
+
+	header := c.Response().Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	header.Set("RateLimit-Reset", ceilSeconds(d.Reset))
+	header.Set("RateLimit-Policy", d.Policy)
+	if !d.Allowed {
+		header.Set(echo.HeaderRetryAfter, ceilSeconds(d.RetryAfter))
+	}
+	return d.Allowed
+}
+
+// reserveQuota counts storing size bytes under key against the client's daily quotas,
+// returning the quota that would be exceeded, after setting Retry-After, or "" if it is
+// within its quotas. A reservation for a write that fails is returned with adjustQuota.
+func (h *Handler) reserveQuota(c echo.Context, key string, size int64) string {
+	if h.limiter == nil || !h.limiter.HasQuotas() {
+		return ""
+	}
+	quota, reset := h.limiter.Reserve(h.rateLimitClient(c, key), size, time.Now())
+	if quota != "" {
+		metrics.RecordQuotaRejection(c.Request().Context(), quota)
+		c.Response().Header().Set(echo.HeaderRetryAfter, ceilSeconds(reset))
+	}
+	return quota
+}
+
+// adjustQuota corrects what reserveQuota counted for key by entries and bytes
+func (h *Handler) adjustQuota(c echo.Context, key string, entries, bytes int64) {
+	if h.limiter != nil && h.limiter.HasQuotas() {
+		h.limiter.Adjust(h.rateLimitClient(c, key), entries, bytes, time.Now())
+	}
+}
+
+// rateLimitClient identifies the client a request for key is counted against
+func (h *Handler) rateLimitClient(c echo.Context, key string) string {
+	return h.limiter.Client(key, auth.FromContext(c.Request().Context()), c.RealIP())
+}
+
+// rateLimited responds with 429 for a client over its rate limit
+func rateLimited(c echo.Context) error {
+	return problem(c, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
+}
+
+// quotaExceeded responds with 429 for a client over a daily quota
+func quotaExceeded(c echo.Context, quota string) error {
+	return problem(c, http.StatusTooManyRequests, CodeQuotaExceeded, "Daily "+quota+" quota exceeded")
+}
+
+// ceilSeconds formats d as whole seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+// Problem is an RFC 7807 problem details response. Code is stable for clients to match
+// on; Title and Detail are meant for people and may change.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+
+	// Errors lists the fields that failed validation, for invalid_request problems
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+// ProblemContentType is the media type of problem responses
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the code of a problem to form its type URI
+const problemTypePrefix = "urn:semcache:problem:"
+
+// Problem codes
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeKeyConflict      = "key_conflict"
+	CodeValueTooLarge    = "value_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeDBUnavailable    = "db_unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// problemTitles are the short, fixed summaries of each code
+var problemTitles = map[string]string{
+	CodeInvalidRequest:   "Invalid request",
+	CodeUnauthorized:     "Unauthorized",
+	CodeForbidden:        "Forbidden",
+	CodeNotFound:         "Not found",
+	CodeMethodNotAllowed: "Method not allowed",
+	CodeKeyConflict:      "Key conflict",
+	CodeValueTooLarge:    "Value too large",
+	CodeRateLimited:      "Rate limited",
+	CodeQuotaExceeded:    "Quota exceeded",
+	CodeDBUnavailable:    "Database unavailable",
+	CodeInternal:         "Internal server error",
+}
+
+// problem responds with a problem of the given status and code
+func problem(c echo.Context, status int, code, detail string) error {
+	return sendProblem(c, newProblem(c, status, code, detail))
+}
+
+// newProblem creates a problem about the current request
+func newProblem(c echo.Context, status int, code, detail string) *Problem {
+	return &Problem{
+		Type:     problemTypePrefix + code,
+		Title:    problemTitles[code],
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request().URL.Path,
+		Code:     code,
+	}
+}
+
+// sendProblem writes p as the response
+func sendProblem(c echo.Context, p *Problem) error {
+	c.Response().Header().Set(echo.HeaderContentType, ProblemContentType)
+	return c.JSON(p.Status, p)
+}
+
+// badRequest responds with 400 for a request that is malformed or fails validation
+func badRequest(c echo.Context, detail string) error {
+	return problem(c, http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// invalidRequest responds with 400 for a request that failed validation, listing the
+// fields at fault
+func invalidRequest(c echo.Context, err error) error {
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) {
+		logger.Logger.Error(fmt.Sprintf("Failed to validate request: %v", err))
+		return internalError(c, "Failed to validate request")
+	}
+
+	p := newProblem(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request: "+fieldErrs.Error())
+	p.Errors = fieldErrs
+	return sendProblem(c, p)
+}
+
+// forbidden responds with 403 for a request its API key does not permit
+func forbidden(c echo.Context, detail string) error {
+	return problem(c, http.StatusForbidden, CodeForbidden, detail)
+}
+
+// notFound responds with 404
+func notFound(c echo.Context, detail string) error {
+	return problem(c, http.StatusNotFound, CodeNotFound, detail)
+}
+
+// internalError responds with 500
+func internalError(c echo.Context, detail string) error {
+	return problem(c, http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// storeUnavailable responds with 503 while the storage backend is down
+func storeUnavailable(c echo.Context) error {
+	return problem(c, http.StatusServiceUnavailable, CodeDBUnavailable, "Cache storage unavailable")
+}
+
+// valueTooLarge responds with 413 for values over the configured maximum size
+func valueTooLarge(c echo.Context) error {
+	return problem(c, http.StatusRequestEntityTooLarge, CodeValueTooLarge, "Value exceeds maximum size")
+}
+
+// storeError responds to an error from the store, reporting detail if it is not one that
+// maps to a more specific problem
+func storeError(c echo.Context, err error, detail string) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case models.IsUnavailable(err):
+		return storeUnavailable(c)
+	case errors.Is(err, models.ErrNotFound):
+		return notFound(c, "Cache entry not found")
+	case errors.Is(err, models.ErrKeyExists):
+		return problem(c, http.StatusConflict, CodeKeyConflict, "Cache entry already exists")
+	case errors.As(err, &maxBytesErr):
+		return valueTooLarge(c)
+	}
+	return internalError(c, detail)
+}
+
+// ErrorHandler renders errors returned to Echo, such as unknown routes and panics caught
+// by the recover middleware, as problems
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	detail := http.StatusText(status)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+		detail = http.StatusText(status)
+		if msg, ok := httpErr.Message.(string); ok {
+			detail = msg
+		}
+	}
+
+	code := CodeInvalidRequest
+	switch {
+	case status == http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case status == http.StatusForbidden:
+		code = CodeForbidden
+	case status == http.StatusNotFound:
+		code = CodeNotFound
+	case status == http.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case status == http.StatusRequestEntityTooLarge:
+		code = CodeValueTooLarge
+	case status == http.StatusTooManyRequests:
+		code = CodeRateLimited
+	case status == http.StatusServiceUnavailable:
+		code = CodeDBUnavailable
+	case status >= 500:
+		code = CodeInternal
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = problem(c, status, code, detail)
+	}
+	if err != nil {
+		logger.Logger.Error(fmt.Sprintf("Failed to send error response: %v", err))
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+func TestInvalidRequest(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	fieldErrs := validation.Errors{{Field: "key", Message: "is required"}, {Field: "ttl", Message: "must be at least 0"}}
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   Problem
+	}{
+		{"field errors", fieldErrs, http.StatusBadRequest, Problem{
+			Type:     "urn:semcache:problem:invalid_request",
+			Title:    "Invalid request",
+			Status:   http.StatusBadRequest,
+			Detail:   "Invalid request: key is required; ttl must be at least 0",
+			Instance: "/cache",
+			Code:     CodeInvalidRequest,
+			Errors:   fieldErrs,
+		}},
+		{"other errors", errors.New("invalid validate tag"), http.StatusInternalServerError, Problem{
+			Type:     "urn:semcache:problem:internal_error",
+			Title:    "Internal server error",
+			Status:   http.StatusInternalServerError,
+			Detail:   "Failed to validate request",
+			Instance: "/cache",
+			Code:     CodeInternal,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/cache", nil), rec)
+			if err := invalidRequest(c, tt.err); err != nil {
+				t.Fatalf("invalidRequest: %v", err)
+			}
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get(echo.HeaderContentType); got != ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ProblemContentType)
+			}
+			var got Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid body %q: %v", rec.Body, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("problem = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidRequestFromValidator(t *testing.T) {
+	// Errors from c.Validate reach the client as they were reported, field by field
+	e := echo.New()
+	e.Validator = validation.New()
+	e.POST("/cache", func(c echo.Context) error {
+		var req struct {
+			Key string `json:"key" validate:"required,key"`
+			TTL *int   `json:"ttl,omitempty" validate:"min=0"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return badRequest(c, "Invalid request body")
+		}
+		if err := c.Validate(&req); err != nil {
+			return invalidRequest(c, err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/cache", strings.NewReader(`{"key":"a\u0001b","ttl":-5}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var got struct {
+		Code   string `json:"code"`
+		Errors []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid body %q: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusBadRequest || got.Code != CodeInvalidRequest || len(got.Errors) != 2 ||
+		got.Errors[0].Field != "key" || got.Errors[0].Message != "must not contain control characters" ||
+		got.Errors[1].Field != "ttl" || got.Errors[1].Message != "must be at least 0" {
+		t.Fatalf("response = %d %s, want 400 invalid_request with key and ttl errors", rec.Code, rec.Body)
+	}
+}
@@ -2,35 +2,30 @@ package handlers
 
 import (
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/api"
 )
 
-// ServeOpenAPISpec serves the OpenAPI specification file
+// ServeOpenAPISpec serves the OpenAPI specification embedded in the binary
 func (h *Handler) ServeOpenAPISpec(c echo.Context) error {
-	// Read the OpenAPI spec file
-	specPath := filepath.Join("api", "openapi.yaml")
-	data, err := os.ReadFile(specPath)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to read OpenAPI specification",
-		})
-	}
-
-	return c.Blob(http.StatusOK, "application/yaml", data)
+	return c.Blob(http.StatusOK, "application/yaml", api.OpenAPISpec)
 }
 
-// ServeSwaggerUI serves the Swagger UI HTML page
+// ServeSwaggerUI serves the Swagger UI HTML page. Its assets are served from the binary
+// under /docs/assets, so the page works without internet access.
 func (h *Handler) ServeSwaggerUI(c echo.Context) error {
-	html := `<!DOCTYPE html>
+	return c.HTML(http.StatusOK, swaggerUIPage)
+}
+
+// swaggerUIPage loads Swagger UI from the assets next to it, pointed at the spec
+const swaggerUIPage = `<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>Semcache Service API Documentation</title>
-    <link rel="stylesheet" type="text/css" href="https://unpkg.com/swagger-ui-dist@5.10.5/swagger-ui.css">
+    <link rel="stylesheet" type="text/css" href="docs/assets/swagger-ui.css">
     <style>
         body {
             margin: 0;
@@ -43,8 +38,8 @@ func (h *Handler) ServeSwaggerUI(c echo.Context) error {
 </head>
 <body>
     <div id="swagger-ui"></div>
-    <script src="https://unpkg.com/swagger-ui-dist@5.10.5/swagger-ui-bundle.js"></script>
-    <script src="https://unpkg.com/swagger-ui-dist@5.10.5/swagger-ui-standalone-preset.js"></script>
+    <script src="docs/assets/swagger-ui-bundle.js"></script>
+    <script src="docs/assets/swagger-ui-standalone-preset.js"></script>
     <script>
         window.onload = function() {
             // Get the base path from the current URL
@@ -76,7 +71,3 @@ func (h *Handler) ServeSwaggerUI(c echo.Context) error {
     </script>
 </body>
 </html>`
-
-	return c.HTML(http.StatusOK, html)
-}
-
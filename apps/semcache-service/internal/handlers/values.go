@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+// streamTimeout bounds raw value uploads and downloads, which run at the client's pace
+const streamTimeout = 10 * time.Minute
+
+// ValueLimits reports the maximum value size per namespace
+type ValueLimits interface {
+	MaxValueBytesFor(namespace string) int
+	LargestMaxValueBytes() int
+}
+
+// UseValueLimits rejects values over the size limit of their key's namespace with 413
+func (h *Handler) UseValueLimits(limits ValueLimits) {
+	h.valueLimits = limits
+}
+
+// maxJSONBodyBytes bounds JSON create bodies: the largest value once base64-encoded,
+// with room for the rest of the request such as the embedding
+func (h *Handler) maxJSONBodyBytes() int64 {
+	return int64(h.valueLimits.LargestMaxValueBytes())/3*4 + 4 + 1<<20
+}
+
+// putValueParams are the parameters of PutValue other than the value, with the same
+// limits as models.CreateRequest
+type putValueParams struct {
+	Key         string `param:"key" validate:"required,key"`
+	ContentType string `header:"Content-Type" validate:"max=255"`
+	Metadata    string `query:"metadata" validate:"maxbytes=2048"`
+	TTL         *int   `query:"ttl" validate:"min=0,max=315360000"`
+}
+
+// PutValue creates an entry from the raw request body, keeping the request Content-Type.
+// TTL and metadata are taken from the ttl and metadata query parameters. The body is
+// streamed to the store rather than read into memory first.
+func (h *Handler) PutValue(c echo.Context) error {
+	params := putValueParams{
+		Key:         keyParam(c),
+		ContentType: c.Request().Header.Get(echo.HeaderContentType),
+		Metadata:    c.QueryParam("metadata"),
+	}
+	if ttl := c.QueryParam("ttl"); ttl != "" {
+		seconds, err := strconv.Atoi(ttl)
+		if err != nil {
+			return invalidRequest(c, validation.Errors{{Field: "ttl", Message: "must be an integer"}})
+		}
+		params.TTL = &seconds
+	}
+	if err := c.Validate(&params); err != nil {
+		return invalidRequest(c, err)
+	}
+	if !allowsKey(c, params.Key) {
+		return keyForbidden(c)
+	}
+	if !h.allowRequest(c, ratelimit.Write, params.Key) {
+		return rateLimited(c)
+	}
+	// The size is only known up front if the client sent Content-Length; the value is
+	// counted as it is read either way, and the reservation corrected once stored
+	reserved := max(c.Request().ContentLength, 0)
+	if quota := h.reserveQuota(c, params.Key, reserved); quota != "" {
+		return quotaExceeded(c, quota)
+	}
+
+	req := models.CreateRequest{
+		Key:         params.Key,
+		ContentType: params.ContentType,
+		Metadata:    params.Metadata,
+		TTL:         params.TTL,
+	}
+	if req.ContentType == "" {
+		req.ContentType = echo.MIMEOctetStream
+	}
+
+	var body io.Reader = c.Request().Body
+	if h.valueLimits != nil {
+		limit := h.valueLimits.MaxValueBytesFor(models.Namespace(req.Key))
+		body = http.MaxBytesReader(c.Response(), c.Request().Body, int64(limit))
+	}
+
+	// Reject an empty body before the store starts writing
+	counted := &countingReader{r: body}
+	buffered := bufio.NewReader(counted)
+	if _, err := buffered.Peek(1); err != nil {
+		h.adjustQuota(c, params.Key, -1, -reserved)
+		if err == io.EOF {
+			return invalidRequest(c, validation.Errors{{Field: "value", Message: "is required"}})
+		}
+		return readBodyFailed(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), streamTimeout)
+	defer cancel()
+
+	_, err := h.store.CreateStream(ctx, req, buffered)
+	if err != nil {
+		h.adjustQuota(c, req.Key, -1, -reserved)
+		return storeError(c, err, "Failed to create cache entry")
+	}
+	h.adjustQuota(c, req.Key, 0, counted.n-reserved)
+
+	c.Response().Header().Set(echo.HeaderLocation, c.Request().URL.Path)
+	return c.NoContent(http.StatusCreated)
+}
+
+// GetValue streams the raw value of an entry under its stored content type
+func (h *Handler) GetValue(c echo.Context) error {
+	key := keyParam(c)
+	if !allowsKey(c, key) {
+		return keyForbidden(c)
+	}
+	if !h.allowRequest(c, ratelimit.Read, key) {
+		return rateLimited(c)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), streamTimeout)
+	defer cancel()
+
+	stream, err := h.store.GetStream(ctx, key)
+	if err != nil {
+		return storeError(c, err, "Failed to get cache entry")
+	}
+	defer stream.Close()
+
+	contentType := stream.ContentType
+	if contentType == "" {
+		contentType = echo.MIMETextPlainCharsetUTF8
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(stream.Size, 10))
+	header.Set(echo.HeaderLastModified, stream.CreatedAt.UTC().Format(http.TimeFormat))
+	if stream.ExpiresAt != nil {
+		header.Set("Expires", stream.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
+	// Once streaming has started, a failure can only cut the response short
+	return c.Stream(http.StatusOK, contentType, stream)
+}
+
+// decodeRequestValue decodes a JSON request value according to its value_encoding
+func decodeRequestValue(req *models.CreateRequest) error {
+	switch req.ValueEncoding {
+	case "":
+		return nil
+	case models.ValueEncodingBase64:
+		value, err := base64.StdEncoding.DecodeString(req.Value)
+		if err != nil {
+			return fmt.Errorf("invalid base64 value: %w", err)
+		}
+		req.Value = string(value)
+		req.ValueEncoding = ""
+		return nil
+	}
+	return fmt.Errorf("unknown value_encoding %q", req.ValueEncoding)
+}
+
+// jsonEntry returns entry as sent in JSON responses. Binary values, by content type or
+// because they are not valid UTF-8, are base64-encoded and marked with value_encoding.
+func jsonEntry(entry *models.CacheEntry) *models.CacheEntry {
+	if isTextContentType(entry.ContentType) && utf8.ValidString(entry.Value) {
+		return entry
+	}
+
+	// Copy rather than modify, as stores may hand out shared entries
+	encoded := *entry
+	encoded.Value = base64.StdEncoding.EncodeToString([]byte(entry.Value))
+	encoded.ValueEncoding = models.ValueEncodingBase64
+	return &encoded
+}
+
+// jsonEntries applies jsonEntry to each entry
+func jsonEntries(entries []*models.CacheEntry) []*models.CacheEntry {
+	out := make([]*models.CacheEntry, len(entries))
+	for i, entry := range entries {
+		out[i] = jsonEntry(entry)
+	}
+	return out
+}
+
+// isTextContentType reports whether values of contentType are text; no content type is text
+func isTextContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == echo.MIMEApplicationJSON,
+		mediaType == echo.MIMEApplicationXML,
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// readBodyFailed responds to a failure reading the request body
+func readBodyFailed(c echo.Context, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return valueTooLarge(c)
+	}
+	return badRequest(c, "Failed to read request body")
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/auth"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+	"github.com/nextinterfaces/semcache-service/internal/watch"
+)
+
+const (
+	// watchHeartbeat is how often an idle watch stream is written to, so proxies keep it open
+	watchHeartbeat = 15 * time.Second
+
+	// watchReplayPage is how many missed changes are read from the log at a time
+	watchReplayPage = 1000
+)
+
+// watchEvent is the data of a change event on a watch stream
+type watchEvent struct {
+	Seq       int64     `json:"seq"`
+	Type      string    `json:"type"`
+	Key       string    `json:"key"`
+	Namespace string    `json:"namespace"`
+	Time      time.Time `json:"time"`
+}
+
+// UseWatch enables the watch endpoint, streaming changes from hub
+func (h *Handler) UseWatch(hub *watch.Hub) {
+	h.watch = hub
+}
+
+// Watch streams entry changes as Server-Sent Events, optionally only those whose key
+// starts with the prefix query parameter or is in the namespace query parameter. Each
+// event's id is its sequence in the change log; a client reconnecting with Last-Event-ID
+// (or the last_event_id query parameter) first receives the changes it missed. If those
+// are no longer retained, a reset event tells it to reload what it cached. An evicted
+// event without a key reports entries removed in bulk and is sent to every watcher.
+// Changes outside the namespaces of the request's API key are never sent.
+func (h *Handler) Watch(c echo.Context) error {
+	prefix := c.QueryParam("prefix")
+	namespace := c.QueryParam("namespace")
+	apiKey := auth.FromContext(c.Request().Context())
+	if namespace != "" && apiKey != nil && !apiKey.AllowsNamespace(namespace) {
+		return keyForbidden(c)
+	}
+	if !h.allowRequest(c, ratelimit.Read, "") {
+		return rateLimited(c)
+	}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+	after := int64(-1)
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || after < 0 {
+			return badRequest(c, "Invalid Last-Event-ID")
+		}
+	}
+
+	sub, err := h.watch.Subscribe()
+	if errors.Is(err, models.ErrUnavailable) || errors.Is(err, watch.ErrClosed) {
+		return storeUnavailable(c)
+	}
+	if err != nil {
+		return internalError(c, "Failed to watch changes")
+	}
+	defer h.watch.Unsubscribe(sub)
+
+	ctx := c.Request().Context()
+
+	// Work out where to resume before streaming, so failures can still be reported
+	reset := false
+	if after >= 0 {
+		oldest, latest, err := h.watch.ChangeBounds(ctx)
+		if err != nil {
+			return storeError(c, err, "Failed to watch changes")
+		}
+		// Changes after the position were trimmed, or it came from another log
+		reset = after < oldest-1 || after > latest
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	stream := &watchStream{res: res, prefix: prefix, namespace: namespace, apiKey: apiKey}
+
+	switch {
+	case after < 0 || reset:
+		stream.last = sub.Head
+		if reset {
+			stream.write("event: reset\nid: %d\ndata: {}\n\n", stream.last)
+		} else {
+			stream.write("id: %d\n\n", stream.last)
+		}
+	default:
+		// Replay what was missed; changes broadcast meanwhile are deduplicated below
+		stream.last = after
+		for {
+			changes, err := h.watch.ChangesSince(ctx, stream.last, watchReplayPage)
+			if err != nil {
+				return nil
+			}
+			for _, change := range changes {
+				stream.send(change)
+			}
+			if len(changes) < watchReplayPage {
+				break
+			}
+		}
+	}
+	if stream.err != nil {
+		return nil
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case change, ok := <-sub.C:
+			// Closed when we fell behind or the server is shutting down; the client resumes
+			if !ok {
+				return nil
+			}
+			stream.send(change)
+		case <-heartbeat.C:
+			stream.heartbeat()
+		case <-ctx.Done():
+			return nil
+		}
+		if stream.err != nil {
+			return nil
+		}
+		res.Flush()
+	}
+}
+
+// watchStream writes change events matching a watch request's filters
+type watchStream struct {
+	res       *echo.Response
+	prefix    string
+	namespace string
+	// apiKey limits the namespaces sent, if set
+	apiKey *models.APIKey
+
+	// last is the latest change seen; sent reports whether the client has been told it
+	last int64
+	sent bool
+	err  error
+}
+
+// send writes change if it matches the filters and is newer than the last one seen
+func (s *watchStream) send(change *models.Change) {
+	if change.Seq <= s.last {
+		return
+	}
+	s.last = change.Seq
+
+	// A change without a key reports entries removed in bulk, which every watcher receives
+	namespace := models.Namespace(change.Key)
+	if change.Key != "" && !s.matches(change.Key, namespace) {
+		s.sent = false
+		return
+	}
+
+	data, err := json.Marshal(watchEvent{
+		Seq:       change.Seq,
+		Type:      change.Type,
+		Key:       change.Key,
+		Namespace: namespace,
+		Time:      change.Time,
+	})
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.write("id: %d\nevent: %s\ndata: %s\n\n", change.Seq, change.Type, data)
+}
+
+// matches reports whether key, in namespace, passes the stream's filters
+func (s *watchStream) matches(key, namespace string) bool {
+	if !strings.HasPrefix(key, s.prefix) {
+		return false
+	}
+	if s.namespace != "" && namespace != s.namespace {
+		return false
+	}
+	return s.apiKey == nil || s.apiKey.AllowsNamespace(namespace)
+}
+
+// heartbeat keeps the stream open, moving the client's position past changes filtered out
+// since the last event so it does not replay them on reconnect
+func (s *watchStream) heartbeat() {
+	if s.sent {
+		s.write(": ping\n\n")
+	} else {
+		s.write("id: %d\n\n", s.last)
+	}
+}
+
+// write writes one event, recording the position it carries as sent
+func (s *watchStream) write(format string, args ...interface{}) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = fmt.Fprintf(s.res, format, args...)
+	s.sent = true
+}
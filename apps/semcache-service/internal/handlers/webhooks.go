@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+	"github.com/nextinterfaces/semcache-service/internal/webhooks"
+)
+
+// minWebhookSecretLength is the shortest signing secret accepted from callers
+const minWebhookSecretLength = 16
+
+// UseWebhooks enables the webhook endpoints, storing webhooks in store and calling
+// changed after one is created or deleted
+func (h *Handler) UseWebhooks(store models.WebhookStore, changed func(ctx context.Context)) {
+	h.webhooks = store
+	h.webhooksChanged = changed
+}
+
+// CreateWebhook registers a webhook. The response includes the signing secret, which is
+// not returned again.
+func (h *Handler) CreateWebhook(c echo.Context) error {
+	var req models.CreateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	var errs validation.Errors
+	if err := c.Validate(&req); err != nil && !errors.As(err, &errs) {
+		return invalidRequest(c, err)
+	}
+	if errs = append(errs, validateWebhook(&req)...); len(errs) > 0 {
+		return invalidRequest(c, errs)
+	}
+
+	if req.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			return internalError(c, "Failed to create webhook")
+		}
+		req.Secret = secret
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	webhook, err := h.webhooks.CreateWebhook(ctx, req)
+	if err != nil {
+		return storeError(c, err, "Failed to create webhook")
+	}
+	h.notifyWebhooksChanged(ctx)
+
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+// ListWebhooks returns every registered webhook, without secrets
+func (h *Handler) ListWebhooks(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	list, err := h.webhooks.ListWebhooks(ctx)
+	if err != nil {
+		return storeError(c, err, "Failed to list webhooks")
+	}
+
+	for _, webhook := range list {
+		webhook.Secret = ""
+	}
+	if list == nil {
+		list = []*models.Webhook{}
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+// DeleteWebhook removes a webhook and its dead letters
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return badRequest(c, "Invalid webhook id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	err = h.webhooks.DeleteWebhook(ctx, id)
+	if errors.Is(err, models.ErrNotFound) {
+		return notFound(c, "Webhook not found")
+	}
+	if err != nil {
+		return storeError(c, err, "Failed to delete webhook")
+	}
+	h.notifyWebhooksChanged(ctx)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListDeadLetters returns the most recent deliveries given up on for a webhook
+func (h *Handler) ListDeadLetters(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return badRequest(c, "Invalid webhook id")
+	}
+
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			return badRequest(c, "Invalid limit")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	deadLetters, err := h.webhooks.ListDeadLetters(ctx, id, limit)
+	if err != nil {
+		return storeError(c, err, "Failed to list dead letters")
+	}
+	if deadLetters == nil {
+		deadLetters = []*models.DeadLetter{}
+	}
+
+	return c.JSON(http.StatusOK, deadLetters)
+}
+
+// notifyWebhooksChanged reports a created or deleted webhook so it takes effect at once
+func (h *Handler) notifyWebhooksChanged(ctx context.Context) {
+	if h.webhooksChanged != nil {
+		h.webhooksChanged(ctx)
+	}
+}
+
+// validateWebhook checks the parts of a webhook registration that its validate tags do not
+func validateWebhook(req *models.CreateWebhookRequest) validation.Errors {
+	var errs validation.Errors
+	if strings.Contains(req.Namespace, ":") {
+		errs = append(errs, validation.FieldError{Field: "namespace", Message: "must not contain ':'"})
+	}
+
+	if u, err := url.Parse(req.URL); req.URL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs = append(errs, validation.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+
+	for _, event := range req.Events {
+		if !slices.Contains(models.EventTypes, event) {
+			errs = append(errs, validation.FieldError{Field: "events", Message: "has unknown event type " + event})
+			break
+		}
+	}
+	slices.Sort(req.Events)
+	req.Events = slices.Compact(req.Events)
+
+	if req.Secret != "" && len(req.Secret) < minWebhookSecretLength {
+		errs = append(errs, validation.FieldError{
+			Field:   "secret",
+			Message: "must be at least " + strconv.Itoa(minWebhookSecretLength) + " characters",
+		})
+	}
+
+	return errs
+}
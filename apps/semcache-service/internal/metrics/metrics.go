@@ -16,6 +16,8 @@ import (
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -52,6 +54,8 @@ func Init(serviceName, serviceVersion string) (http.Handler, error) {
 	// Ensure instruments are created upfront
 	_, _ = m.Float64Histogram("http_server_duration")
 	_, _ = m.Int64Counter("http_server_requests_total")
+	_, _ = m.Float64Histogram("grpc_server_duration")
+	_, _ = m.Int64Counter("grpc_server_requests_total")
 
 	// Expose the registry via promhttp handler
 	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
@@ -83,3 +87,246 @@ func Middleware() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// UnaryServerInterceptor records gRPC metrics alongside the HTTP ones, by method and status code
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		durMs := float64(time.Since(start).Microseconds()) / 1000.0
+
+		m := otel.Meter("semcache-service")
+		hist, _ := m.Float64Histogram("grpc_server_duration")
+		ctr, _ := m.Int64Counter("grpc_server_requests_total")
+
+		attrs := []attribute.KeyValue{
+			attribute.String("method", info.FullMethod),
+			attribute.String("code", status.Code(err).String()),
+		}
+
+		hist.Record(ctx, durMs, metric.WithAttributes(attrs...))
+		ctr.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+		return resp, err
+	}
+}
+
+// RecordRESPCommand records a command served by the Redis protocol listener
+func RecordRESPCommand(ctx context.Context, command string, ok bool, duration time.Duration) {
+	m := otel.Meter("semcache-service")
+	hist, _ := m.Float64Histogram("resp_server_duration")
+	ctr, _ := m.Int64Counter("resp_server_requests_total")
+
+	status := "ok"
+	if !ok {
+		status = "error"
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("command", command),
+		attribute.String("status", status),
+	)
+
+	hist.Record(ctx, float64(duration.Microseconds())/1000.0, attrs)
+	ctr.Add(ctx, 1, attrs)
+}
+
+// RecordTierResult counts a hit or miss for an operation ("get", "lookup") on a cache tier ("l1", "store")
+func RecordTierResult(ctx context.Context, tier, op string, hit bool) {
+	m := otel.Meter("semcache-service")
+	name := "semcache_tier_misses_total"
+	if hit {
+		name = "semcache_tier_hits_total"
+	}
+	ctr, _ := m.Int64Counter(name)
+
+	ctr.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("tier", tier),
+		attribute.String("operation", op),
+	))
+}
+
+// RecordL1Evictions counts entries evicted from the L1 tier to stay within its byte budget
+func RecordL1Evictions(ctx context.Context, n int) {
+	if n <= 0 {
+		return
+	}
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_l1_evictions_total")
+	ctr.Add(ctx, int64(n))
+}
+
+// RegisterL1Usage exposes the L1 tier's entry count and accounted bytes as gauges
+func RegisterL1Usage(usage func() (entries, bytes int64)) error {
+	m := otel.Meter("semcache-service")
+
+	entriesGauge, err := m.Int64ObservableGauge("semcache_l1_entries")
+	if err != nil {
+		return err
+	}
+	bytesGauge, err := m.Int64ObservableGauge("semcache_l1_bytes")
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		entries, bytes := usage()
+		o.ObserveInt64(entriesGauge, entries)
+		o.ObserveInt64(bytesGauge, bytes)
+		return nil
+	}, entriesGauge, bytesGauge)
+	return err
+}
+
+// RecordInvalidation counts an L1 invalidation received from another replica and records
+// how long the change notification took to arrive
+func RecordInvalidation(ctx context.Context, op string, lag time.Duration) {
+	m := otel.Meter("semcache-service")
+	ctr, _ := m.Int64Counter("semcache_l1_invalidations_total")
+	hist, _ := m.Float64Histogram("semcache_notification_lag")
+
+	// Replica clocks can disagree slightly; never report negative lag
+	if lag < 0 {
+		lag = 0
+	}
+
+	attrs := metric.WithAttributes(attribute.String("operation", op))
+	ctr.Add(ctx, 1, attrs)
+	hist.Record(ctx, float64(lag.Microseconds())/1000.0, attrs)
+}
+
+// RecordBreakerRejection counts a database call rejected by the open circuit breaker
+func RecordBreakerRejection(ctx context.Context, op string) {
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_db_breaker_rejections_total")
+	ctr.Add(ctx, 1, metric.WithAttributes(attribute.String("operation", op)))
+}
+
+// RecordBreakerTransition counts circuit breaker state changes
+func RecordBreakerTransition(ctx context.Context, from, to string) {
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_db_breaker_transitions_total")
+	ctr.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("from", from),
+		attribute.String("to", to),
+	))
+}
+
+// RegisterBreakerState exposes the circuit breaker state as a gauge (0 closed, 1 half-open, 2 open)
+func RegisterBreakerState(state func() int64) error {
+	m := otel.Meter("semcache-service")
+
+	gauge, err := m.Int64ObservableGauge("semcache_db_breaker_state")
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, state())
+		return nil
+	}, gauge)
+	return err
+}
+
+// RecordCompression counts a compressed value and the bytes compression saved on it
+func RecordCompression(ctx context.Context, codec string, originalBytes, storedBytes int) {
+	m := otel.Meter("semcache-service")
+	values, _ := m.Int64Counter("semcache_compressed_values_total")
+	saved, _ := m.Int64Counter("semcache_compression_saved_bytes_total")
+
+	attrs := metric.WithAttributes(attribute.String("codec", codec))
+	values.Add(ctx, 1, attrs)
+	saved.Add(ctx, int64(originalBytes-storedBytes), attrs)
+}
+
+// RecordWebhookDelivery records a webhook delivery attempt by event type and outcome
+// ("delivered", "retry" or "failed")
+func RecordWebhookDelivery(ctx context.Context, eventType, outcome string, duration time.Duration) {
+	m := otel.Meter("semcache-service")
+	hist, _ := m.Float64Histogram("semcache_webhook_delivery_duration")
+	ctr, _ := m.Int64Counter("semcache_webhook_deliveries_total")
+
+	attrs := metric.WithAttributes(
+		attribute.String("event", eventType),
+		attribute.String("outcome", outcome),
+	)
+	hist.Record(ctx, float64(duration.Microseconds())/1000.0, attrs)
+	ctr.Add(ctx, 1, attrs)
+}
+
+// RecordWebhookDeadLetter counts a webhook delivery given up on
+func RecordWebhookDeadLetter(ctx context.Context, eventType string) {
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_webhook_dead_letters_total")
+	ctr.Add(ctx, 1, metric.WithAttributes(attribute.String("event", eventType)))
+}
+
+// RegisterWebhookPending exposes the number of webhook deliveries waiting to be sent or
+// retried as a gauge
+func RegisterWebhookPending(pending func() int64) error {
+	m := otel.Meter("semcache-service")
+
+	gauge, err := m.Int64ObservableGauge("semcache_webhook_pending")
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, pending())
+		return nil
+	}, gauge)
+	return err
+}
+
+// RecordDroppedChanges counts entry events that could not be written to the change log
+func RecordDroppedChanges(ctx context.Context, n int) {
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_watch_dropped_changes_total")
+	ctr.Add(ctx, int64(n))
+}
+
+// RegisterWatchers exposes the number of connected watchers as a gauge
+func RegisterWatchers(watchers func() int64) error {
+	m := otel.Meter("semcache-service")
+
+	gauge, err := m.Int64ObservableGauge("semcache_watchers")
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, watchers())
+		return nil
+	}, gauge)
+	return err
+}
+
+// RecordRateLimit counts a rate limit decision by request class and outcome ("allowed"
+// or "limited")
+func RecordRateLimit(ctx context.Context, class string, allowed bool) {
+	outcome := "allowed"
+	if !allowed {
+		outcome = "limited"
+	}
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_rate_limit_requests_total")
+	ctr.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("class", class),
+		attribute.String("outcome", outcome),
+	))
+}
+
+// RecordQuotaRejection counts a write rejected by a daily quota ("entries" or "bytes")
+func RecordQuotaRejection(ctx context.Context, quota string) {
+	ctr, _ := otel.Meter("semcache-service").Int64Counter("semcache_quota_rejections_total")
+	ctr.Add(ctx, 1, metric.WithAttributes(attribute.String("quota", quota)))
+}
+
+// RegisterRateLimitClients exposes the number of clients being rate limited as a gauge
+func RegisterRateLimitClients(clients func() int64) error {
+	m := otel.Meter("semcache-service")
+
+	gauge, err := m.Int64ObservableGauge("semcache_rate_limit_clients")
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, clients())
+		return nil
+	}, gauge)
+	return err
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Scopes of an API key. The admin scope includes the others.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every API key scope
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// APIKey is a credential for the HTTP API. Only a hash of the key itself is stored.
+type APIKey struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the start of the key, to tell keys apart without revealing them
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// Namespaces restricts the key to entries in these namespaces; empty means any
+	Namespaces []string   `json:"namespaces"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
+	// Key is the key itself; it is only returned when the key is created
+	Key string `json:"key,omitempty"`
+	// Hash identifies the key in the store
+	Hash string `json:"-"`
+}
+
+// CreateAPIKeyRequest creates an API key
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name" validate:"required,max=255"`
+	Scopes     []string   `json:"scopes" validate:"required"`
+	Namespaces []string   `json:"namespaces,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	// CreateAPIKey stores key, which must have its Hash set, and sets its ID and CreatedAt
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	// ListAPIKeys returns every key in id order
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int) error
+	// APIKeyByHash returns the key with the given hash, or ErrNotFound
+	APIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+}
+
+var _ APIKeyStore = (*CacheRepository)(nil)
+
+// HasScope reports whether k grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// AllowsNamespace reports whether k may access entries in namespace
+func (k *APIKey) AllowsNamespace(namespace string) bool {
+	return len(k.Namespaces) == 0 || slices.Contains(k.Namespaces, namespace)
+}
+
+// Expired reports whether k has an expiry that has passed at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
+
+// CreateAPIKey stores an API key
+func (r *CacheRepository) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+	if key.Namespaces == nil {
+		key.Namespaces = []string{}
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO semcache_api_keys (name, key_hash, prefix, scopes, namespaces, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, key.Name, key.Hash, key.Prefix, pq.Array(key.Scopes), pq.Array(key.Namespaces), key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// ListAPIKeys returns every API key in id order
+func (r *CacheRepository) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, key_hash, prefix, scopes, namespaces, created_at, expires_at
+		FROM semcache_api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey removes an API key
+func (r *CacheRepository) DeleteAPIKey(ctx context.Context, id int) error {
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM semcache_api_keys WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// APIKeyByHash returns the API key with the given hash. It reads from the primary, so
+// a new key works at once.
+func (r *CacheRepository) APIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	return scanAPIKey(r.db.QueryRowContext(ctx, `
+		SELECT id, name, key_hash, prefix, scopes, namespaces, created_at, expires_at
+		FROM semcache_api_keys
+		WHERE key_hash = $1
+	`, hash))
+}
+
+// scanAPIKey scans a semcache_api_keys row, returning ErrNotFound if there is none
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	key := &APIKey{}
+	err := row.Scan(&key.ID, &key.Name, &key.Hash, &key.Prefix, pq.Array(&key.Scopes), pq.Array(&key.Namespaces),
+		&key.CreatedAt, &key.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+	return key, nil
+}
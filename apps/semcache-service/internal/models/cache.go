@@ -3,86 +3,477 @@ package models
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+	"github.com/nextinterfaces/semcache-service/internal/envelope"
 )
 
-// CacheEntry represents a semantic cache entry
+// CacheEntry represents a semantic cache entry. Value holds arbitrary bytes; the JSON API
+// carries binary values base64-encoded with ValueEncoding set to "base64".
 type CacheEntry struct {
-	ID        int       `json:"id"`
-	Key       string    `json:"key"`
-	Value     string    `json:"value"`
-	Metadata  string    `json:"metadata,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ID            int        `json:"id"`
+	Key           string     `json:"key"`
+	Value         string     `json:"value"`
+	ValueEncoding string     `json:"value_encoding,omitempty"`
+	ContentType   string     `json:"content_type,omitempty"`
+	Metadata      string     `json:"metadata,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Embedding     []float32  `json:"embedding,omitempty"`
 }
 
-// CreateRequest represents the request to create a cache entry
+// CreateRequest represents the request to create a cache entry. Metadata is limited to
+// 2048 bytes because it is indexed, and Postgres caps the size of btree index entries.
 type CreateRequest struct {
-	Key      string `json:"key" validate:"required"`
-	Value    string `json:"value" validate:"required"`
-	Metadata string `json:"metadata,omitempty"`
-	TTL      *int   `json:"ttl,omitempty"` // TTL in seconds
+	Key           string    `json:"key" validate:"required,key"`
+	Value         string    `json:"value" validate:"required"`
+	ValueEncoding string    `json:"value_encoding,omitempty" validate:"omitempty,oneof=base64"` // "base64" for binary values
+	ContentType   string    `json:"content_type,omitempty" validate:"max=255"`
+	Metadata      string    `json:"metadata,omitempty" validate:"maxbytes=2048"`
+	TTL           *int      `json:"ttl,omitempty" validate:"min=0,max=315360000"` // TTL in seconds, at most ten years
+	Embedding     []float32 `json:"embedding,omitempty"`
 }
 
+// ValueEncodingBase64 marks a JSON value as base64-encoded bytes
+const ValueEncodingBase64 = "base64"
+
 // SearchRequest represents the request to search cache entries
 type SearchRequest struct {
-	Key      string `json:"key,omitempty"`
-	Metadata string `json:"metadata,omitempty"`
+	Key      string `json:"key,omitempty" validate:"max=255"`
+	Metadata string `json:"metadata,omitempty" validate:"maxbytes=2048"`
 	Limit    int    `json:"limit,omitempty"`
+
+	// Namespaces restricts results to entries in these namespaces, if set, before the
+	// limit applies
+	Namespaces []string `json:"-"`
+}
+
+// LookupRequest represents a semantic lookup by embedding similarity
+type LookupRequest struct {
+	Embedding []float32 `json:"embedding" validate:"required"`
+	Threshold float64   `json:"threshold,omitempty" validate:"min=-1,max=1"` // minimum cosine similarity
+	Limit     int       `json:"limit,omitempty"`
+
+	// Namespaces restricts results to entries in these namespaces, if set, before the
+	// limit applies
+	Namespaces []string `json:"-"`
+}
+
+// LookupResult is a cache entry together with its similarity score
+type LookupResult struct {
+	*CacheEntry
+	Score float64 `json:"score"`
+}
+
+var (
+	// ErrNotFound is returned when no live entry exists for a key
+	ErrNotFound = errors.New("cache entry not found")
+	// ErrKeyExists is returned when creating an entry whose key is already taken
+	ErrKeyExists = errors.New("cache entry already exists")
+	// ErrUnavailable is returned when the backing database cannot currently serve requests
+	ErrUnavailable = errors.New("cache storage unavailable")
+)
+
+// IsUnavailable reports whether err means the database could not be reached, rather than
+// that it rejected the operation: ErrUnavailable, or a lost or refused connection
+func IsUnavailable(err error) bool {
+	if errors.Is(err, ErrUnavailable) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	// Class 08 is connection exceptions; 57P01-57P03 are shutdowns and startups
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P02" || pqErr.Code == "57P03"
+	}
+	return false
 }
 
 // CacheRepository handles database operations for cache entries
 type CacheRepository struct {
 	db *sql.DB
+
+	// keyLocks enforces key uniqueness in Create when the table cannot (see UseKeyLocks)
+	keyLocks bool
+
+	// reader picks the pool for read-only queries (see UseReader)
+	reader func() *sql.DB
+
+	// available reports whether the database can take queries (see UseAvailability)
+	available func() bool
+
+	// compressThreshold is the value size from which values are compressed (see UseCompression)
+	compressThreshold int
+
+	// chunkSize is the largest value stored in the semcache row itself (see UseChunking)
+	chunkSize int
+
+	// keyring encrypts values, and metadata if encryptMetadata is set (see UseEncryption)
+	keyring         *envelope.Keyring
+	encryptMetadata bool
+
+	// events receives expired entries removed by the repository (see UseEvents)
+	events func(EntryEvent)
 }
 
+// entryColumns are the columns scanned by scanEntry, in order
+const entryColumns = `id, key, value, content_type, metadata, created_at, expires_at, embedding,
+	value_codec, value_data, encryption_key_id, encrypted_data_key, metadata_encrypted, value_chunks, value_size`
+
+// pgNamespace is the namespace of the key column, as Namespace returns it
+const pgNamespace = `CASE WHEN strpos(key, ':') > 0 THEN split_part(key, ':', 1) ELSE '' END`
+
 // NewCacheRepository creates a new cache repository
 func NewCacheRepository(db *sql.DB) *CacheRepository {
 	return &CacheRepository{db: db}
 }
 
+// UseKeyLocks makes Create serialize writers per key with advisory locks and reject keys
+// that already exist. A partitioned semcache table has no UNIQUE (key) constraint, so this
+// must be enabled whenever partitioning is.
+func (r *CacheRepository) UseKeyLocks() {
+	r.keyLocks = true
+}
+
+// UseReader routes Get, Search and Lookup to the pool returned by reader, typically a
+// read replica. Writes always go to the primary.
+func (r *CacheRepository) UseReader(reader func() *sql.DB) {
+	r.reader = reader
+}
+
+// UseAvailability makes every operation fail fast with ErrUnavailable while available
+// returns false, instead of waiting on a database that is known to be down
+func (r *CacheRepository) UseAvailability(available func() bool) {
+	r.available = available
+}
+
+// checkAvailable returns ErrUnavailable when the database is known to be down
+func (r *CacheRepository) checkAvailable() error {
+	if r.available != nil && !r.available() {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// queryRead runs a read-only query on the reader pool, retrying on the primary if it fails.
+// It returns the pool that answered, from which the chunks of any entry read must be read
+// too, as another pool may not have them yet or any longer.
+func (r *CacheRepository) queryRead(ctx context.Context, query string, args ...interface{}) (*sql.Rows, *sql.DB, error) {
+	db := r.db
+	if r.reader != nil {
+		db = r.reader()
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil && db != r.db && ctx.Err() == nil {
+		rows, err = r.db.QueryContext(ctx, query, args...)
+		return rows, r.db, err
+	}
+	return rows, db, err
+}
+
 // Create creates a new cache entry
 func (r *CacheRepository) Create(ctx context.Context, req CreateRequest) (*CacheEntry, error) {
+	entry, _, err := r.create(ctx, req, false)
+	return entry, err
+}
+
+// Put creates a cache entry, deleting any entry under its key in the same transaction
+func (r *CacheRepository) Put(ctx context.Context, req CreateRequest) (*CacheEntry, bool, error) {
+	var (
+		entry    *CacheEntry
+		replaced bool
+		err      error
+	)
+	for attempt := 0; attempt < 3; attempt++ {
+		entry, replaced, err = r.create(ctx, req, true)
+		if !errors.Is(err, ErrKeyExists) {
+			break
+		}
+		// A Create without key locks inserted the key after the delete; replace that instead
+	}
+	return entry, replaced, err
+}
+
+// create creates a cache entry, replacing a live entry with the same key if replace is
+// set, and reports whether it did
+func (r *CacheRepository) create(ctx context.Context, req CreateRequest, replace bool) (*CacheEntry, bool, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, false, err
+	}
+
+	// Values over the chunk size are chunked as if they had been streamed
+	if r.chunkSize > 0 && len(req.Value) > r.chunkSize {
+		first := []byte(req.Value[:r.chunkSize])
+		entry, replaced, err := r.createChunked(ctx, req, first, strings.NewReader(req.Value[r.chunkSize:]), replace)
+		if err != nil {
+			return nil, false, err
+		}
+		entry.Value = req.Value
+		r.publishChange(ctx, "create", entry.Key)
+		return entry, replaced, nil
+	}
+
+	// created_at and expires_at are TIMESTAMPs holding UTC, compared against
+	// NOW() AT TIME ZONE 'UTC' whatever the session time zone
 	var expiresAt *time.Time
 	if req.TTL != nil && *req.TTL > 0 {
-		expiry := time.Now().Add(time.Duration(*req.TTL) * time.Second)
+		expiry := time.Now().UTC().Add(time.Duration(*req.TTL) * time.Second)
 		expiresAt = &expiry
 	}
 
+	var embedding interface{}
+	if len(req.Embedding) > 0 {
+		embedding = pq.Array(req.Embedding)
+	}
+
+	stored, err := r.encodeValue(ctx, req.Key, req.Value, req.Metadata)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
 	query := `
-		INSERT INTO semcache (key, value, metadata, expires_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, key, value, metadata, created_at, expires_at
+		INSERT INTO semcache (key, value, content_type, metadata, expires_at, embedding,
+			value_codec, value_data, encryption_key_id, encrypted_data_key, metadata_encrypted, value_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, key, created_at, expires_at
 	`
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer tx.Rollback()
+
+	// An expired row the sweeper has not removed yet no longer holds the key, so it is
+	// deleted first. Without a UNIQUE (key) constraint, hold a per-key lock while checking
+	// for an existing row. Replacing deletes the existing row under the same lock.
+	var replaced, expired bool
+	switch {
+	case replace:
+		replaced, expired, err = replaceKey(ctx, tx, req.Key)
+	case r.keyLocks:
+		expired, err = lockKey(ctx, tx, req.Key)
+	default:
+		expired, err = deleteExpired(ctx, tx, req.Key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
 	entry := &CacheEntry{}
-	err := r.db.QueryRowContext(ctx, query, req.Key, req.Value, req.Metadata, expiresAt).Scan(
+	err = tx.QueryRowContext(ctx, query,
+		req.Key, stored.value, req.ContentType, stored.metadata, expiresAt, embedding,
+		stored.codec, stored.data, stored.keyID, stored.dataKey, stored.metadataEncrypted, stored.size,
+	).Scan(
 		&entry.ID,
 		&entry.Key,
-		&entry.Value,
-		&entry.Metadata,
 		&entry.CreatedAt,
 		&entry.ExpiresAt,
 	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, false, ErrKeyExists
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cache entry: %w", err)
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	if expired {
+		publishEvent(r.events, EventExpired, req.Key)
+	}
+	entry.Value = req.Value
+	entry.ContentType = req.ContentType
+	entry.Metadata = req.Metadata
+	entry.Embedding = req.Embedding
+	r.publishChange(ctx, "create", entry.Key)
+
+	return entry, replaced, nil
+}
+
+// Get returns the live cache entry stored under key
+func (r *CacheRepository) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + entryColumns + `
+		FROM semcache
+		WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+	`
+
+	rows, db, err := r.queryRead(ctx, query, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to get cache entry: %w", err)
+		}
+		return nil, ErrNotFound
+	}
+
+	entry, stored, err := r.scanEntry(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	rows.Close()
+
+	if stored.chunks > 0 {
+		if err := r.readChunks(ctx, db, entry, stored); err != nil {
+			return nil, fmt.Errorf("failed to get cache entry: %w", err)
+		}
 	}
 
 	return entry, nil
 }
 
+// Delete removes the cache entry stored under key
+func (r *CacheRepository) Delete(ctx context.Context, key string) error {
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM semcache WHERE key = $1 RETURNING id
+		), chunks AS (
+			DELETE FROM semcache_chunks WHERE entry_id IN (SELECT id FROM deleted)
+		)
+		SELECT COUNT(*) FROM deleted
+	`, key).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	r.publishChange(ctx, "delete", key)
+
+	return nil
+}
+
+// Expire sets the expiry of the live entry stored under key in place
+func (r *CacheRepository) Expire(ctx context.Context, key string, expiresAt *time.Time) error {
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE semcache SET expires_at = $2 WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')",
+		key, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to expire cache entry: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to expire cache entry: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	r.publishChange(ctx, "expire", key)
+
+	return nil
+}
+
+// Lookup returns the live entries most similar to the request embedding by cosine similarity
+func (r *CacheRepository) Lookup(ctx context.Context, req LookupRequest) ([]*LookupResult, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	limit := lookupLimit(req.Limit)
+
+	query := `
+		SELECT * FROM (
+			SELECT ` + entryColumns + `,
+				(SELECT SUM(a * b) / NULLIF(SQRT(SUM(a * a)) * SQRT(SUM(b * b)), 0)
+				 FROM unnest(s.embedding, $1::real[]) AS t(a, b)) AS score
+			FROM semcache s
+			WHERE (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+				AND cardinality(embedding) = cardinality($1::real[])
+				AND ($4::text[] IS NULL OR ` + pgNamespace + ` = ANY($4::text[]))
+		) scored
+		WHERE score >= $2
+		ORDER BY score DESC
+		LIMIT $3
+	`
+
+	var namespaces interface{}
+	if len(req.Namespaces) > 0 {
+		namespaces = pq.Array(req.Namespaces)
+	}
+	rows, db, err := r.queryRead(ctx, query, pq.Array(req.Embedding), req.Threshold, limit, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*LookupResult
+	var chunked []chunkedEntry
+	for rows.Next() {
+		result := &LookupResult{}
+		var stored *storedValue
+		result.CacheEntry, stored, err = r.scanEntry(rows, &result.Score)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lookup result: %w", err)
+		}
+		if stored.chunks > 0 {
+			chunked = append(chunked, chunkedEntry{result.CacheEntry, stored})
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lookup results: %w", err)
+	}
+	rows.Close()
+
+	if err := r.readAllChunks(ctx, db, chunked); err != nil {
+		return nil, fmt.Errorf("failed to lookup cache entries: %w", err)
+	}
+
+	return results, nil
+}
+
 // Search searches for cache entries based on criteria
 func (r *CacheRepository) Search(ctx context.Context, req SearchRequest) ([]*CacheEntry, error) {
-	limit := req.Limit
-	if limit <= 0 || limit > 100 {
-		limit = 100
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
 	}
 
+	limit := searchLimit(req.Limit)
+
 	query := `
-		SELECT id, key, value, metadata, created_at, expires_at
+		SELECT ` + entryColumns + `
 		FROM semcache
-		WHERE (expires_at IS NULL OR expires_at > NOW())
+		WHERE (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
 	`
 	args := []interface{}{}
 	argCount := 0
@@ -99,45 +490,155 @@ func (r *CacheRepository) Search(ctx context.Context, req SearchRequest) ([]*Cac
 		args = append(args, "%"+req.Metadata+"%")
 	}
 
+	if len(req.Namespaces) > 0 {
+		argCount++
+		query += fmt.Sprintf(" AND "+pgNamespace+" = ANY($%d)", argCount)
+		args = append(args, pq.Array(req.Namespaces))
+	}
+
 	argCount++
 	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argCount)
 	args = append(args, limit)
 
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	rows, db, err := r.queryRead(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search cache entries: %w", err)
 	}
 	defer rows.Close()
 
 	var entries []*CacheEntry
+	var chunked []chunkedEntry
 	for rows.Next() {
-		entry := &CacheEntry{}
-		err := rows.Scan(
-			&entry.ID,
-			&entry.Key,
-			&entry.Value,
-			&entry.Metadata,
-			&entry.CreatedAt,
-			&entry.ExpiresAt,
-		)
+		entry, stored, err := r.scanEntry(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
 		}
+		if stored.chunks > 0 {
+			chunked = append(chunked, chunkedEntry{entry, stored})
+		}
 		entries = append(entries, entry)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating cache entries: %w", err)
 	}
+	rows.Close()
+
+	if err := r.readAllChunks(ctx, db, chunked); err != nil {
+		return nil, fmt.Errorf("failed to search cache entries: %w", err)
+	}
 
 	return entries, nil
 }
 
 // HealthCheck performs a simple query to check database connectivity
 func (r *CacheRepository) HealthCheck(ctx context.Context) error {
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+
 	query := "SELECT 1"
 	var result int
 	err := r.db.QueryRowContext(ctx, query).Scan(&result)
 	return err
 }
 
+// scanEntry scans a row selected as entryColumns followed by extra, decoding the value.
+// The stored row is returned too; chunked values are left empty for readChunks.
+func (r *CacheRepository) scanEntry(rows *sql.Rows, extra ...interface{}) (*CacheEntry, *storedValue, error) {
+	entry := &CacheEntry{}
+	stored := &storedValue{}
+
+	dest := append([]interface{}{
+		&entry.ID,
+		&entry.Key,
+		&stored.value,
+		&entry.ContentType,
+		&stored.metadata,
+		&entry.CreatedAt,
+		&entry.ExpiresAt,
+		pq.Array(&entry.Embedding),
+		&stored.codec,
+		&stored.data,
+		&stored.keyID,
+		&stored.dataKey,
+		&stored.metadataEncrypted,
+		&stored.chunks,
+		&stored.size,
+	}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := r.decodeValue(entry, stored); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode value for key %q: %w", entry.Key, err)
+	}
+
+	return entry, stored, nil
+}
+
+// lockKey serializes creators of key until tx ends and fails with ErrKeyExists if a live
+// entry already has the key, deleting an expired one. It enforces key uniqueness when the
+// table has no UNIQUE (key) constraint, and reports whether an expired entry was deleted.
+func lockKey(ctx context.Context, tx *sql.Tx, key string) (bool, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+		return false, fmt.Errorf("failed to lock cache key: %w", err)
+	}
+
+	expired, err := deleteExpired(ctx, tx, key)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM semcache WHERE key = $1)", key).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check cache key: %w", err)
+	}
+	if exists {
+		return false, ErrKeyExists
+	}
+	return expired, nil
+}
+
+// deleteExpired deletes the entry under key, and its chunks, if it has expired, so that a
+// new entry can take the key before the sweeper removes it. It reports whether it did.
+func deleteExpired(ctx context.Context, tx *sql.Tx, key string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM semcache WHERE key = $1 AND expires_at <= NOW() AT TIME ZONE 'UTC' RETURNING id
+		), chunks AS (
+			DELETE FROM semcache_chunks WHERE entry_id IN (SELECT id FROM deleted)
+		)
+		SELECT COUNT(*) FROM deleted
+	`, key).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired cache entry: %w", err)
+	}
+	return n > 0, nil
+}
+
+// replaceKey locks key until tx ends, as lockKey does, then deletes its entry and chunks,
+// reporting whether the deleted entry was live or expired
+func replaceKey(ctx context.Context, tx *sql.Tx, key string) (bool, bool, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+		return false, false, fmt.Errorf("failed to lock cache key: %w", err)
+	}
+
+	var live, expired bool
+	err := tx.QueryRowContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM semcache WHERE key = $1 RETURNING id, expires_at
+		), chunks AS (
+			DELETE FROM semcache_chunks WHERE entry_id IN (SELECT id FROM deleted)
+		)
+		SELECT
+			EXISTS (SELECT 1 FROM deleted WHERE expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC'),
+			EXISTS (SELECT 1 FROM deleted WHERE expires_at <= NOW() AT TIME ZONE 'UTC')
+	`, key).Scan(&live, &expired)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to replace cache entry: %w", err)
+	}
+	return live, expired, nil
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Change is an entry event recorded in the change log
+type Change struct {
+	Seq  int64     `json:"seq"`
+	Type string    `json:"type"`
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+}
+
+// ChangeLog records entry events under an increasing sequence so watchers can catch up
+// on what they missed
+type ChangeLog interface {
+	// AppendChanges records events, assigning sequences in commit order so that a change
+	// never becomes visible below one already read
+	AppendChanges(ctx context.Context, events []EntryEvent) error
+	// ChangesSince returns up to limit changes with a sequence above after, in order
+	ChangesSince(ctx context.Context, after int64, limit int) ([]*Change, error)
+	// ChangeBounds returns the oldest and latest sequence in the log, or zeros if it is empty
+	ChangeBounds(ctx context.Context) (oldest, latest int64, err error)
+	// TrimChanges removes changes older than maxAge, always keeping the latest one so the
+	// sequence remains known
+	TrimChanges(ctx context.Context, maxAge time.Duration) (int, error)
+}
+
+var _ ChangeLog = (*CacheRepository)(nil)
+
+// changeLogLockID is the pg_advisory_xact_lock key serializing appends to the change log.
+// It is outside the int4 range of the per-key locks taken on hashtext(key).
+const changeLogLockID = 0x73656d6368616e67 // "semchang"
+
+// AppendChanges records events in one statement, so their sequences are contiguous.
+// Appends from every replica hold a lock until they commit, so sequences are assigned in
+// commit order: once a change is visible, every change with a lower sequence is too, and
+// pollers never skip one committed late.
+func (r *CacheRepository) AppendChanges(ctx context.Context, events []EntryEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+
+	values := make([]string, 0, len(events))
+	args := make([]interface{}, 0, 2*len(events))
+	for _, event := range events {
+		values = append(values, fmt.Sprintf("($%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, event.Type, event.Key)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to append changes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", changeLogLockID); err != nil {
+		return fmt.Errorf("failed to lock change log: %w", err)
+	}
+	query := "INSERT INTO semcache_changes (type, key) VALUES " + strings.Join(values, ", ")
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to append changes: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to append changes: %w", err)
+	}
+	return nil
+}
+
+// ChangesSince returns up to limit changes after the given sequence. It reads from the
+// primary, since a lagging replica would make watchers skip changes.
+func (r *CacheRepository) ChangesSince(ctx context.Context, after int64, limit int) ([]*Change, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT seq, type, key, created_at
+		FROM semcache_changes
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*Change
+	for rows.Next() {
+		change := &Change{}
+		if err := rows.Scan(&change.Seq, &change.Type, &change.Key, &change.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ChangeBounds returns the oldest and latest sequence in the change log
+func (r *CacheRepository) ChangeBounds(ctx context.Context) (int64, int64, error) {
+	if err := r.checkAvailable(); err != nil {
+		return 0, 0, err
+	}
+
+	var oldest, latest int64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM semcache_changes",
+	).Scan(&oldest, &latest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read change bounds: %w", err)
+	}
+	return oldest, latest, nil
+}
+
+// TrimChanges removes changes older than maxAge, except the latest
+func (r *CacheRepository) TrimChanges(ctx context.Context, maxAge time.Duration) (int, error) {
+	if err := r.checkAvailable(); err != nil {
+		return 0, err
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM semcache_changes
+		WHERE created_at < NOW() - make_interval(secs => $1)
+		AND seq < (SELECT MAX(seq) FROM semcache_changes)
+	`, maxAge.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim changes: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim changes: %w", err)
+	}
+	return int(n), nil
+}
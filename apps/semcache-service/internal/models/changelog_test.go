@@ -0,0 +1,71 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// TestChangeLogCommitOrder checks that a change appended while an earlier append is still
+// uncommitted gets the higher sequence, so pollers never skip the earlier one
+func TestChangeLogCommitOrder(t *testing.T) {
+	db := testPostgres(t)
+	if db == nil {
+		t.Skip("SEMCACHE_TEST_POSTGRES is not set")
+	}
+	ctx := context.Background()
+	repo := models.NewCacheRepository(db.DB)
+	key := fmt.Sprintf("changes%d", time.Now().UnixNano())
+
+	_, head, err := repo.ChangeBounds(ctx)
+	if err != nil {
+		t.Fatalf("failed to read change bounds: %v", err)
+	}
+
+	// An append from another replica that has not committed yet
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", int64(0x73656d6368616e67)); err != nil {
+		t.Fatalf("failed to lock change log: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO semcache_changes (type, key) VALUES ('created', $1)", key+":first"); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- repo.AppendChanges(ctx, []models.EntryEvent{{Type: models.EventCreated, Key: key + ":second"}})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("append finished before the earlier one committed: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	changes, err := repo.ChangesSince(ctx, head, 1000)
+	if err != nil {
+		t.Fatalf("failed to read changes: %v", err)
+	}
+	var order []string
+	for _, change := range changes {
+		if change.Key == key+":first" || change.Key == key+":second" {
+			order = append(order, change.Key)
+		}
+	}
+	if len(order) != 2 || order[0] != key+":first" {
+		t.Fatalf("changes in sequence order = %v, want first then second", order)
+	}
+}
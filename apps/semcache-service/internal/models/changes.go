@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+)
+
+// ChangeChannel is the Postgres NOTIFY channel on which writes are published
+const ChangeChannel = "semcache_changes"
+
+// ChangeEvent describes a write to a cache entry so other replicas can evict it
+type ChangeEvent struct {
+	Op     string    `json:"op"` // "create", "expire", "delete", "invalidate" or "flush"
+	Key    string    `json:"key"`
+	Origin string    `json:"origin"`
+	Time   time.Time `json:"time"`
+}
+
+// instanceID identifies this process as the origin of published changes
+var instanceID = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// InstanceID returns the origin recorded on changes published by this process
+func InstanceID() string {
+	return instanceID
+}
+
+// Change ops published without a write: "invalidate" evicts one key from other replicas'
+// L1, and "flush" (with no key) empties it
+const (
+	ChangeInvalidate = "invalidate"
+	ChangeFlush      = "flush"
+)
+
+// publishChange notifies listening replicas of a write. Failures are logged rather than
+// returned because the write itself has already succeeded.
+func (r *CacheRepository) publishChange(ctx context.Context, op, key string) {
+	if err := r.notifyChange(ctx, op, key); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("Failed to publish cache change for key %q: %v", key, err))
+	}
+}
+
+// PublishInvalidation asks the other replicas to evict key from their L1, or to flush it
+// entirely when key is empty
+func (r *CacheRepository) PublishInvalidation(ctx context.Context, key string) error {
+	op := ChangeInvalidate
+	if key == "" {
+		op = ChangeFlush
+	}
+	if err := r.notifyChange(ctx, op, key); err != nil {
+		return fmt.Errorf("failed to publish invalidation: %w", err)
+	}
+	return nil
+}
+
+// notifyChange publishes a change event on ChangeChannel
+func (r *CacheRepository) notifyChange(ctx context.Context, op, key string) error {
+	payload, err := json.Marshal(ChangeEvent{
+		Op:     op,
+		Key:    key,
+		Origin: instanceID,
+		Time:   time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", ChangeChannel, string(payload))
+	return err
+}
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// chunkedEntry is a scanned entry whose value is still in semcache_chunks
+type chunkedEntry struct {
+	entry  *CacheEntry
+	stored *storedValue
+}
+
+// UseChunking splits values larger than chunkSize bytes across rows of semcache_chunks, so
+// no single row holds the whole value. CreateStream also writes them as they are read, so
+// the server does not hold the whole value either.
+func (r *CacheRepository) UseChunking(chunkSize int) {
+	r.chunkSize = chunkSize
+}
+
+// CreateStream creates an entry from value. Values that fit in one chunk are stored like
+// Create stores them; larger ones are written chunk by chunk as they are read, in a single
+// transaction, so a failed or abandoned upload leaves nothing behind.
+func (r *CacheRepository) CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error) {
+	if r.chunkSize <= 0 {
+		return createFromStream(ctx, req, value, r.Create)
+	}
+
+	buf := make([]byte, r.chunkSize)
+	n, err := io.ReadFull(value, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		req.Value = string(buf[:n])
+		entry, err := r.Create(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		entry.Value = ""
+		return entry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	entry, _, err := r.createChunked(ctx, req, buf, value, false)
+	if err != nil {
+		return nil, err
+	}
+	r.publishChange(ctx, "create", entry.Key)
+
+	return entry, nil
+}
+
+// createChunked stores a value whose first chunk is already in first and whose remainder
+// is read from rest, replacing a live entry with the same key if replace is set, and
+// reports whether it did. first is reused as the buffer for the remaining chunks.
+func (r *CacheRepository) createChunked(ctx context.Context, req CreateRequest, first []byte, rest io.Reader, replace bool) (*CacheEntry, bool, error) {
+	var expiresAt *time.Time
+	if req.TTL != nil && *req.TTL > 0 {
+		expiry := time.Now().UTC().Add(time.Duration(*req.TTL) * time.Second)
+		expiresAt = &expiry
+	}
+
+	var embedding interface{}
+	if len(req.Embedding) > 0 {
+		embedding = pq.Array(req.Embedding)
+	}
+
+	stored, dataKey, err := r.newStoredValue(req.Key, req.Metadata)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer tx.Rollback()
+
+	var replaced, expired bool
+	switch {
+	case replace:
+		replaced, expired, err = replaceKey(ctx, tx, req.Key)
+	case r.keyLocks:
+		expired, err = lockKey(ctx, tx, req.Key)
+	default:
+		expired, err = deleteExpired(ctx, tx, req.Key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry := &CacheEntry{ContentType: req.ContentType, Metadata: req.Metadata, Embedding: req.Embedding}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO semcache (key, value, content_type, metadata, expires_at, embedding,
+			encryption_key_id, encrypted_data_key, metadata_encrypted)
+		VALUES ($1, '', $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, key, created_at, expires_at
+	`, req.Key, req.ContentType, stored.metadata, expiresAt, embedding,
+		stored.keyID, stored.dataKey, stored.metadataEncrypted,
+	).Scan(&entry.ID, &entry.Key, &entry.CreatedAt, &entry.ExpiresAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, false, ErrKeyExists
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
+	// Write each chunk before reading the next, reusing the buffer
+	chunk := first
+	seq := 0
+	var size int64
+	for len(chunk) > 0 {
+		codec, data, err := r.encodeBytes(ctx, chunk, dataKey, chunkAAD(entry.ID, req.Key, seq))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to encode chunk: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO semcache_chunks (entry_id, seq, codec, data) VALUES ($1, $2, $3, $4)",
+			entry.ID, seq, codec, data,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to write chunk: %w", err)
+		}
+		seq++
+		size += int64(len(chunk))
+
+		n, err := io.ReadFull(rest, first)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, false, err
+		}
+		chunk = first[:n]
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE semcache SET value_chunks = $3, value_size = $4 WHERE id = $1 AND created_at = $2",
+		entry.ID, entry.CreatedAt, seq, size,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	if expired {
+		publishEvent(r.events, EventExpired, req.Key)
+	}
+
+	return entry, replaced, nil
+}
+
+// GetStream opens the value stored under key. Chunked values are fetched one chunk at a
+// time as the stream is read.
+func (r *CacheRepository) GetStream(ctx context.Context, key string) (*ValueStream, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	rows, db, err := r.queryRead(ctx, `
+		SELECT `+entryColumns+`
+		FROM semcache
+		WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+	`, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to get cache entry: %w", err)
+		}
+		return nil, ErrNotFound
+	}
+
+	entry, stored, err := r.scanEntry(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	rows.Close()
+
+	if stored.chunks == 0 {
+		return streamEntry(entry), nil
+	}
+
+	reader, err := r.openChunks(ctx, db, entry, stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	return &ValueStream{CacheEntry: entry, ReadCloser: reader, Size: stored.size}, nil
+}
+
+// readChunks reads a whole chunked value into entry.Value from db, the pool its row was
+// read from
+func (r *CacheRepository) readChunks(ctx context.Context, db *sql.DB, entry *CacheEntry, stored *storedValue) error {
+	reader, err := r.openChunks(ctx, db, entry, stored)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	entry.Value = string(value)
+	return nil
+}
+
+// readAllChunks fills in the values of entries scanned from db with chunked values
+func (r *CacheRepository) readAllChunks(ctx context.Context, db *sql.DB, chunked []chunkedEntry) error {
+	for _, c := range chunked {
+		if err := r.readChunks(ctx, db, c.entry, c.stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openChunks returns a reader over the chunks of a chunked value, read from db, the pool
+// its row was read from
+func (r *CacheRepository) openChunks(ctx context.Context, db *sql.DB, entry *CacheEntry, stored *storedValue) (io.ReadCloser, error) {
+	dataKey, err := r.unwrapDataKey(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode value for key %q: %w", entry.Key, err)
+	}
+	return &chunkReader{ctx: ctx, db: db, id: entry.ID, key: entry.Key, chunks: stored.chunks, dataKey: dataKey}, nil
+}
+
+// chunkReader reads a chunked value one chunk at a time. Chunks are read from the same pool
+// as the entry's row: a row and its chunks are committed together, so a replica that has
+// the row has every chunk, while another pool may have already deleted or replaced them.
+type chunkReader struct {
+	ctx     context.Context
+	db      *sql.DB
+	id      int
+	key     string
+	chunks  int
+	dataKey []byte
+
+	seq int
+	buf []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.seq >= c.chunks {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// next fetches and decodes the next chunk into buf
+func (c *chunkReader) next() error {
+	var codec string
+	var data []byte
+	err := c.db.QueryRowContext(c.ctx,
+		"SELECT codec, data FROM semcache_chunks WHERE entry_id = $1 AND seq = $2",
+		c.id, c.seq,
+	).Scan(&codec, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &codecError{fmt.Errorf("chunk %d of key %q is missing", c.seq, c.key)}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %d of key %q: %w", c.seq, c.key, err)
+	}
+
+	c.buf, err = decodeChunk(codec, data, c.dataKey, c.id, c.key, c.seq)
+	if err != nil {
+		return fmt.Errorf("failed to decode chunk %d of key %q: %w", c.seq, c.key, err)
+	}
+	c.seq++
+	return nil
+}
+
+func (c *chunkReader) Close() error {
+	c.buf = nil
+	c.seq = c.chunks
+	return nil
+}
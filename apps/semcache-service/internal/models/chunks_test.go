@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// TestCreateChunksLargeValues checks that Create and Put chunk values over the chunk size,
+// like CreateStream does, and that they read back whole
+func TestCreateChunksLargeValues(t *testing.T) {
+	db := testPostgres(t)
+	if db == nil {
+		t.Skip("SEMCACHE_TEST_POSTGRES is not set")
+	}
+	ctx := context.Background()
+	repo := models.NewCacheRepository(db.DB)
+	repo.UseChunking(16)
+
+	prefix := fmt.Sprintf("chunks%d:", time.Now().UnixNano())
+	value := strings.Repeat("0123456789", 10)
+	ops := map[string]func(key string) (*models.CacheEntry, error){
+		"create": func(key string) (*models.CacheEntry, error) {
+			return repo.Create(ctx, models.CreateRequest{Key: key, Value: value})
+		},
+		"put": func(key string) (*models.CacheEntry, error) {
+			entry, _, err := repo.Put(ctx, models.CreateRequest{Key: key, Value: value})
+			return entry, err
+		},
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			key := prefix + name
+			entry, err := op(key)
+			if err != nil {
+				t.Fatalf("failed to write %s: %v", key, err)
+			}
+
+			var chunks int
+			if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM semcache_chunks WHERE entry_id = $1", entry.ID).Scan(&chunks); err != nil {
+				t.Fatalf("failed to count chunks: %v", err)
+			}
+			if want := (len(value) + 15) / 16; chunks != want {
+				t.Errorf("chunk rows = %d, want %d", chunks, want)
+			}
+
+			got, err := repo.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", key, err)
+			}
+			if got.Value != value {
+				t.Errorf("value = %q, want %q", got.Value, value)
+			}
+		})
+	}
+}
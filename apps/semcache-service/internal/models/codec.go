@@ -0,0 +1,286 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nextinterfaces/semcache-service/internal/envelope"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+)
+
+// Value codecs recorded per row in value_codec. Codecs are applied in order and joined
+// with "+", so "gzip+aes-gcm" is a compressed value that was then encrypted.
+const (
+	CodecNone   = ""
+	CodecGzip   = "gzip"
+	CodecAESGCM = "aes-gcm"
+)
+
+// codecError is a failure to encode or decode a stored value, such as an unknown master
+// key, a failed authentication check or corrupt compressed data. It concerns the data
+// rather than the health of the database (see isDatabaseFailure).
+type codecError struct {
+	err error
+}
+
+func (e *codecError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codecError) Unwrap() error {
+	return e.err
+}
+
+// storedValue is how a value and its metadata are laid out in a semcache row. Values that
+// are not valid text for a TEXT column are kept in value_data even when no codec applies,
+// and values larger than a chunk are kept in semcache_chunks.
+type storedValue struct {
+	value             string         // value; empty when the value is in value_data or chunked
+	metadata          string         // metadata; empty when metadata is encrypted
+	codec             string         // value_codec
+	data              []byte         // value_data: the encoded value
+	keyID             sql.NullString // encryption_key_id: master key that wrapped dataKey
+	dataKey           []byte         // encrypted_data_key
+	metadataEncrypted []byte         // metadata_encrypted
+	chunks            int            // value_chunks
+	size              int64          // value_size
+}
+
+// UseCompression stores values of at least threshold bytes gzip-compressed. Values that do
+// not shrink are stored as-is. Reads decompress transparently whatever the setting.
+func (r *CacheRepository) UseCompression(threshold int) {
+	r.compressThreshold = threshold
+}
+
+// UseEncryption encrypts every value written, and metadata too if encryptMetadata is set,
+// with AES-GCM under a fresh data key wrapped by the keyring's primary master key. Rows
+// written before encryption was enabled stay readable until Reencrypt rewrites them.
+// Encrypted metadata no longer matches metadata searches.
+func (r *CacheRepository) UseEncryption(keyring *envelope.Keyring, encryptMetadata bool) {
+	r.keyring = keyring
+	r.encryptMetadata = encryptMetadata
+}
+
+// encodeValue compresses and encrypts a value and its metadata as configured
+func (r *CacheRepository) encodeValue(ctx context.Context, key, value, metadata string) (*storedValue, error) {
+	stored, dataKey, err := r.newStoredValue(key, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, data, err := r.encodeBytes(ctx, []byte(value), dataKey, valueAAD(key))
+	if err != nil {
+		return nil, err
+	}
+
+	stored.size = int64(len(value))
+	switch {
+	case codec != CodecNone:
+		stored.codec = codec
+		stored.data = data
+	case !isText(value):
+		stored.data = data
+	default:
+		stored.value = value
+	}
+
+	return stored, nil
+}
+
+// newStoredValue prepares a row for key without its value: it creates the row's data key
+// when encryption is enabled and stores metadata, encrypted if configured. The returned
+// data key is nil when values are not encrypted.
+func (r *CacheRepository) newStoredValue(key, metadata string) (*storedValue, []byte, error) {
+	stored := &storedValue{metadata: metadata}
+	if r.keyring == nil {
+		return stored, nil, nil
+	}
+
+	dataKey, wrapped, keyID, err := r.keyring.NewDataKey()
+	if err != nil {
+		return nil, nil, &codecError{fmt.Errorf("failed to create data key: %w", err)}
+	}
+	stored.keyID = sql.NullString{String: keyID, Valid: true}
+	stored.dataKey = wrapped
+
+	if r.encryptMetadata && metadata != "" {
+		stored.metadataEncrypted, err = envelope.Seal(dataKey, []byte(metadata), metadataAAD(key))
+		if err != nil {
+			return nil, nil, &codecError{fmt.Errorf("failed to encrypt metadata: %w", err)}
+		}
+		stored.metadata = ""
+	}
+
+	return stored, dataKey, nil
+}
+
+// encodeBytes compresses data if configured and worthwhile, then encrypts it if dataKey is
+// set, and returns the codecs applied
+func (r *CacheRepository) encodeBytes(ctx context.Context, data, dataKey, aad []byte) (string, []byte, error) {
+	var codecs []string
+
+	if r.compressThreshold > 0 && len(data) >= r.compressThreshold {
+		compressed, err := compressValue(data)
+		if err != nil {
+			return "", nil, &codecError{err}
+		}
+		if len(compressed) < len(data) {
+			metrics.RecordCompression(ctx, CodecGzip, len(data), len(compressed))
+			data = compressed
+			codecs = append(codecs, CodecGzip)
+		}
+	}
+
+	if dataKey != nil {
+		var err error
+		if data, err = envelope.Seal(dataKey, data, aad); err != nil {
+			return "", nil, &codecError{fmt.Errorf("failed to encrypt value: %w", err)}
+		}
+		codecs = append(codecs, CodecAESGCM)
+	}
+
+	return strings.Join(codecs, "+"), data, nil
+}
+
+// decodeValue fills entry.Value and entry.Metadata from a stored row. Chunked values are
+// left empty; they are read with openChunks.
+func (r *CacheRepository) decodeValue(entry *CacheEntry, stored *storedValue) error {
+	entry.Value = stored.value
+	entry.Metadata = stored.metadata
+	if stored.codec == CodecNone && stored.data != nil {
+		entry.Value = string(stored.data)
+	}
+	if stored.codec == CodecNone && stored.metadataEncrypted == nil {
+		return nil
+	}
+
+	dataKey, err := r.unwrapDataKey(stored)
+	if err != nil {
+		return err
+	}
+
+	if stored.metadataEncrypted != nil {
+		if dataKey == nil {
+			return &codecError{fmt.Errorf("metadata is encrypted but has no data key")}
+		}
+		metadata, err := envelope.Open(dataKey, stored.metadataEncrypted, metadataAAD(entry.Key))
+		if err != nil {
+			return &codecError{fmt.Errorf("failed to decrypt metadata: %w", err)}
+		}
+		entry.Metadata = string(metadata)
+	}
+
+	if stored.codec == CodecNone {
+		return nil
+	}
+
+	value, err := decodeBytes(stored.codec, stored.data, dataKey, valueAAD(entry.Key))
+	if err != nil {
+		return err
+	}
+	entry.Value = string(value)
+
+	return nil
+}
+
+// unwrapDataKey returns the row's data key, or nil if the row is not encrypted
+func (r *CacheRepository) unwrapDataKey(stored *storedValue) ([]byte, error) {
+	if !stored.keyID.Valid {
+		return nil, nil
+	}
+	if r.keyring == nil {
+		return nil, &codecError{fmt.Errorf("value is encrypted but no master keys are configured")}
+	}
+
+	dataKey, err := r.keyring.UnwrapDataKey(stored.keyID.String, stored.dataKey)
+	if err != nil {
+		return nil, &codecError{fmt.Errorf("failed to unwrap data key: %w", err)}
+	}
+	return dataKey, nil
+}
+
+// decodeBytes undoes the codecs applied by encodeBytes, in reverse order
+func decodeBytes(codec string, data, dataKey, aad []byte) ([]byte, error) {
+	if codec == CodecNone {
+		return data, nil
+	}
+
+	codecs := strings.Split(codec, "+")
+	for i := len(codecs) - 1; i >= 0; i-- {
+		var err error
+		switch codecs[i] {
+		case CodecGzip:
+			data, err = decompressValue(data)
+		case CodecAESGCM:
+			if dataKey == nil {
+				return nil, &codecError{fmt.Errorf("value is encrypted but has no data key")}
+			}
+			data, err = envelope.Open(dataKey, data, aad)
+		default:
+			err = fmt.Errorf("unknown value codec %q", codecs[i])
+		}
+		if err != nil {
+			return nil, &codecError{err}
+		}
+	}
+
+	return data, nil
+}
+
+// isText reports whether value can be stored in a Postgres TEXT column, which rejects
+// invalid UTF-8 and NUL bytes
+func isText(value string) bool {
+	return utf8.ValidString(value) && strings.IndexByte(value, 0) < 0
+}
+
+// valueAAD binds an encrypted value to its key so ciphertexts cannot be moved between rows
+func valueAAD(key string) []byte {
+	return []byte("value:" + key)
+}
+
+// metadataAAD binds encrypted metadata to its key, distinct from the value
+func metadataAAD(key string) []byte {
+	return []byte("metadata:" + key)
+}
+
+// chunkAAD binds an encrypted chunk to its entry, key and position, so chunks cannot be
+// moved between entries, even between entries that reused a key
+func chunkAAD(id int, key string, seq int) []byte {
+	return []byte("chunk:" + strconv.Itoa(id) + ":" + strconv.Itoa(seq) + ":" + key)
+}
+
+// decodeChunk undoes the codecs of chunk seq of entry id
+func decodeChunk(codec string, data, dataKey []byte, id int, key string, seq int) ([]byte, error) {
+	return decodeBytes(codec, data, dataKey, chunkAAD(id, key, seq))
+}
+
+// compressValue gzips data
+func compressValue(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressValue gunzips data
+func decompressValue(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
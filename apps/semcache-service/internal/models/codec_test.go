@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nextinterfaces/semcache-service/internal/envelope"
+)
+
+func TestDecodeChunk(t *testing.T) {
+	dataKey := make([]byte, 32)
+	seal := func(aad []byte) []byte {
+		t.Helper()
+		data, err := envelope.Seal(dataKey, []byte("chunk"), aad)
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"same entry", seal(chunkAAD(1, "k", 0)), true},
+		{"other entry with the same key", seal(chunkAAD(2, "k", 0)), false},
+		{"other position", seal(chunkAAD(1, "k", 1)), false},
+		{"other key", seal(chunkAAD(1, "other", 0)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := decodeChunk(CodecAESGCM, tt.data, dataKey, 1, "k", 0)
+			if !tt.ok {
+				var codecErr *codecError
+				if !errors.As(err, &codecErr) {
+					t.Fatalf("decodeChunk error = %v, want a codec error", err)
+				}
+				return
+			}
+			if err != nil || string(value) != "chunk" {
+				t.Fatalf("decodeChunk = %q, %v, want %q", value, err, "chunk")
+			}
+		})
+	}
+}
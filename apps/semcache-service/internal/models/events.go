@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Entry event types, as reported to webhooks
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+	EventExpired = "expired" // removed after its TTL passed
+	EventEvicted = "evicted" // removed early to make room
+)
+
+// EventTypes lists every entry event type
+var EventTypes = []string{EventCreated, EventUpdated, EventDeleted, EventExpired, EventEvicted}
+
+// EntryEvent reports a change to a cache entry
+type EntryEvent struct {
+	Type string
+	// Key is empty for an evicted event reporting entries removed in bulk, such as when a
+	// partition is dropped, which concerns every key
+	Key string
+	// Entry is the entry as written for created and updated events, without its value.
+	// It is nil for an update that only changed the expiry.
+	Entry *CacheEntry
+	Time  time.Time
+}
+
+// EventStore is a Store that reports entries created, updated and deleted through it.
+// Backends report expired and evicted entries themselves (see UseEvents on each).
+type EventStore struct {
+	Store
+	publish func(EntryEvent)
+}
+
+// NewEventStore wraps next, calling publish after each successful write. publish must
+// not block.
+func NewEventStore(next Store, publish func(EntryEvent)) *EventStore {
+	return &EventStore{Store: next, publish: publish}
+}
+
+// Create creates the entry and reports it as created
+func (s *EventStore) Create(ctx context.Context, req CreateRequest) (*CacheEntry, error) {
+	entry, err := s.Store.Create(ctx, req)
+	if err == nil {
+		s.emit(EventCreated, req.Key, entry)
+	}
+	return entry, err
+}
+
+// Put writes the entry and reports it as updated if it replaced a live entry, and
+// otherwise as created
+func (s *EventStore) Put(ctx context.Context, req CreateRequest) (*CacheEntry, bool, error) {
+	entry, replaced, err := s.Store.Put(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if replaced {
+		s.emit(EventUpdated, req.Key, entry)
+	} else {
+		s.emit(EventCreated, req.Key, entry)
+	}
+	return entry, replaced, nil
+}
+
+// Expire sets the entry's expiry and reports it as updated
+func (s *EventStore) Expire(ctx context.Context, key string, expiresAt *time.Time) error {
+	if err := s.Store.Expire(ctx, key, expiresAt); err != nil {
+		return err
+	}
+	s.emit(EventUpdated, key, nil)
+	return nil
+}
+
+// CreateStream creates the entry and reports it as created
+func (s *EventStore) CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error) {
+	entry, err := s.Store.CreateStream(ctx, req, value)
+	if err == nil {
+		s.emit(EventCreated, req.Key, entry)
+	}
+	return entry, err
+}
+
+// Delete deletes the entry and reports it as deleted
+func (s *EventStore) Delete(ctx context.Context, key string) error {
+	if err := s.Store.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.emit(EventDeleted, key, nil)
+	return nil
+}
+
+func (s *EventStore) emit(eventType, key string, entry *CacheEntry) {
+	if entry != nil {
+		described := *entry
+		described.Value = ""
+		described.Embedding = nil
+		entry = &described
+	}
+	s.publish(EntryEvent{Type: eventType, Key: key, Entry: entry, Time: time.Now().UTC()})
+}
+
+// publishEvent reports an event generated by a backend itself, if publish is set
+func publishEvent(publish func(EntryEvent), eventType, key string) {
+	if publish != nil {
+		publish(EntryEvent{Type: eventType, Key: key, Time: time.Now().UTC()})
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stats counts live and expired rows. Rows written before value sizes were recorded
+// count their stored size, which is smaller than the value when compressed.
+func (r *CacheRepository) Stats(ctx context.Context) (*Stats, error) {
+	if err := r.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	rows, _, err := r.queryRead(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC'),
+			COUNT(*) FILTER (WHERE expires_at <= NOW() AT TIME ZONE 'UTC'),
+			COALESCE(SUM(CASE
+				WHEN value_size > 0 THEN value_size
+				ELSE octet_length(value) + COALESCE(octet_length(value_data), 0)
+			END) FILTER (WHERE expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC'), 0)
+		FROM semcache
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &Stats{}
+	if rows.Next() {
+		if err := rows.Scan(&stats.Entries, &stats.Expired, &stats.ValueBytes); err != nil {
+			return nil, fmt.Errorf("failed to get cache stats: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get cache stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Export reads live entries in batches by id, so no query or transaction stays open
+// while fn runs. Entries written during the export may or may not be included.
+func (r *CacheRepository) Export(ctx context.Context, prefix string, after int, fn func(*CacheEntry) error) error {
+	if err := r.checkAvailable(); err != nil {
+		return err
+	}
+
+	lastID := after
+	for {
+		entries, err := r.exportBatch(ctx, prefix, lastID)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < exportBatchSize {
+			return nil
+		}
+		lastID = entries[len(entries)-1].ID
+	}
+}
+
+// exportBatch reads the next batch of live entries after lastID, values included
+func (r *CacheRepository) exportBatch(ctx context.Context, prefix string, lastID int) ([]*CacheEntry, error) {
+	rows, db, err := r.queryRead(ctx, `
+		SELECT `+entryColumns+`
+		FROM semcache
+		WHERE id > $1 AND starts_with(key, $2) AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+		ORDER BY id
+		LIMIT $3
+	`, lastID, prefix, exportBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*CacheEntry
+	var chunked []chunkedEntry
+	for rows.Next() {
+		entry, stored, err := r.scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		if stored.chunks > 0 {
+			chunked = append(chunked, chunkedEntry{entry, stored})
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cache entries: %w", err)
+	}
+	rows.Close()
+
+	if err := r.readAllChunks(ctx, db, chunked); err != nil {
+		return nil, fmt.Errorf("failed to export cache entries: %w", err)
+	}
+
+	return entries, nil
+}
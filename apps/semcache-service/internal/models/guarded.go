@@ -0,0 +1,251 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/nextinterfaces/semcache-service/internal/breaker"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+)
+
+// GuardedStore wraps another Store with a circuit breaker and a per-call timeout, so that
+// while the backing database is slow or failing, requests fail fast with ErrUnavailable
+// instead of each waiting for the handler timeout.
+type GuardedStore struct {
+	next        Store
+	cb          *breaker.Breaker
+	callTimeout time.Duration
+
+	// missOnOpen answers reads with a cache miss instead of an error while the breaker is
+	// open (see UseMissOnOpen)
+	missOnOpen bool
+}
+
+var _ Store = (*GuardedStore)(nil)
+
+// NewGuardedStore wraps next with the given breaker. Calls taking longer than callTimeout
+// are cancelled and count as failures. Calls rejected while the breaker is open fail with
+// ErrUnavailable.
+func NewGuardedStore(next Store, cb *breaker.Breaker, callTimeout time.Duration) *GuardedStore {
+	return &GuardedStore{
+		next:        next,
+		cb:          cb,
+		callTimeout: callTimeout,
+	}
+}
+
+// UseMissOnOpen answers Get, GetStream and Lookup as cache misses instead of failing with
+// ErrUnavailable while the breaker is open, for clients that fall back to the source of
+// truth on a miss. A database outage then looks like a miss rather than a 503.
+func (g *GuardedStore) UseMissOnOpen() {
+	g.missOnOpen = true
+}
+
+// Create creates an entry through the breaker
+func (g *GuardedStore) Create(ctx context.Context, req CreateRequest) (*CacheEntry, error) {
+	var entry *CacheEntry
+	err := g.call(ctx, "create", func(ctx context.Context) (err error) {
+		entry, err = g.next.Create(ctx, req)
+		return err
+	})
+	return entry, err
+}
+
+// Put creates or replaces an entry through the breaker
+func (g *GuardedStore) Put(ctx context.Context, req CreateRequest) (*CacheEntry, bool, error) {
+	var (
+		entry    *CacheEntry
+		replaced bool
+	)
+	err := g.call(ctx, "put", func(ctx context.Context) (err error) {
+		entry, replaced, err = g.next.Put(ctx, req)
+		return err
+	})
+	return entry, replaced, err
+}
+
+// Expire sets an entry's expiry through the breaker
+func (g *GuardedStore) Expire(ctx context.Context, key string, expiresAt *time.Time) error {
+	return g.call(ctx, "expire", func(ctx context.Context) error {
+		return g.next.Expire(ctx, key, expiresAt)
+	})
+}
+
+// Get reads an entry through the breaker
+func (g *GuardedStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	var entry *CacheEntry
+	err := g.call(ctx, "get", func(ctx context.Context) (err error) {
+		entry, err = g.next.Get(ctx, key)
+		return err
+	})
+	if g.missOnOpen && errors.Is(err, breaker.ErrOpen) {
+		return nil, ErrNotFound
+	}
+	return entry, err
+}
+
+// CreateStream creates an entry through the breaker. There is no call timeout, as the
+// upload proceeds at the client's pace, and failures reading value are not held against
+// the database.
+func (g *GuardedStore) CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error) {
+	generation, err := g.allow(ctx, "create")
+	if err != nil {
+		return nil, err
+	}
+
+	src := &trackedReader{r: value}
+	entry, err := g.next.CreateStream(ctx, req, src)
+	g.cb.Record(generation, src.err != nil || !isDatabaseFailure(ctx, err))
+	return entry, err
+}
+
+// GetStream opens a value through the breaker. Only opening the stream is guarded, without
+// a call timeout, so that long downloads are not cut short.
+func (g *GuardedStore) GetStream(ctx context.Context, key string) (*ValueStream, error) {
+	generation, err := g.allow(ctx, "get")
+	if err != nil {
+		if g.missOnOpen && errors.Is(err, breaker.ErrOpen) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	stream, err := g.next.GetStream(ctx, key)
+	g.cb.Record(generation, !isDatabaseFailure(ctx, err))
+	return stream, err
+}
+
+// Search searches through the breaker
+func (g *GuardedStore) Search(ctx context.Context, req SearchRequest) ([]*CacheEntry, error) {
+	var entries []*CacheEntry
+	err := g.call(ctx, "search", func(ctx context.Context) (err error) {
+		entries, err = g.next.Search(ctx, req)
+		return err
+	})
+	return entries, err
+}
+
+// Delete deletes an entry through the breaker
+func (g *GuardedStore) Delete(ctx context.Context, key string) error {
+	return g.call(ctx, "delete", func(ctx context.Context) error {
+		return g.next.Delete(ctx, key)
+	})
+}
+
+// Lookup runs a semantic lookup through the breaker
+func (g *GuardedStore) Lookup(ctx context.Context, req LookupRequest) ([]*LookupResult, error) {
+	var results []*LookupResult
+	err := g.call(ctx, "lookup", func(ctx context.Context) (err error) {
+		results, err = g.next.Lookup(ctx, req)
+		return err
+	})
+	if g.missOnOpen && errors.Is(err, breaker.ErrOpen) {
+		return nil, nil
+	}
+	return results, err
+}
+
+// Stats counts entries through the breaker
+func (g *GuardedStore) Stats(ctx context.Context) (*Stats, error) {
+	var stats *Stats
+	err := g.call(ctx, "stats", func(ctx context.Context) (err error) {
+		stats, err = g.next.Stats(ctx)
+		return err
+	})
+	return stats, err
+}
+
+// Export exports through the breaker, without a call timeout, as it proceeds at the pace
+// fn consumes entries. Errors returned by fn are not held against the database.
+func (g *GuardedStore) Export(ctx context.Context, prefix string, after int, fn func(*CacheEntry) error) error {
+	generation, err := g.allow(ctx, "export")
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	err = g.next.Export(ctx, prefix, after, func(entry *CacheEntry) error {
+		fnErr = fn(entry)
+		return fnErr
+	})
+	g.cb.Record(generation, fnErr != nil || !isDatabaseFailure(ctx, err))
+	return err
+}
+
+// HealthCheck bypasses the breaker so probes always see the real database state
+func (g *GuardedStore) HealthCheck(ctx context.Context) error {
+	return g.next.HealthCheck(ctx)
+}
+
+// BreakerState returns the breaker state for health reporting
+func (g *GuardedStore) BreakerState() string {
+	return g.cb.State().String()
+}
+
+// call runs fn if the breaker allows it and records the outcome. Rejected calls return an
+// error matching both ErrUnavailable and breaker.ErrOpen.
+func (g *GuardedStore) call(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	generation, err := g.allow(ctx, op)
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, g.callTimeout)
+	defer cancel()
+
+	err = fn(callCtx)
+	g.cb.Record(generation, !isDatabaseFailure(ctx, err))
+	return err
+}
+
+// allow returns the breaker generation the call is allowed under, or an error matching
+// both ErrUnavailable and breaker.ErrOpen if the breaker rejects the call
+func (g *GuardedStore) allow(ctx context.Context, op string) (uint64, error) {
+	generation, err := g.cb.Allow()
+	if err != nil {
+		metrics.RecordBreakerRejection(ctx, op)
+		return 0, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return generation, nil
+}
+
+// trackedReader remembers the first error returned by the reader it wraps, other than io.EOF
+type trackedReader struct {
+	r   io.Reader
+	err error
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF && t.err == nil {
+		t.err = err
+	}
+	return n, err
+}
+
+// isDatabaseFailure reports whether err indicates an unhealthy database, such as a lost
+// connection, a timeout or a server error, as opposed to a normal outcome (not found,
+// conflict), a stored value that cannot be decoded, a request the database rejected as
+// invalid, or the client giving up on its own request
+func isDatabaseFailure(ctx context.Context, err error) bool {
+	var codecErr *codecError
+	var pqErr *pq.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrKeyExists), errors.As(err, &codecErr):
+		return false
+	case ctx.Err() != nil:
+		return false
+	case errors.As(err, &pqErr):
+		// Class 22 is data exceptions and 23 integrity constraint violations
+		class := pqErr.Code.Class()
+		return class != "22" && class != "23"
+	}
+	return true
+}
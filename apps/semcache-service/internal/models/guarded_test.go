@@ -0,0 +1,123 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/nextinterfaces/semcache-service/internal/breaker"
+	"github.com/nextinterfaces/semcache-service/internal/envelope"
+)
+
+// decodeError returns the error decoding data with codec fails with
+func decodeError(t *testing.T, codec string, data, dataKey []byte) error {
+	t.Helper()
+	_, err := decodeBytes(codec, data, dataKey, valueAAD("k"))
+	if err == nil {
+		t.Fatalf("decoding with %s succeeded", codec)
+	}
+	return err
+}
+
+func TestIsDatabaseFailure(t *testing.T) {
+	keyring, err := envelope.ParseKeyring("current:"+base64.StdEncoding.EncodeToString(make([]byte, 32)), "")
+	if err != nil {
+		t.Fatalf("failed to parse keyring: %v", err)
+	}
+	repo := &CacheRepository{keyring: keyring}
+	_, unknownKey := repo.unwrapDataKey(&storedValue{keyID: sql.NullString{String: "retired", Valid: true}, dataKey: []byte("wrapped")})
+	if !errors.Is(unknownKey, envelope.ErrUnknownKey) {
+		t.Fatalf("got %v, want ErrUnknownKey", unknownKey)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"success", context.Background(), nil, false},
+		{"not found", context.Background(), fmt.Errorf("failed to get cache entry: %w", ErrNotFound), false},
+		{"key exists", context.Background(), ErrKeyExists, false},
+		{"unknown master key", context.Background(), fmt.Errorf("failed to get cache entry: %w", unknownKey), false},
+		{"GCM authentication", context.Background(), decodeError(t, CodecAESGCM, make([]byte, 40), make([]byte, 32)), false},
+		{"corrupt gzip", context.Background(), decodeError(t, CodecGzip, []byte("not gzip"), nil), false},
+		{"invalid input", context.Background(), &pq.Error{Code: "22P02"}, false},
+		{"not null violation", context.Background(), &pq.Error{Code: "23502"}, false},
+		{"client cancelled", cancelled, context.Canceled, false},
+		{"connection failure", context.Background(), &pq.Error{Code: "08006"}, true},
+		{"too many connections", context.Background(), &pq.Error{Code: "53300"}, true},
+		{"bad connection", context.Background(), fmt.Errorf("failed to get cache entry: %w", driver.ErrBadConn), true},
+		{"call timeout", context.Background(), context.DeadlineExceeded, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDatabaseFailure(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("isDatabaseFailure(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// failingStore fails every Get with err
+type failingStore struct {
+	Store
+	err error
+}
+
+func (s *failingStore) Get(context.Context, string) (*CacheEntry, error) {
+	return nil, s.err
+}
+
+func TestGuardedStoreIgnoresCodecErrors(t *testing.T) {
+	corrupt := &failingStore{err: decodeError(t, CodecGzip, []byte("not gzip"), nil)}
+	g := NewGuardedStore(corrupt, breaker.New(2, time.Minute, nil), time.Second)
+	for i := 0; i < 3; i++ {
+		g.Get(context.Background(), "k")
+	}
+	if state := g.BreakerState(); state != breaker.Closed.String() {
+		t.Errorf("after decode errors: breaker %s, want closed", state)
+	}
+
+	down := &failingStore{err: driver.ErrBadConn}
+	g = NewGuardedStore(down, breaker.New(2, time.Minute, nil), time.Second)
+	for i := 0; i < 3; i++ {
+		g.Get(context.Background(), "k")
+	}
+	if state := g.BreakerState(); state != breaker.Open.String() {
+		t.Errorf("after connection errors: breaker %s, want open", state)
+	}
+}
+
+func TestGuardedStoreOpen(t *testing.T) {
+	for _, missOnOpen := range []bool{false, true} {
+		t.Run(fmt.Sprintf("miss on open %v", missOnOpen), func(t *testing.T) {
+			g := NewGuardedStore(&failingStore{err: driver.ErrBadConn}, breaker.New(1, time.Minute, nil), time.Second)
+			if missOnOpen {
+				g.UseMissOnOpen()
+			}
+			g.Get(context.Background(), "k")
+
+			_, err := g.Get(context.Background(), "k")
+			if missOnOpen {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("Get while open = %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if !IsUnavailable(err) || errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get while open = %v, want ErrUnavailable", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// l1Item is a single value held by the L1 cache
+type l1Item struct {
+	key       string
+	value     interface{}
+	size      int64
+	expiresAt time.Time
+}
+
+// l1Cache is a byte-bounded LRU cache whose items carry their own expiry
+type l1Cache struct {
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	lru      *list.List // front is most recently used
+	bytes    int64
+	maxBytes int64
+}
+
+// newL1Cache creates an L1 cache holding at most maxBytes of accounted item sizes
+func newL1Cache(maxBytes int64) *l1Cache {
+	return &l1Cache{
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// get returns the live value stored under key
+func (c *l1Cache) get(key string, now time.Time) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := el.Value.(*l1Item)
+	if !now.Before(item.expiresAt) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+
+	return item.value, true
+}
+
+// set stores value under key until expiresAt, evicting least recently used items to stay
+// within the byte budget. It returns the number of items evicted.
+func (c *l1Cache) set(key string, value interface{}, size int64, expiresAt time.Time) int {
+	if size > c.maxBytes {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	c.items[key] = c.lru.PushFront(&l1Item{key: key, value: value, size: size, expiresAt: expiresAt})
+	c.bytes += size
+
+	evicted := 0
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.lru.Back())
+		evicted++
+	}
+
+	return evicted
+}
+
+// delete removes key from the cache
+func (c *l1Cache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// clear removes every item
+func (c *l1Cache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// usage returns the number of items and accounted bytes currently held
+func (c *l1Cache) usage() (int64, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(c.lru.Len()), c.bytes
+}
+
+// removeElement drops an item from both the index and the LRU list; callers hold c.mu
+func (c *l1Cache) removeElement(el *list.Element) {
+	item := c.lru.Remove(el).(*l1Item)
+	delete(c.items, item.key)
+	c.bytes -= item.size
+}
+
+// entrySize estimates the memory held by a cache entry
+func entrySize(entry *CacheEntry) int64 {
+	const overhead = 128 // struct, timestamps and map/list bookkeeping
+	return int64(overhead + len(entry.Key) + len(entry.Value) + len(entry.ContentType) + len(entry.Metadata) + 4*len(entry.Embedding))
+}
@@ -0,0 +1,626 @@
+package models
+
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store with TTL expiry and LRU eviction.
+// Contents are lost on restart, so it is meant for local development and tests.
+type MemoryStore struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List // front is most recently used
+	maxEntries int
+	nextID     int
+
+	// events receives expired and evicted entries (see UseEvents)
+	events func(EntryEvent)
+
+	// webhooks and deadLetters back the WebhookStore methods
+	webhooks         []*Webhook
+	deadLetters      []*DeadLetter
+	nextWebhookID    int
+	nextDeadLetterID int
+
+	// changes backs the ChangeLog methods, oldest first
+	changes       []*Change
+	nextChangeSeq int64
+
+	// apiKeys backs the APIKeyStore methods
+	apiKeys      []*APIKey
+	nextAPIKeyID int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMemoryStore creates an in-memory store holding at most maxEntries live entries
+// (0 means unbounded). Expired entries are swept every sweepInterval when it is positive.
+func NewMemoryStore(maxEntries int, sweepInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	if sweepInterval > 0 {
+		go s.sweepLoop(sweepInterval)
+	} else {
+		close(s.done)
+	}
+
+	return s
+}
+
+var (
+	_ Store        = (*MemoryStore)(nil)
+	_ WebhookStore = (*MemoryStore)(nil)
+	_ ChangeLog    = (*MemoryStore)(nil)
+	_ APIKeyStore  = (*MemoryStore)(nil)
+)
+
+// memoryChangeLimit caps the in-memory change log, whatever its retention
+const memoryChangeLimit = 100000
+
+// UseEvents reports entries removed after their TTL as expired events, and entries
+// dropped to stay within maxEntries as evicted events. publish is called with the store
+// locked and must not block or use the store.
+func (s *MemoryStore) UseEvents(publish func(EntryEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = publish
+}
+
+// Create stores a new entry, failing with ErrKeyExists if a live entry already uses the key
+func (s *MemoryStore) Create(_ context.Context, req CreateRequest) (*CacheEntry, error) {
+	entry, _, err := s.create(req, false)
+	return entry, err
+}
+
+// Put stores an entry, replacing any entry already stored under the key
+func (s *MemoryStore) Put(_ context.Context, req CreateRequest) (*CacheEntry, bool, error) {
+	return s.create(req, true)
+}
+
+// create stores an entry, replacing a live entry with the same key if replace is set, and
+// reports whether it did
+func (s *MemoryStore) create(req CreateRequest, replace bool) (*CacheEntry, bool, error) {
+	now := time.Now()
+
+	var expiresAt *time.Time
+	if req.TTL != nil && *req.TTL > 0 {
+		expiry := now.Add(time.Duration(*req.TTL) * time.Second)
+		expiresAt = &expiry
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replaced := false
+	if el, ok := s.items[req.Key]; ok {
+		switch {
+		case isExpired(el.Value.(*CacheEntry), now):
+			s.removeElement(el, EventExpired)
+		case replace:
+			s.removeElement(el, "")
+			replaced = true
+		default:
+			return nil, false, ErrKeyExists
+		}
+	}
+
+	s.nextID++
+	entry := &CacheEntry{
+		ID:          s.nextID,
+		Key:         req.Key,
+		Value:       req.Value,
+		ContentType: req.ContentType,
+		Metadata:    req.Metadata,
+		CreatedAt:   now.UTC(),
+		ExpiresAt:   expiresAt,
+		Embedding:   append([]float32(nil), req.Embedding...),
+	}
+	s.items[req.Key] = s.lru.PushFront(entry)
+
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.removeElement(s.lru.Back(), EventEvicted)
+	}
+
+	return copyEntry(entry), replaced, nil
+}
+
+// Expire sets when the live entry stored under key expires
+func (s *MemoryStore) Expire(_ context.Context, key string, expiresAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return ErrNotFound
+	}
+	entry := el.Value.(*CacheEntry)
+	if isExpired(entry, time.Now()) {
+		s.removeElement(el, EventExpired)
+		return ErrNotFound
+	}
+	entry.ExpiresAt = nil
+	if expiresAt != nil {
+		expiry := *expiresAt
+		entry.ExpiresAt = &expiry
+	}
+
+	return nil
+}
+
+// CreateStream reads the whole value into memory and creates the entry
+func (s *MemoryStore) CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error) {
+	return createFromStream(ctx, req, value, s.Create)
+}
+
+// GetStream returns a stream over the value stored under key
+func (s *MemoryStore) GetStream(ctx context.Context, key string) (*ValueStream, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return streamEntry(entry), nil
+}
+
+// Get returns the live entry stored under key and marks it as recently used
+func (s *MemoryStore) Get(_ context.Context, key string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := el.Value.(*CacheEntry)
+	if isExpired(entry, time.Now()) {
+		s.removeElement(el, EventExpired)
+		return nil, ErrNotFound
+	}
+	s.lru.MoveToFront(el)
+
+	return copyEntry(entry), nil
+}
+
+// Search returns live entries whose key and metadata contain the given substrings (case-insensitive)
+func (s *MemoryStore) Search(_ context.Context, req SearchRequest) ([]*CacheEntry, error) {
+	limit := searchLimit(req.Limit)
+	key := strings.ToLower(req.Key)
+	metadata := strings.ToLower(req.Metadata)
+	now := time.Now()
+
+	s.mu.Lock()
+	var entries []*CacheEntry
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*CacheEntry)
+		if isExpired(entry, now) {
+			continue
+		}
+		if key != "" && !strings.Contains(strings.ToLower(entry.Key), key) {
+			continue
+		}
+		if metadata != "" && !strings.Contains(strings.ToLower(entry.Metadata), metadata) {
+			continue
+		}
+		if !inNamespaces(entry.Key, req.Namespaces) {
+			continue
+		}
+		entries = append(entries, copyEntry(entry))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// Delete removes the entry stored under key
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if isExpired(el.Value.(*CacheEntry), time.Now()) {
+		s.removeElement(el, EventExpired)
+		return ErrNotFound
+	}
+	s.removeElement(el, "")
+
+	return nil
+}
+
+// Lookup scores every live entry against the request embedding by brute force
+func (s *MemoryStore) Lookup(_ context.Context, req LookupRequest) ([]*LookupResult, error) {
+	limit := lookupLimit(req.Limit)
+	now := time.Now()
+
+	s.mu.Lock()
+	var results []*LookupResult
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*CacheEntry)
+		if isExpired(entry, now) || !inNamespaces(entry.Key, req.Namespaces) {
+			continue
+		}
+		score, ok := cosineSimilarity(req.Embedding, entry.Embedding)
+		if !ok || score < req.Threshold {
+			continue
+		}
+		results = append(results, &LookupResult{CacheEntry: copyEntry(entry), Score: score})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// HealthCheck always succeeds for the in-memory store
+func (s *MemoryStore) HealthCheck(_ context.Context) error {
+	return nil
+}
+
+// Stats counts live and expired entries
+func (s *MemoryStore) Stats(_ context.Context) (*Stats, error) {
+	now := time.Now()
+	stats := &Stats{}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*CacheEntry)
+		if isExpired(entry, now) {
+			stats.Expired++
+			continue
+		}
+		stats.Entries++
+		stats.ValueBytes += int64(len(entry.Value))
+	}
+
+	return stats, nil
+}
+
+// Export calls fn with copies of the live entries taken under the lock, so fn may use
+// the store
+func (s *MemoryStore) Export(_ context.Context, prefix string, after int, fn func(*CacheEntry) error) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	var entries []*CacheEntry
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*CacheEntry)
+		if entry.ID > after && !isExpired(entry, now) && strings.HasPrefix(entry.Key, prefix) {
+			entries = append(entries, copyEntry(entry))
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateWebhook registers a webhook
+func (s *MemoryStore) CreateWebhook(_ context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextWebhookID++
+	webhook := &Webhook{
+		ID:        s.nextWebhookID,
+		Namespace: req.Namespace,
+		URL:       req.URL,
+		Events:    append([]string{}, req.Events...),
+		Secret:    req.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.webhooks = append(s.webhooks, webhook)
+
+	return copyWebhook(webhook), nil
+}
+
+// ListWebhooks returns every webhook in id order
+func (s *MemoryStore) ListWebhooks(_ context.Context) ([]*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := make([]*Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its dead letters
+func (s *MemoryStore) DeleteWebhook(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.webhooks, func(w *Webhook) bool { return w.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.webhooks = slices.Delete(s.webhooks, i, i+1)
+	s.deadLetters = slices.DeleteFunc(s.deadLetters, func(dl *DeadLetter) bool { return dl.WebhookID == id })
+
+	return nil
+}
+
+// AddDeadLetter records a delivery that was given up on
+func (s *MemoryStore) AddDeadLetter(_ context.Context, dl *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextDeadLetterID++
+	dl.ID = s.nextDeadLetterID
+	dl.CreatedAt = time.Now().UTC()
+	stored := *dl
+	s.deadLetters = append(s.deadLetters, &stored)
+
+	return nil
+}
+
+// ListDeadLetters returns the most recent dead letters of a webhook, newest first
+func (s *MemoryStore) ListDeadLetters(_ context.Context, webhookID, limit int) ([]*DeadLetter, error) {
+	limit = deadLetterLimit(limit)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deadLetters []*DeadLetter
+	for i := len(s.deadLetters) - 1; i >= 0 && len(deadLetters) < limit; i-- {
+		if dl := s.deadLetters[i]; dl.WebhookID == webhookID {
+			c := *dl
+			deadLetters = append(deadLetters, &c)
+		}
+	}
+	return deadLetters, nil
+}
+
+// AppendChanges records events in the change log
+func (s *MemoryStore) AppendChanges(_ context.Context, events []EntryEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		s.nextChangeSeq++
+		s.changes = append(s.changes, &Change{
+			Seq:  s.nextChangeSeq,
+			Type: event.Type,
+			Key:  event.Key,
+			Time: event.Time.UTC(),
+		})
+	}
+	if excess := len(s.changes) - memoryChangeLimit; excess > 0 {
+		s.changes = slices.Delete(s.changes, 0, excess)
+	}
+
+	return nil
+}
+
+// ChangesSince returns up to limit changes after the given sequence
+func (s *MemoryStore) ChangesSince(_ context.Context, after int64, limit int) ([]*Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, _ := slices.BinarySearchFunc(s.changes, after+1, func(c *Change, seq int64) int {
+		return cmp.Compare(c.Seq, seq)
+	})
+
+	var changes []*Change
+	for _, change := range s.changes[i:min(i+limit, len(s.changes))] {
+		c := *change
+		changes = append(changes, &c)
+	}
+	return changes, nil
+}
+
+// ChangeBounds returns the oldest and latest sequence in the change log
+func (s *MemoryStore) ChangeBounds(_ context.Context) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.changes) == 0 {
+		return 0, 0, nil
+	}
+	return s.changes[0].Seq, s.changes[len(s.changes)-1].Seq, nil
+}
+
+// TrimChanges removes changes older than maxAge, except the latest
+func (s *MemoryStore) TrimChanges(_ context.Context, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for n < len(s.changes)-1 && s.changes[n].Time.Before(cutoff) {
+		n++
+	}
+	s.changes = slices.Delete(s.changes, 0, n)
+	return n, nil
+}
+
+// CreateAPIKey stores an API key
+func (s *MemoryStore) CreateAPIKey(_ context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextAPIKeyID++
+	key.ID = s.nextAPIKeyID
+	key.CreatedAt = time.Now().UTC()
+	if key.Namespaces == nil {
+		key.Namespaces = []string{}
+	}
+	stored := copyAPIKey(key)
+	stored.Key = ""
+	s.apiKeys = append(s.apiKeys, stored)
+
+	return nil
+}
+
+// ListAPIKeys returns every API key in id order
+func (s *MemoryStore) ListAPIKeys(_ context.Context) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey removes an API key
+func (s *MemoryStore) DeleteAPIKey(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.apiKeys, func(k *APIKey) bool { return k.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.apiKeys = slices.Delete(s.apiKeys, i, i+1)
+
+	return nil
+}
+
+// APIKeyByHash returns the API key with the given hash
+func (s *MemoryStore) APIKeyByHash(_ context.Context, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.apiKeys, func(k *APIKey) bool { return k.Hash == hash })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return copyAPIKey(s.apiKeys[i]), nil
+}
+
+// Len returns the number of entries currently held, including expired ones not yet swept
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Close stops the background sweeper
+func (s *MemoryStore) Close() error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	return nil
+}
+
+// sweepLoop periodically removes expired entries until Close is called
+func (s *MemoryStore) sweepLoop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep(time.Now())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sweep removes all entries that expired before now
+func (s *MemoryStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for el := s.lru.Front(); el != nil; {
+		next := el.Next()
+		if isExpired(el.Value.(*CacheEntry), now) {
+			s.removeElement(el, EventExpired)
+		}
+		el = next
+	}
+}
+
+// removeElement drops an entry from both the index and the LRU list, reporting eventType
+// unless it is empty; callers hold s.mu
+func (s *MemoryStore) removeElement(el *list.Element, eventType string) {
+	entry := s.lru.Remove(el).(*CacheEntry)
+	delete(s.items, entry.Key)
+	if eventType != "" {
+		publishEvent(s.events, eventType, entry.Key)
+	}
+}
+
+// isExpired reports whether entry has a TTL that has passed at now
+func isExpired(entry *CacheEntry, now time.Time) bool {
+	return entry.ExpiresAt != nil && !entry.ExpiresAt.After(now)
+}
+
+// copyWebhook returns a copy of webhook that callers may modify freely
+func copyWebhook(webhook *Webhook) *Webhook {
+	c := *webhook
+	c.Events = append([]string{}, webhook.Events...)
+	return &c
+}
+
+// copyAPIKey returns a copy of key that callers may modify freely
+func copyAPIKey(key *APIKey) *APIKey {
+	c := *key
+	c.Scopes = append([]string{}, key.Scopes...)
+	c.Namespaces = append([]string{}, key.Namespaces...)
+	if key.ExpiresAt != nil {
+		expiresAt := *key.ExpiresAt
+		c.ExpiresAt = &expiresAt
+	}
+	return &c
+}
+
+// copyEntry returns a copy of entry that callers may modify freely
+func copyEntry(entry *CacheEntry) *CacheEntry {
+	c := *entry
+	if entry.ExpiresAt != nil {
+		expiresAt := *entry.ExpiresAt
+		c.ExpiresAt = &expiresAt
+	}
+	c.Embedding = append([]float32(nil), entry.Embedding...)
+	return &c
+}
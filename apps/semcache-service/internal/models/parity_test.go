@@ -0,0 +1,121 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/database"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// testStores returns a fresh store for each backend: memory and SQLite, and Postgres with
+// and without key locks when SEMCACHE_TEST_POSTGRES is set, connecting with the DB_*
+// settings
+func testStores(t *testing.T) map[string]models.Store {
+	t.Helper()
+	ctx := context.Background()
+
+	memory := models.NewMemoryStore(0, 0)
+	t.Cleanup(func() { memory.Close() })
+	sqlite, err := models.NewSQLiteStore(ctx, t.TempDir()+"/semcache.db")
+	if err != nil {
+		t.Fatalf("failed to open sqlite store: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	stores := map[string]models.Store{"memory": memory, "sqlite": sqlite}
+
+	db := testPostgres(t)
+	if db == nil {
+		return stores
+	}
+	stores["postgres"] = models.NewCacheRepository(db.DB)
+	locked := models.NewCacheRepository(db.DB)
+	locked.UseKeyLocks()
+	stores["postgres key locks"] = locked
+	return stores
+}
+
+// testPostgres connects to Postgres with the DB_* settings and migrates it, or returns nil
+// if SEMCACHE_TEST_POSTGRES is not set
+func testPostgres(t *testing.T) *database.DB {
+	t.Helper()
+	if os.Getenv("SEMCACHE_TEST_POSTGRES") == "" {
+		return nil
+	}
+
+	logger.Logger = zap.NewNop()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		t.Fatalf("failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+// TestExpiredKeyReuse checks that every backend lets a new entry take the key of an
+// expired entry that has not been swept yet
+func TestExpiredKeyReuse(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("parity%d:", time.Now().UnixNano())
+	ttl := 1
+
+	stores := testStores(t)
+	for name, store := range stores {
+		for _, op := range []string{"create", "stream", "put"} {
+			key := prefix + name + ":" + op
+			if _, err := store.Create(ctx, models.CreateRequest{Key: key, Value: "old", TTL: &ttl}); err != nil {
+				t.Fatalf("%s: failed to create %s: %v", name, key, err)
+			}
+		}
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			key := prefix + name + ":"
+			if _, err := store.Get(ctx, key+"create"); !errors.Is(err, models.ErrNotFound) {
+				t.Fatalf("got %v for an expired entry, want ErrNotFound", err)
+			}
+
+			if _, err := store.Create(ctx, models.CreateRequest{Key: key + "create", Value: "new"}); err != nil {
+				t.Errorf("create over an expired entry: %v", err)
+			}
+			if _, err := store.Create(ctx, models.CreateRequest{Key: key + "create", Value: "again"}); !errors.Is(err, models.ErrKeyExists) {
+				t.Errorf("create over a live entry: got %v, want ErrKeyExists", err)
+			}
+
+			if _, err := store.CreateStream(ctx, models.CreateRequest{Key: key + "stream"}, strings.NewReader("new")); err != nil {
+				t.Errorf("stream over an expired entry: %v", err)
+			}
+
+			_, replaced, err := store.Put(ctx, models.CreateRequest{Key: key + "put", Value: "new"})
+			if err != nil || replaced {
+				t.Errorf("put over an expired entry: got replaced %t, %v, want a new entry", replaced, err)
+			}
+
+			for _, op := range []string{"create", "stream", "put"} {
+				entry, err := store.Get(ctx, key+op)
+				if err != nil || entry.Value != "new" {
+					t.Errorf("%s: got %+v, %v, want the new entry", op, entry, err)
+				}
+				store.Delete(ctx, key+op)
+			}
+		})
+	}
+}
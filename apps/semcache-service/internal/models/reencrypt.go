@@ -0,0 +1,213 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// needsReencryption selects rows not under the primary master key $1, or with metadata
+	// left unencrypted when $2 asks for it to be encrypted
+	needsReencryption = "encryption_key_id IS DISTINCT FROM $1 OR ($2 AND metadata <> '')"
+
+	// reencryptRetries bounds how many times Reencrypt waits, reencryptRetryWait each time,
+	// for rows locked by concurrent writers before giving up on them
+	reencryptRetries   = 5
+	reencryptRetryWait = time.Second
+)
+
+// Reencrypt moves every row under the primary master key, batchSize rows per transaction,
+// and returns how many rows it rewrote. Rows whose data key was wrapped by an older master
+// key only have the data key re-wrapped; rows that are not encrypted yet, or whose metadata
+// should be encrypted but is not, are re-encoded in full. Expired rows are included, since
+// they still hold data until they are deleted. Rows that stay locked by concurrent writers
+// are left behind, and reported in the error.
+func (r *CacheRepository) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	if r.keyring == nil {
+		return 0, errors.New("encryption is not configured")
+	}
+
+	total := 0
+	for retries := 0; ; {
+		n, err := r.reencryptBatch(ctx, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n > 0 {
+			continue
+		}
+
+		// Batches skip locked rows, so an empty batch does not mean none are left
+		var left int
+		err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM semcache WHERE "+needsReencryption,
+			r.keyring.PrimaryID(), r.encryptMetadata,
+		).Scan(&left)
+		if err != nil {
+			return total, fmt.Errorf("failed to count rows to re-encrypt: %w", err)
+		}
+		if left == 0 {
+			return total, nil
+		}
+		if retries == reencryptRetries {
+			return total, fmt.Errorf("%d rows still need re-encryption but stayed locked by concurrent writes; run it again", left)
+		}
+		retries++
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(reencryptRetryWait):
+		}
+	}
+}
+
+// reencryptBatch rewrites up to batchSize rows that are not under the primary master key.
+// Rows locked by concurrent writers are skipped and picked up by a later batch.
+func (r *CacheRepository) reencryptBatch(ctx context.Context, batchSize int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin re-encryption: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+entryColumns+`
+		FROM semcache
+		WHERE `+needsReencryption+`
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, r.keyring.PrimaryID(), r.encryptMetadata, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select rows to re-encrypt: %w", err)
+	}
+
+	type pending struct {
+		id        int
+		key       string
+		createdAt time.Time
+		stored    *storedValue
+
+		// oldKey and newKey are the data keys to re-encode chunks from and to, if any
+		oldKey, newKey []byte
+		reencode       bool
+	}
+	var batch []pending
+
+	for rows.Next() {
+		entry := &CacheEntry{}
+		stored := &storedValue{}
+		var embedding interface{}
+		if err := rows.Scan(
+			&entry.ID, &entry.Key, &stored.value, &entry.ContentType, &stored.metadata, &entry.CreatedAt, &entry.ExpiresAt,
+			&embedding, &stored.codec, &stored.data, &stored.keyID, &stored.dataKey, &stored.metadataEncrypted,
+			&stored.chunks, &stored.size,
+		); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row to re-encrypt: %w", err)
+		}
+
+		if stored.keyID.Valid && (!r.encryptMetadata || stored.metadata == "") {
+			// Only the wrapping changes; the data key and ciphertext stay as they are
+			stored.dataKey, stored.keyID.String, err = r.keyring.Rewrap(stored.keyID.String, stored.dataKey)
+			if err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to re-wrap data key for key %q: %w", entry.Key, err)
+			}
+			batch = append(batch, pending{id: entry.ID, key: entry.Key, createdAt: entry.CreatedAt, stored: stored})
+			continue
+		}
+
+		if err := r.decodeValue(entry, stored); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode value for key %q: %w", entry.Key, err)
+		}
+
+		p := pending{id: entry.ID, key: entry.Key, createdAt: entry.CreatedAt}
+		if stored.chunks > 0 {
+			// Chunks move to a new data key after the rows have been read
+			p.oldKey, err = r.unwrapDataKey(stored)
+			if err == nil {
+				p.stored, p.newKey, err = r.newStoredValue(entry.Key, entry.Metadata)
+			}
+			if err == nil {
+				p.stored.chunks, p.stored.size, p.reencode = stored.chunks, stored.size, true
+			}
+		} else {
+			p.stored, err = r.encodeValue(ctx, entry.Key, entry.Value, entry.Metadata)
+		}
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to encrypt value for key %q: %w", entry.Key, err)
+		}
+
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows to re-encrypt: %w", err)
+	}
+
+	for _, p := range batch {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE semcache
+			SET value = $3, metadata = $4, value_codec = $5, value_data = $6,
+				encryption_key_id = $7, encrypted_data_key = $8, metadata_encrypted = $9
+			WHERE id = $1 AND created_at = $2
+		`, p.id, p.createdAt, p.stored.value, p.stored.metadata, p.stored.codec, p.stored.data,
+			p.stored.keyID, p.stored.dataKey, p.stored.metadataEncrypted)
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt row %d: %w", p.id, err)
+		}
+
+		if p.reencode {
+			if err := r.reencodeChunks(ctx, tx, p.id, p.key, p.stored.chunks, p.oldKey, p.newKey); err != nil {
+				return 0, fmt.Errorf("failed to re-encrypt row %d: %w", p.id, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit re-encryption: %w", err)
+	}
+
+	return len(batch), nil
+}
+
+// reencodeChunks decodes each chunk of a value with oldKey and encodes it again with newKey,
+// one chunk at a time
+func (r *CacheRepository) reencodeChunks(ctx context.Context, tx *sql.Tx, id int, key string, chunks int, oldKey, newKey []byte) error {
+	for seq := 0; seq < chunks; seq++ {
+		var codec string
+		var data []byte
+		err := tx.QueryRowContext(ctx,
+			"SELECT codec, data FROM semcache_chunks WHERE entry_id = $1 AND seq = $2",
+			id, seq,
+		).Scan(&codec, &data)
+		if err != nil {
+			return fmt.Errorf("failed to read chunk %d: %w", seq, err)
+		}
+
+		data, err = decodeChunk(codec, data, oldKey, id, key, seq)
+		if err != nil {
+			return fmt.Errorf("failed to decode chunk %d: %w", seq, err)
+		}
+		codec, data, err = r.encodeBytes(ctx, data, newKey, chunkAAD(id, key, seq))
+		if err != nil {
+			return fmt.Errorf("failed to encode chunk %d: %w", seq, err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE semcache_chunks SET codec = $3, data = $4 WHERE entry_id = $1 AND seq = $2",
+			id, seq, codec, data,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", seq, err)
+		}
+	}
+	return nil
+}
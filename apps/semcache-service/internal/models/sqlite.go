@@ -0,0 +1,945 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteStore is a single-node Store persisted to a local SQLite file.
+// It needs no external database, which makes it suitable for edge deployments.
+type SQLiteStore struct {
+	db *sql.DB
+
+	// events receives expired entries removed by the store (see UseEvents)
+	events func(EntryEvent)
+}
+
+var (
+	_ Store        = (*SQLiteStore)(nil)
+	_ WebhookStore = (*SQLiteStore)(nil)
+	_ ChangeLog    = (*SQLiteStore)(nil)
+	_ APIKeyStore  = (*SQLiteStore)(nil)
+)
+
+// sqliteNamespace is the namespace of the key column, as Namespace returns it
+const sqliteNamespace = `CASE WHEN instr(key, ':') > 0 THEN substr(key, 1, instr(key, ':') - 1) ELSE '' END`
+
+// placeholders returns n comma-separated parameter placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// NewSQLiteStore opens (creating if needed) the SQLite database at path and initializes its schema
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)", path)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY between our own goroutines
+	db.SetMaxOpenConns(1)
+
+	query := `
+		CREATE TABLE IF NOT EXISTS semcache (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key TEXT NOT NULL UNIQUE,
+			value BLOB NOT NULL,
+			content_type TEXT NOT NULL DEFAULT '',
+			metadata TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER,
+			embedding BLOB
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_semcache_expires_at ON semcache(expires_at);
+
+		CREATE TABLE IF NOT EXISTS semcache_webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			namespace TEXT NOT NULL,
+			url TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '',
+			secret TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS semcache_webhook_dead_letters (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			key TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_status INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_semcache_webhook_dead_letters_webhook_id
+			ON semcache_webhook_dead_letters(webhook_id, id);
+
+		CREATE TABLE IF NOT EXISTS semcache_changes (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL,
+			key TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_semcache_changes_created_at ON semcache_changes(created_at);
+
+		CREATE TABLE IF NOT EXISTS semcache_api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			prefix TEXT NOT NULL,
+			scopes TEXT NOT NULL,
+			namespaces TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER
+		);
+	`
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	// Databases created before content types were added lack the column
+	if err := addSQLiteColumn(ctx, db, "content_type", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade sqlite schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// addSQLiteColumn adds a column to semcache unless it already exists
+func addSQLiteColumn(ctx context.Context, db *sql.DB, name, definition string) error {
+	var exists bool
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM pragma_table_info('semcache') WHERE name = ?", name,
+	).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE semcache ADD COLUMN %s %s", name, definition))
+	return err
+}
+
+// Create stores a new entry, replacing an expired entry with the same key
+func (s *SQLiteStore) Create(ctx context.Context, req CreateRequest) (*CacheEntry, error) {
+	entry, _, err := s.create(ctx, req, false)
+	return entry, err
+}
+
+// Put stores an entry, replacing any entry with the same key in the same transaction
+func (s *SQLiteStore) Put(ctx context.Context, req CreateRequest) (*CacheEntry, bool, error) {
+	return s.create(ctx, req, true)
+}
+
+// create stores an entry, replacing a live entry with the same key if replace is set, and
+// reports whether it did
+func (s *SQLiteStore) create(ctx context.Context, req CreateRequest, replace bool) (*CacheEntry, bool, error) {
+	now := time.Now().UTC()
+
+	entry := &CacheEntry{
+		Key:         req.Key,
+		Value:       req.Value,
+		ContentType: req.ContentType,
+		Metadata:    req.Metadata,
+		CreatedAt:   now,
+		Embedding:   req.Embedding,
+	}
+
+	var expiresAt sql.NullInt64
+	if req.TTL != nil && *req.TTL > 0 {
+		expiry := now.Add(time.Duration(*req.TTL) * time.Second)
+		entry.ExpiresAt = &expiry
+		expiresAt = sql.NullInt64{Int64: expiry.UnixNano(), Valid: true}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"DELETE FROM semcache WHERE key = ? AND expires_at IS NOT NULL AND expires_at <= ?",
+		req.Key, now.UnixNano(),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	replacedExpired, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
+	var replaced int64
+	if replace {
+		res, err = tx.ExecContext(ctx, "DELETE FROM semcache WHERE key = ?", req.Key)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+		}
+		if replaced, err = res.RowsAffected(); err != nil {
+			return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+		}
+	}
+
+	// Values are bound as bytes so binary values are stored as BLOBs, unchanged
+	res, err = tx.ExecContext(ctx,
+		"INSERT INTO semcache (key, value, content_type, metadata, created_at, expires_at, embedding) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		req.Key, []byte(req.Value), req.ContentType, req.Metadata, now.UnixNano(), expiresAt, encodeEmbedding(req.Embedding),
+	)
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return nil, false, ErrKeyExists
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	entry.ID = int(id)
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	if replacedExpired > 0 {
+		publishEvent(s.events, EventExpired, req.Key)
+	}
+
+	return entry, replaced > 0, nil
+}
+
+// CreateStream reads the whole value into memory and creates the entry
+func (s *SQLiteStore) CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error) {
+	return createFromStream(ctx, req, value, s.Create)
+}
+
+// GetStream returns a stream over the value stored under key
+func (s *SQLiteStore) GetStream(ctx context.Context, key string) (*ValueStream, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return streamEntry(entry), nil
+}
+
+// Get returns the live cache entry stored under key
+func (s *SQLiteStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, key, value, content_type, metadata, created_at, expires_at, embedding
+		FROM semcache
+		WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)
+	`, key, time.Now().UnixNano())
+
+	entry, err := scanSQLiteEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// Search searches for live cache entries by key and metadata substrings
+func (s *SQLiteStore) Search(ctx context.Context, req SearchRequest) ([]*CacheEntry, error) {
+	query := `
+		SELECT id, key, value, content_type, metadata, created_at, expires_at, embedding
+		FROM semcache
+		WHERE (expires_at IS NULL OR expires_at > ?)
+	`
+	args := []interface{}{time.Now().UnixNano()}
+
+	// LIKE is case-insensitive for ASCII in SQLite, matching ILIKE in Postgres
+	if req.Key != "" {
+		query += " AND key LIKE ?"
+		args = append(args, "%"+req.Key+"%")
+	}
+
+	if req.Metadata != "" {
+		query += " AND metadata LIKE ?"
+		args = append(args, "%"+req.Metadata+"%")
+	}
+
+	if len(req.Namespaces) > 0 {
+		query += " AND " + sqliteNamespace + " IN (" + placeholders(len(req.Namespaces)) + ")"
+		for _, namespace := range req.Namespaces {
+			args = append(args, namespace)
+		}
+	}
+
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, searchLimit(req.Limit))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*CacheEntry
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cache entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Delete removes the cache entry stored under key
+func (s *SQLiteStore) Delete(ctx context.Context, key string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM semcache WHERE key = ?", key)
+	if err != nil {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Lookup scores every live entry with a same-dimension embedding by brute force
+func (s *SQLiteStore) Lookup(ctx context.Context, req LookupRequest) ([]*LookupResult, error) {
+	query := `
+		SELECT id, key, value, content_type, metadata, created_at, expires_at, embedding
+		FROM semcache
+		WHERE (expires_at IS NULL OR expires_at > ?) AND length(embedding) = ?
+	`
+	args := []interface{}{time.Now().UnixNano(), 4 * len(req.Embedding)}
+	if len(req.Namespaces) > 0 {
+		query += " AND " + sqliteNamespace + " IN (" + placeholders(len(req.Namespaces)) + ")"
+		for _, namespace := range req.Namespaces {
+			args = append(args, namespace)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*LookupResult
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lookup result: %w", err)
+		}
+		score, ok := cosineSimilarity(req.Embedding, entry.Embedding)
+		if !ok || score < req.Threshold {
+			continue
+		}
+		results = append(results, &LookupResult{CacheEntry: entry, Score: score})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lookup results: %w", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit := lookupLimit(req.Limit); len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// Stats counts live and expired entries
+func (s *SQLiteStore) Stats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE expires_at IS NULL OR expires_at > ?1),
+			COUNT(*) FILTER (WHERE expires_at <= ?1),
+			COALESCE(SUM(length(value)) FILTER (WHERE expires_at IS NULL OR expires_at > ?1), 0)
+		FROM semcache
+	`, time.Now().UnixNano()).Scan(&stats.Entries, &stats.Expired, &stats.ValueBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Expire sets the expiry of the live entry stored under key in place
+func (s *SQLiteStore) Expire(ctx context.Context, key string, expiresAt *time.Time) error {
+	var expiry sql.NullInt64
+	if expiresAt != nil {
+		expiry = sql.NullInt64{Int64: expiresAt.UnixNano(), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE semcache SET expires_at = ? WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)",
+		expiry, key, time.Now().UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to expire cache entry: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to expire cache entry: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Export reads live entries in batches by id, releasing the connection while fn runs
+func (s *SQLiteStore) Export(ctx context.Context, prefix string, after int, fn func(*CacheEntry) error) error {
+	lastID := after
+	for {
+		entries, err := s.exportBatch(ctx, prefix, lastID)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < exportBatchSize {
+			return nil
+		}
+		lastID = entries[len(entries)-1].ID
+	}
+}
+
+// exportBatch reads the next batch of live entries after lastID
+func (s *SQLiteStore) exportBatch(ctx context.Context, prefix string, lastID int) ([]*CacheEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, key, value, content_type, metadata, created_at, expires_at, embedding
+		FROM semcache
+		WHERE id > ? AND substr(key, 1, length(?2)) = ?2 AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY id
+		LIMIT ?
+	`, lastID, prefix, time.Now().UnixNano(), exportBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*CacheEntry
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cache entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// UseEvents reports expired entries the store removes, in SweepExpired or when their key
+// is reused, as expired events
+func (s *SQLiteStore) UseEvents(publish func(EntryEvent)) {
+	s.events = publish
+}
+
+// SweepExpired deletes entries whose TTL has passed, batchSize at a time, and returns how
+// many it removed
+func (s *SQLiteStore) SweepExpired(ctx context.Context, batchSize int) (int, error) {
+	removed := 0
+	for {
+		keys, err := s.sweepBatch(ctx, batchSize)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, key := range keys {
+			publishEvent(s.events, EventExpired, key)
+		}
+
+		removed += len(keys)
+		if len(keys) < batchSize {
+			return removed, nil
+		}
+	}
+}
+
+// sweepBatch deletes up to batchSize expired entries and returns their keys
+func (s *SQLiteStore) sweepBatch(ctx context.Context, batchSize int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		DELETE FROM semcache WHERE id IN (
+			SELECT id FROM semcache WHERE expires_at <= ? LIMIT ?
+		)
+		RETURNING key
+	`, time.Now().UnixNano(), batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep expired entries: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan expired entry: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating expired entries: %w", err)
+	}
+
+	return keys, nil
+}
+
+// CreateWebhook registers a webhook
+func (s *SQLiteStore) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	webhook := &Webhook{
+		Namespace: req.Namespace,
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    req.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO semcache_webhooks (namespace, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?)",
+		webhook.Namespace, webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret, webhook.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	webhook.ID = int(id)
+
+	return webhook, nil
+}
+
+// ListWebhooks returns every webhook in id order
+func (s *SQLiteStore) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, namespace, url, events, secret, created_at FROM semcache_webhooks ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		var (
+			webhook   Webhook
+			events    string
+			createdAt int64
+		)
+		if err := rows.Scan(&webhook.ID, &webhook.Namespace, &webhook.URL, &events, &webhook.Secret, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhook.Events = []string{}
+		if events != "" {
+			webhook.Events = strings.Split(events, ",")
+		}
+		webhook.CreatedAt = time.Unix(0, createdAt).UTC()
+		webhooks = append(webhooks, &webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its dead letters
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM semcache_webhooks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM semcache_webhook_dead_letters WHERE webhook_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete webhook dead letters: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// AddDeadLetter records a delivery that was given up on
+func (s *SQLiteStore) AddDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	dl.CreatedAt = time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO semcache_webhook_dead_letters
+			(webhook_id, event_id, event_type, key, payload, attempts, last_status, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, dl.WebhookID, dl.EventID, dl.EventType, dl.Key, dl.Payload, dl.Attempts, dl.LastStatus, dl.LastError, dl.CreatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to add dead letter: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to add dead letter: %w", err)
+	}
+	dl.ID = int(id)
+
+	return nil
+}
+
+// ListDeadLetters returns the most recent dead letters of a webhook, newest first
+func (s *SQLiteStore) ListDeadLetters(ctx context.Context, webhookID, limit int) ([]*DeadLetter, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event_type, key, payload, attempts, last_status, last_error, created_at
+		FROM semcache_webhook_dead_letters
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, webhookID, deadLetterLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var deadLetters []*DeadLetter
+	for rows.Next() {
+		var (
+			dl        DeadLetter
+			createdAt int64
+		)
+		err := rows.Scan(&dl.ID, &dl.WebhookID, &dl.EventID, &dl.EventType, &dl.Key, &dl.Payload,
+			&dl.Attempts, &dl.LastStatus, &dl.LastError, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		dl.CreatedAt = time.Unix(0, createdAt).UTC()
+		deadLetters = append(deadLetters, &dl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dead letters: %w", err)
+	}
+
+	return deadLetters, nil
+}
+
+// AppendChanges records events in one transaction, so their sequences are contiguous
+func (s *SQLiteStore) AppendChanges(ctx context.Context, events []EntryEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to append changes: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO semcache_changes (type, key, created_at) VALUES (?, ?, ?)",
+			event.Type, event.Key, event.Time.UnixNano(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append changes: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to append changes: %w", err)
+	}
+	return nil
+}
+
+// ChangesSince returns up to limit changes after the given sequence
+func (s *SQLiteStore) ChangesSince(ctx context.Context, after int64, limit int) ([]*Change, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT seq, type, key, created_at FROM semcache_changes WHERE seq > ? ORDER BY seq LIMIT ?",
+		after, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*Change
+	for rows.Next() {
+		var (
+			change    Change
+			createdAt int64
+		)
+		if err := rows.Scan(&change.Seq, &change.Type, &change.Key, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		change.Time = time.Unix(0, createdAt).UTC()
+		changes = append(changes, &change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ChangeBounds returns the oldest and latest sequence in the change log
+func (s *SQLiteStore) ChangeBounds(ctx context.Context) (int64, int64, error) {
+	var oldest, latest int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM semcache_changes",
+	).Scan(&oldest, &latest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read change bounds: %w", err)
+	}
+	return oldest, latest, nil
+}
+
+// TrimChanges removes changes older than maxAge, except the latest
+func (s *SQLiteStore) TrimChanges(ctx context.Context, maxAge time.Duration) (int, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM semcache_changes
+		WHERE created_at < ?
+		AND seq < (SELECT MAX(seq) FROM semcache_changes)
+	`, time.Now().Add(-maxAge).UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim changes: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim changes: %w", err)
+	}
+	return int(n), nil
+}
+
+// HealthCheck performs a simple query to check the database file is usable
+func (s *SQLiteStore) HealthCheck(ctx context.Context) error {
+	var result int
+	return s.db.QueryRowContext(ctx, "SELECT 1").Scan(&result)
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// scanSQLiteEntry scans a row selected as id, key, value, content_type, metadata, created_at,
+// expires_at, embedding
+func scanSQLiteEntry(row interface{ Scan(...interface{}) error }) (*CacheEntry, error) {
+	var (
+		entry     CacheEntry
+		createdAt int64
+		expiresAt sql.NullInt64
+		embedding []byte
+	)
+
+	err := row.Scan(&entry.ID, &entry.Key, &entry.Value, &entry.ContentType, &entry.Metadata, &createdAt, &expiresAt, &embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.CreatedAt = time.Unix(0, createdAt).UTC()
+	if expiresAt.Valid {
+		expiry := time.Unix(0, expiresAt.Int64).UTC()
+		entry.ExpiresAt = &expiry
+	}
+	entry.Embedding = decodeEmbedding(embedding)
+
+	return &entry, nil
+}
+
+// encodeEmbedding packs a vector as little-endian float32s; nil vectors stay NULL
+func encodeEmbedding(v []float32) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+// decodeEmbedding is the inverse of encodeEmbedding
+func decodeEmbedding(buf []byte) []float32 {
+	if len(buf) == 0 {
+		return nil
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
+
+// CreateAPIKey stores an API key
+func (s *SQLiteStore) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	key.CreatedAt = time.Now().UTC()
+	if key.Namespaces == nil {
+		key.Namespaces = []string{}
+	}
+
+	var expiresAt *int64
+	if key.ExpiresAt != nil {
+		nanos := key.ExpiresAt.UnixNano()
+		expiresAt = &nanos
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO semcache_api_keys (name, key_hash, prefix, scopes, namespaces, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, key.Name, key.Hash, key.Prefix, strings.Join(key.Scopes, ","), strings.Join(key.Namespaces, ","),
+		key.CreatedAt.UnixNano(), expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	key.ID = int(id)
+
+	return nil
+}
+
+// ListAPIKeys returns every API key in id order
+func (s *SQLiteStore) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, key_hash, prefix, scopes, namespaces, created_at, expires_at
+		FROM semcache_api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanSQLiteAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey removes an API key
+func (s *SQLiteStore) DeleteAPIKey(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM semcache_api_keys WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// APIKeyByHash returns the API key with the given hash
+func (s *SQLiteStore) APIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	return scanSQLiteAPIKey(s.db.QueryRowContext(ctx, `
+		SELECT id, name, key_hash, prefix, scopes, namespaces, created_at, expires_at
+		FROM semcache_api_keys
+		WHERE key_hash = ?
+	`, hash))
+}
+
+// scanSQLiteAPIKey scans a semcache_api_keys row, returning ErrNotFound if there is none
+func scanSQLiteAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var (
+		key                APIKey
+		scopes, namespaces string
+		createdAt          int64
+		expiresAt          sql.NullInt64
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Hash, &key.Prefix, &scopes, &namespaces, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+
+	key.Scopes = splitList(scopes)
+	key.Namespaces = splitList(namespaces)
+	key.CreatedAt = time.Unix(0, createdAt).UTC()
+	if expiresAt.Valid {
+		t := time.Unix(0, expiresAt.Int64).UTC()
+		key.ExpiresAt = &t
+	}
+	return &key, nil
+}
+
+// splitList splits a comma-separated column, returning an empty slice for an empty one
+func splitList(list string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, ",")
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Store is the storage backend used by the handlers
+type Store interface {
+	Create(ctx context.Context, req CreateRequest) (*CacheEntry, error)
+	// Put creates an entry, atomically replacing any entry already under its key. It
+	// reports whether a live entry was replaced.
+	Put(ctx context.Context, req CreateRequest) (*CacheEntry, bool, error)
+	// Expire sets when the live entry under key expires, or clears its expiry if expiresAt
+	// is nil, keeping the rest of the entry
+	Expire(ctx context.Context, key string, expiresAt *time.Time) error
+	Get(ctx context.Context, key string) (*CacheEntry, error)
+	// CreateStream creates an entry whose value is read from value rather than req.Value.
+	// The returned entry has an empty Value.
+	CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error)
+	// GetStream opens the value stored under key for reading. The caller must close it.
+	GetStream(ctx context.Context, key string) (*ValueStream, error)
+	Search(ctx context.Context, req SearchRequest) ([]*CacheEntry, error)
+	Delete(ctx context.Context, key string) error
+	Lookup(ctx context.Context, req LookupRequest) ([]*LookupResult, error)
+	HealthCheck(ctx context.Context) error
+	// Stats counts the entries held by the store
+	Stats(ctx context.Context) (*Stats, error)
+	// Export calls fn with every live entry whose key starts with prefix and whose id is
+	// greater than after, in id order. It stops at the first error fn returns.
+	Export(ctx context.Context, prefix string, after int, fn func(*CacheEntry) error) error
+}
+
+var _ Store = (*CacheRepository)(nil)
+
+// ValueStream is an entry whose value is read from the stream rather than Value, which is empty
+type ValueStream struct {
+	*CacheEntry
+	io.ReadCloser
+
+	// Size is the length of the value in bytes
+	Size int64
+}
+
+// Stats summarizes the contents of a store
+type Stats struct {
+	Entries    int64 `json:"entries"`     // live entries
+	Expired    int64 `json:"expired"`     // expired entries not yet removed
+	ValueBytes int64 `json:"value_bytes"` // total size of live values
+}
+
+// exportBatchSize is how many entries Export reads per query
+const exportBatchSize = 500
+
+// Namespace returns the namespace of a key: the part before the first ':', or "" when
+// the key has none
+func Namespace(key string) string {
+	namespace, _, ok := strings.Cut(key, ":")
+	if !ok {
+		return ""
+	}
+	return namespace
+}
+
+// inNamespaces reports whether key is in one of namespaces, or namespaces is empty
+func inNamespaces(key string, namespaces []string) bool {
+	return len(namespaces) == 0 || slices.Contains(namespaces, Namespace(key))
+}
+
+// createFromStream reads a streamed value into memory and creates it with create, for
+// stores that keep values whole
+func createFromStream(ctx context.Context, req CreateRequest, value io.Reader,
+	create func(ctx context.Context, req CreateRequest) (*CacheEntry, error)) (*CacheEntry, error) {
+	data, err := io.ReadAll(value)
+	if err != nil {
+		return nil, err
+	}
+	req.Value = string(data)
+
+	entry, err := create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	streamed := *entry
+	streamed.Value = ""
+	return &streamed, nil
+}
+
+// streamEntry returns a stream over a value already held in memory
+func streamEntry(entry *CacheEntry) *ValueStream {
+	stream := &ValueStream{
+		ReadCloser: io.NopCloser(strings.NewReader(entry.Value)),
+		Size:       int64(len(entry.Value)),
+	}
+	streamed := *entry
+	streamed.Value = ""
+	stream.CacheEntry = &streamed
+	return stream
+}
+
+// searchLimit clamps a search limit to (0, 100], defaulting to 100
+func searchLimit(limit int) int {
+	if limit <= 0 || limit > 100 {
+		return 100
+	}
+	return limit
+}
+
+// lookupLimit clamps a lookup limit to (0, 100], defaulting to 10
+func lookupLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	if limit > 100 {
+		return 100
+	}
+	return limit
+}
+
+// cosineSimilarity returns the cosine similarity of two equal-length vectors
+func cosineSimilarity(a, b []float32) (float64, bool) {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0, false
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, false
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), true
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+)
+
+// TieredStore puts a bounded in-process L1 in front of another Store. Exact key reads
+// and repeated semantic lookups are served from L1; everything else goes to the backing store.
+type TieredStore struct {
+	next      Store
+	l1        *l1Cache
+	ttl       time.Duration
+	lookupTTL time.Duration
+
+	// generation is bumped on every write or invalidation. It versions cached lookup
+	// results and stops reads that raced a write from caching what they fetched.
+	generation atomic.Uint64
+}
+
+var _ Store = (*TieredStore)(nil)
+
+// NewTieredStore wraps next with an L1 holding at most maxBytes. Entries stay in L1 for at
+// most ttl (or until their own expiry), lookup results for at most lookupTTL.
+func NewTieredStore(next Store, maxBytes int64, ttl, lookupTTL time.Duration) *TieredStore {
+	return &TieredStore{
+		next:      next,
+		l1:        newL1Cache(maxBytes),
+		ttl:       ttl,
+		lookupTTL: lookupTTL,
+	}
+}
+
+// Create writes through to the backing store and caches the new entry
+func (t *TieredStore) Create(ctx context.Context, req CreateRequest) (*CacheEntry, error) {
+	entry, err := t.next.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Invalidate(entry.Key)
+	t.cacheEntry(ctx, copyEntry(entry), t.generation.Load())
+
+	return entry, nil
+}
+
+// Put writes through to the backing store and caches the entry that replaced any other
+func (t *TieredStore) Put(ctx context.Context, req CreateRequest) (*CacheEntry, bool, error) {
+	entry, replaced, err := t.next.Put(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	t.Invalidate(entry.Key)
+	t.cacheEntry(ctx, copyEntry(entry), t.generation.Load())
+
+	return entry, replaced, nil
+}
+
+// Expire updates the expiry in the backing store and drops key from L1
+func (t *TieredStore) Expire(ctx context.Context, key string, expiresAt *time.Time) error {
+	err := t.next.Expire(ctx, key, expiresAt)
+	t.Invalidate(key)
+	return err
+}
+
+// Get serves key from L1, falling back to the backing store on a miss
+func (t *TieredStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	if v, ok := t.l1.get(entryCacheKey(key), time.Now()); ok {
+		metrics.RecordTierResult(ctx, "l1", "get", true)
+		return copyEntry(v.(*CacheEntry)), nil
+	}
+	metrics.RecordTierResult(ctx, "l1", "get", false)
+
+	gen := t.generation.Load()
+	entry, err := t.next.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		metrics.RecordTierResult(ctx, "store", "get", false)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordTierResult(ctx, "store", "get", true)
+
+	t.cacheEntry(ctx, copyEntry(entry), gen)
+	return entry, nil
+}
+
+// CreateStream writes through to the backing store. Streamed values are not cached, as
+// they are typically too large for L1.
+func (t *TieredStore) CreateStream(ctx context.Context, req CreateRequest, value io.Reader) (*CacheEntry, error) {
+	entry, err := t.next.CreateStream(ctx, req, value)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Invalidate(entry.Key)
+	return entry, nil
+}
+
+// GetStream serves a value held in L1, and otherwise streams it from the backing store
+// without caching it
+func (t *TieredStore) GetStream(ctx context.Context, key string) (*ValueStream, error) {
+	if v, ok := t.l1.get(entryCacheKey(key), time.Now()); ok {
+		metrics.RecordTierResult(ctx, "l1", "get", true)
+		return streamEntry(copyEntry(v.(*CacheEntry))), nil
+	}
+	metrics.RecordTierResult(ctx, "l1", "get", false)
+
+	return t.next.GetStream(ctx, key)
+}
+
+// Search always queries the backing store
+func (t *TieredStore) Search(ctx context.Context, req SearchRequest) ([]*CacheEntry, error) {
+	return t.next.Search(ctx, req)
+}
+
+// Delete removes key from the backing store and from L1
+func (t *TieredStore) Delete(ctx context.Context, key string) error {
+	err := t.next.Delete(ctx, key)
+	t.Invalidate(key)
+	return err
+}
+
+// Lookup serves repeated identical lookups from L1 until a write invalidates them
+func (t *TieredStore) Lookup(ctx context.Context, req LookupRequest) ([]*LookupResult, error) {
+	gen := t.generation.Load()
+	cacheKey := lookupCacheKey(req, gen)
+
+	if v, ok := t.l1.get(cacheKey, time.Now()); ok {
+		metrics.RecordTierResult(ctx, "l1", "lookup", true)
+		return copyLookupResults(v.([]*LookupResult)), nil
+	}
+	metrics.RecordTierResult(ctx, "l1", "lookup", false)
+
+	results, err := t.next.Lookup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordTierResult(ctx, "store", "lookup", len(results) > 0)
+
+	if t.generation.Load() == gen {
+		expiresAt := time.Now().Add(t.lookupTTL)
+		var size int64
+		for _, r := range results {
+			size += entrySize(r.CacheEntry) + 8
+			if r.ExpiresAt != nil && r.ExpiresAt.Before(expiresAt) {
+				expiresAt = *r.ExpiresAt
+			}
+		}
+		evicted := t.l1.set(cacheKey, copyLookupResults(results), size, expiresAt)
+		metrics.RecordL1Evictions(ctx, evicted)
+	}
+
+	return results, nil
+}
+
+// HealthCheck reports the health of the backing store
+func (t *TieredStore) HealthCheck(ctx context.Context) error {
+	return t.next.HealthCheck(ctx)
+}
+
+// Stats reports the contents of the backing store
+func (t *TieredStore) Stats(ctx context.Context) (*Stats, error) {
+	return t.next.Stats(ctx)
+}
+
+// Export always reads from the backing store
+func (t *TieredStore) Export(ctx context.Context, prefix string, after int, fn func(*CacheEntry) error) error {
+	return t.next.Export(ctx, prefix, after, fn)
+}
+
+// Invalidate drops key from L1 along with every cached lookup result
+func (t *TieredStore) Invalidate(key string) {
+	t.generation.Add(1)
+	t.l1.delete(entryCacheKey(key))
+}
+
+// Flush drops everything held in L1
+func (t *TieredStore) Flush() {
+	t.generation.Add(1)
+	t.l1.clear()
+}
+
+// Usage returns the number of L1 items and their accounted bytes
+func (t *TieredStore) Usage() (int64, int64) {
+	return t.l1.usage()
+}
+
+// cacheEntry stores entry in L1 unless a write happened since gen was read
+func (t *TieredStore) cacheEntry(ctx context.Context, entry *CacheEntry, gen uint64) {
+	if t.generation.Load() != gen {
+		return
+	}
+
+	expiresAt := time.Now().Add(t.ttl)
+	if entry.ExpiresAt != nil && entry.ExpiresAt.Before(expiresAt) {
+		expiresAt = *entry.ExpiresAt
+	}
+
+	evicted := t.l1.set(entryCacheKey(entry.Key), entry, entrySize(entry), expiresAt)
+	metrics.RecordL1Evictions(ctx, evicted)
+}
+
+// entryCacheKey is the L1 key for an exact key read
+func entryCacheKey(key string) string {
+	return "k:" + key
+}
+
+// lookupCacheKey is the L1 key for a lookup request at the given generation
+func lookupCacheKey(req LookupRequest, gen uint64) string {
+	h := sha256.New()
+	var buf [8]byte
+	for _, f := range req.Embedding {
+		binary.LittleEndian.PutUint32(buf[:4], math.Float32bits(f))
+		h.Write(buf[:4])
+	}
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(req.Threshold))
+	h.Write(buf[:])
+	binary.LittleEndian.PutUint64(buf[:], uint64(lookupLimit(req.Limit)))
+	h.Write(buf[:])
+	for _, namespace := range req.Namespaces {
+		// Namespaces cannot contain ':', so it separates them
+		h.Write([]byte(namespace + ":"))
+	}
+
+	return "l:" + strconv.FormatUint(gen, 10) + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// copyLookupResults deep-copies lookup results so cached values are never shared
+func copyLookupResults(results []*LookupResult) []*LookupResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]*LookupResult, len(results))
+	for i, r := range results {
+		out[i] = &LookupResult{CacheEntry: copyEntry(r.CacheEntry), Score: r.Score}
+	}
+	return out
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook is an endpoint notified of changes to the entries of a namespace
+type Webhook struct {
+	ID        int    `json:"id"`
+	Namespace string `json:"namespace"` // AllNamespaces for every key
+	URL       string `json:"url"`
+	// Events are the event types delivered; empty means all of them
+	Events []string `json:"events"`
+	// Secret signs deliveries; it is only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AllNamespaces subscribes a webhook to every key, whatever its namespace
+const AllNamespaces = "*"
+
+// CreateWebhookRequest registers a webhook
+type CreateWebhookRequest struct {
+	Namespace string   `json:"namespace" validate:"required,max=255"`
+	URL       string   `json:"url" validate:"required"`
+	Events    []string `json:"events,omitempty"`
+	Secret    string   `json:"secret,omitempty"` // generated when empty
+}
+
+// DeadLetter is a delivery that was given up on
+type DeadLetter struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Key        string    `json:"key"`
+	Payload    string    `json:"payload"` // the JSON body that was sent
+	Attempts   int       `json:"attempts"`
+	LastStatus int       `json:"last_status,omitempty"` // HTTP status of the last attempt, if any
+	LastError  string    `json:"last_error"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookStore persists webhooks and their dead letters
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error)
+	// ListWebhooks returns every webhook, including its secret, in id order
+	ListWebhooks(ctx context.Context) ([]*Webhook, error)
+	// DeleteWebhook removes a webhook and its dead letters
+	DeleteWebhook(ctx context.Context, id int) error
+	AddDeadLetter(ctx context.Context, dl *DeadLetter) error
+	// ListDeadLetters returns the most recent dead letters of a webhook, newest first
+	ListDeadLetters(ctx context.Context, webhookID, limit int) ([]*DeadLetter, error)
+}
+
+var _ WebhookStore = (*CacheRepository)(nil)
+
+// Matches reports whether w subscribes to events of eventType on key
+func (w *Webhook) Matches(eventType, key string) bool {
+	if key != "" && w.Namespace != AllNamespaces && w.Namespace != Namespace(key) {
+		return false
+	}
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// deadLetterLimit clamps a dead letter listing limit to (0, 100], defaulting to 100
+func deadLetterLimit(limit int) int {
+	return searchLimit(limit)
+}
+
+// UseEvents reports expired entries removed by SweepExpired, or by a create taking their
+// key, as expired events
+func (r *CacheRepository) UseEvents(publish func(EntryEvent)) {
+	r.events = publish
+}
+
+// SweepExpired deletes entries whose TTL has passed, batchSize at a time, and returns how
+// many it removed. Rows are locked while deleted so concurrent sweepers on other replicas
+// never report the same entry.
+func (r *CacheRepository) SweepExpired(ctx context.Context, batchSize int) (int, error) {
+	if err := r.checkAvailable(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for {
+		rows, err := r.db.QueryContext(ctx, `
+			WITH expired AS (
+				DELETE FROM semcache WHERE id IN (
+					SELECT id FROM semcache
+					WHERE expires_at <= NOW() AT TIME ZONE 'UTC'
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, key
+			), chunks AS (
+				DELETE FROM semcache_chunks WHERE entry_id IN (SELECT id FROM expired)
+			)
+			SELECT key FROM expired
+		`, batchSize)
+		if err != nil {
+			return removed, fmt.Errorf("failed to sweep expired entries: %w", err)
+		}
+
+		n := 0
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return removed, fmt.Errorf("failed to scan expired entry: %w", err)
+			}
+			publishEvent(r.events, EventExpired, key)
+			n++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return removed, fmt.Errorf("error iterating expired entries: %w", err)
+		}
+
+		removed += n
+		if n < batchSize {
+			return removed, nil
+		}
+	}
+}
+
+// CreateWebhook registers a webhook
+func (r *CacheRepository) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	webhook := &Webhook{Namespace: req.Namespace, URL: req.URL, Events: req.Events, Secret: req.Secret}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO semcache_webhooks (namespace, url, events, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, webhook.Namespace, webhook.URL, pq.Array(webhook.Events), webhook.Secret).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks returns every webhook in id order
+func (r *CacheRepository) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, namespace, url, events, secret, created_at
+		FROM semcache_webhooks
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		webhook := &Webhook{}
+		err := rows.Scan(&webhook.ID, &webhook.Namespace, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook; its dead letters are removed by the foreign key
+func (r *CacheRepository) DeleteWebhook(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM semcache_webhooks WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddDeadLetter records a delivery that was given up on
+func (r *CacheRepository) AddDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO semcache_webhook_dead_letters
+			(webhook_id, event_id, event_type, key, payload, attempts, last_status, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, dl.WebhookID, dl.EventID, dl.EventType, dl.Key, dl.Payload, dl.Attempts, dl.LastStatus, dl.LastError,
+	).Scan(&dl.ID, &dl.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add dead letter: %w", err)
+	}
+	return nil
+}
+
+// ListDeadLetters returns the most recent dead letters of a webhook, newest first
+func (r *CacheRepository) ListDeadLetters(ctx context.Context, webhookID, limit int) ([]*DeadLetter, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event_type, key, payload, attempts, last_status, last_error, created_at
+		FROM semcache_webhook_dead_letters
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, webhookID, deadLetterLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var deadLetters []*DeadLetter
+	for rows.Next() {
+		dl := &DeadLetter{}
+		err := rows.Scan(&dl.ID, &dl.WebhookID, &dl.EventID, &dl.EventType, &dl.Key, &dl.Payload,
+			&dl.Attempts, &dl.LastStatus, &dl.LastError, &dl.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		deadLetters = append(deadLetters, dl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dead letters: %w", err)
+	}
+
+	return deadLetters, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestWebhookMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		webhook   Webhook
+		eventType string
+		key       string
+		want      bool
+	}{
+		{"all namespaces", Webhook{Namespace: AllNamespaces}, EventCreated, "user:1", true},
+		{"same namespace", Webhook{Namespace: "user"}, EventCreated, "user:1", true},
+		{"other namespace", Webhook{Namespace: "session"}, EventCreated, "user:1", false},
+		{"subscribed type", Webhook{Namespace: "user", Events: []string{EventDeleted}}, EventDeleted, "user:1", true},
+		{"other type", Webhook{Namespace: "user", Events: []string{EventDeleted}}, EventCreated, "user:1", false},
+		{"bulk eviction in any namespace", Webhook{Namespace: "session"}, EventEvicted, "", true},
+		{"bulk eviction not subscribed", Webhook{Namespace: "session", Events: []string{EventDeleted}}, EventEvicted, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.webhook.Matches(tt.eventType, tt.key); got != tt.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.eventType, tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+// Package ratelimit limits the request rate of each client with token buckets, and the
+// entries and bytes each client stores per day. Counts are kept in memory, so each replica
+// limits the requests it serves.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// Class is a kind of request, limited separately
+type Class string
+
+// Request classes. There is no class for proxied upstream calls: the service never calls
+// an upstream on a client's behalf, as it has no proxy mode.
+const (
+	Read  Class = "read"
+	Write Class = "write"
+)
+
+// Quotas, as reported when one is exceeded
+const (
+	QuotaEntries = "entries"
+	QuotaBytes   = "bytes"
+)
+
+// sweepInterval is how often buckets that have refilled are forgotten
+const sweepInterval = time.Minute
+
+// Limiter tracks the token buckets and daily usage of each client
+type Limiter struct {
+	cfg    *config.RateLimitConfig
+	limits map[Class]limit
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+
+	// day is the UTC day usage is counted for
+	day   time.Time
+	usage map[string]*usage
+}
+
+// limit is the refill rate and capacity of the buckets of a class
+type limit struct {
+	rate  float64 // tokens per second
+	burst float64
+}
+
+// bucketKey identifies the bucket of a client for a class
+type bucketKey struct {
+	class  Class
+	client string
+}
+
+// bucket is a token bucket, holding tokens as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// usage is what a client stored during the current day
+type usage struct {
+	entries int64
+	bytes   int64
+}
+
+// Decision is the outcome of a rate limit check, with what to report in the RateLimit
+// headers
+type Decision struct {
+	Allowed bool
+
+	// Limit is the bucket capacity and Remaining the requests left in it
+	Limit     int
+	Remaining int
+
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+
+	// RetryAfter is how long until the next request would be allowed, if this one was not
+	RetryAfter time.Duration
+
+	// Policy describes the limit for the RateLimit-Policy header
+	Policy string
+}
+
+// New creates a limiter with the limits in cfg
+func New(cfg *config.RateLimitConfig) *Limiter {
+	return &Limiter{
+		cfg: cfg,
+		limits: map[Class]limit{
+			Read:  {rate: float64(cfg.ReadRate), burst: float64(cfg.ReadBurst)},
+			Write: {rate: float64(cfg.WriteRate), burst: float64(cfg.WriteBurst)},
+		},
+		buckets: make(map[bucketKey]*bucket),
+		usage:   make(map[string]*usage),
+	}
+}
+
+// Allow takes a token from client's bucket for class. It reports false for a class
+// without a limit.
+func (l *Limiter) Allow(class Class, client string, now time.Time) (Decision, bool) {
+	lim, ok := l.limits[class]
+	if !ok || lim.rate <= 0 {
+		return Decision{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	key := bucketKey{class: class, client: client}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: lim.burst, updated: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(lim.burst, b.tokens+elapsed*lim.rate)
+		b.updated = now
+	}
+
+	d := Decision{
+		Limit:  int(lim.burst),
+		Policy: fmt.Sprintf("%d;w=%d", int(lim.burst), int(math.Ceil(lim.burst/lim.rate))),
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / lim.rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((lim.burst - b.tokens) / lim.rate)
+	return d, true
+}
+
+// Reserve counts an entry of size bytes against client's daily quotas before it is
+// stored, unless that would take client over one. It returns the quota that would be
+// exceeded, or "" once the entry is counted, and how long until quotas reset. Checking and
+// counting together keeps concurrent writes from all passing the check.
+func (l *Limiter) Reserve(client string, size int64, now time.Time) (string, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.usageOf(client, now)
+	reset := l.day.Add(24 * time.Hour).Sub(now)
+	if l.cfg.DailyEntries > 0 && u.entries >= l.cfg.DailyEntries {
+		return QuotaEntries, reset
+	}
+	if l.cfg.DailyBytes > 0 && u.bytes+size > l.cfg.DailyBytes {
+		return QuotaBytes, reset
+	}
+	u.entries++
+	u.bytes += size
+	return "", reset
+}
+
+// Adjust corrects what Reserve counted for client, by -1 entry and the bytes reserved when
+// the write fails, or by the difference when it stores a different size than reserved
+func (l *Limiter) Adjust(client string, entries, bytes int64, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.usageOf(client, now)
+	u.entries = max(u.entries+entries, 0)
+	u.bytes = max(u.bytes+bytes, 0)
+}
+
+// Client identifies who a request for the entry key, if any, is counted against: the
+// namespace of key, the API key or the client IP, as configured, falling back in that order
+func (l *Limiter) Client(key string, apiKey *models.APIKey, ip string) string {
+	if l.cfg.By == config.RateLimitByNamespace && key != "" {
+		return "namespace:" + models.Namespace(key)
+	}
+	if l.cfg.By != config.RateLimitByIP && apiKey != nil {
+		// Tokens and the admin key are not stored, so have no id
+		if apiKey.ID == 0 {
+			return "key:" + apiKey.Name
+		}
+		return "key:" + strconv.Itoa(apiKey.ID)
+	}
+	return "ip:" + ip
+}
+
+// HasQuotas reports whether any daily quota is set
+func (l *Limiter) HasQuotas() bool {
+	return l.cfg.DailyEntries > 0 || l.cfg.DailyBytes > 0
+}
+
+// Clients returns the number of clients with a bucket that has not refilled
+func (l *Limiter) Clients() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(len(l.buckets))
+}
+
+// usageOf returns client's usage today, starting a new day's counts when the day changes.
+// l.mu must be held.
+func (l *Limiter) usageOf(client string, now time.Time) *usage {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(l.day) {
+		l.day = day
+		clear(l.usage)
+	}
+
+	u, ok := l.usage[client]
+	if !ok {
+		u = &usage{}
+		l.usage[client] = u
+	}
+	return u
+}
+
+// sweep forgets buckets that have refilled, which behave like new ones. l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		lim := l.limits[key.class]
+		if b.tokens+now.Sub(b.updated).Seconds()*lim.rate >= lim.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+)
+
+func TestReserveConcurrent(t *testing.T) {
+	l := New(&config.RateLimitConfig{DailyEntries: 10, DailyBytes: 1000})
+	now := time.Now()
+
+	var reserved atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if quota, _ := l.Reserve("c", 10, now); quota == "" {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := reserved.Load(); got != 10 {
+		t.Errorf("reserved %d entries, want 10", got)
+	}
+}
+
+func TestReserveAndAdjust(t *testing.T) {
+	l := New(&config.RateLimitConfig{DailyEntries: 2, DailyBytes: 100})
+	now := time.Now()
+
+	steps := []struct {
+		name    string
+		reserve int64
+		adjust  [2]int64
+		want    string
+	}{
+		{name: "first", reserve: 60, want: ""},
+		{name: "over bytes", reserve: 50, want: QuotaBytes},
+		{name: "refunded", reserve: 50, adjust: [2]int64{-1, -60}, want: ""},
+		{name: "second", reserve: 10, want: ""},
+		{name: "over entries", reserve: 1, want: QuotaEntries},
+	}
+	for _, step := range steps {
+		if step.adjust != [2]int64{} {
+			l.Adjust("c", step.adjust[0], step.adjust[1], now)
+		}
+		if quota, _ := l.Reserve("c", step.reserve, now); quota != step.want {
+			t.Errorf("%s: got quota %q, want %q", step.name, quota, step.want)
+		}
+	}
+
+	if quota, _ := l.Reserve("c", 1, now.Add(24*time.Hour)); quota != "" {
+		t.Errorf("next day: got quota %q, want none", quota)
+	}
+}
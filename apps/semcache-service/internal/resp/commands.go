@@ -0,0 +1,544 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/validation"
+)
+
+// command is a RESP command. arity is the exact number of arguments including the command
+// name, or minus the minimum number when it takes a variable number. scope is the API key
+// scope it needs when API keys are used, which also picks the rate limit it counts
+// against. keyed is set when its first argument is an entry key.
+type command struct {
+	arity  int
+	noAuth bool
+	scope  string
+	keyed  bool
+	run    func(ctx context.Context, sess *session, args [][]byte)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":    {arity: 2, scope: models.ScopeRead, keyed: true, run: cmdGet},
+		"set":    {arity: -3, scope: models.ScopeWrite, keyed: true, run: cmdSet},
+		"setnx":  {arity: 3, scope: models.ScopeWrite, keyed: true, run: cmdSetNX},
+		"del":    {arity: -2, scope: models.ScopeWrite, keyed: true, run: cmdDel},
+		"exists": {arity: -2, scope: models.ScopeRead, keyed: true, run: cmdExists},
+		"ttl":    {arity: 2, scope: models.ScopeRead, keyed: true, run: cmdTTL},
+		"expire": {arity: 3, scope: models.ScopeWrite, keyed: true, run: cmdExpire},
+		"scan":   {arity: -2, scope: models.ScopeRead, run: cmdScan},
+		"mget":   {arity: -2, scope: models.ScopeRead, keyed: true, run: cmdMGet},
+
+		"auth":    {arity: -2, noAuth: true, run: cmdAuth},
+		"quit":    {arity: -1, noAuth: true, run: cmdQuit},
+		"ping":    {arity: -1, run: cmdPing},
+		"echo":    {arity: 2, run: cmdEcho},
+		"select":  {arity: 2, run: cmdSelect},
+		"client":  {arity: -2, run: cmdClient},
+		"command": {arity: -1, run: cmdCommand},
+	}
+}
+
+// storeTimeout bounds the store calls of one command
+const storeTimeout = 5 * time.Second
+
+func cmdGet(ctx context.Context, sess *session, args [][]byte) {
+	if !sess.allowsKeys(args[1]) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	entry, err := sess.server.store.Get(ctx, string(args[1]))
+	if errors.Is(err, models.ErrNotFound) {
+		sess.w.null()
+		return
+	}
+	if err != nil {
+		sess.storeError(err, "failed to get cache entry")
+		return
+	}
+	sess.w.bulk([]byte(entry.Value))
+}
+
+// cmdSet supports SET key value [EX seconds | PX milliseconds] [NX | XX]. Millisecond
+// TTLs are rounded up to whole seconds.
+func cmdSet(ctx context.Context, sess *session, args [][]byte) {
+	req := models.CreateRequest{Key: string(args[1]), Value: string(args[2])}
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if req.TTL != nil || i+1 == len(args) {
+				sess.errorf("ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n <= 0 {
+				sess.errorf("ERR invalid expire time in 'set' command")
+				return
+			}
+			if strings.EqualFold(string(args[i]), "PX") {
+				n = (n + 999) / 1000
+			}
+			if n > math.MaxInt32 {
+				sess.errorf("ERR invalid expire time in 'set' command")
+				return
+			}
+			ttl := int(n)
+			req.TTL = &ttl
+			i++
+		default:
+			sess.errorf("ERR syntax error")
+			return
+		}
+	}
+	if nx && xx {
+		sess.errorf("ERR syntax error")
+		return
+	}
+	if req.Key == "" {
+		sess.errorf("ERR empty keys are not supported")
+		return
+	}
+	if err := validation.Key(req.Key); err != nil {
+		sess.errorf("ERR key %v", err)
+		return
+	}
+	if !sess.allowsKeys(args[1]) {
+		return
+	}
+	if limits := sess.server.valueLimits; limits != nil && len(req.Value) > limits.MaxValueBytesFor(models.Namespace(req.Key)) {
+		sess.errorf("ERR value exceeds maximum size")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	store := sess.server.store
+	if xx {
+		exists, err := sess.server.exists(ctx, req.Key)
+		if err != nil {
+			sess.storeError(err, "failed to get cache entry")
+			return
+		}
+		if !exists {
+			sess.w.null()
+			return
+		}
+	}
+
+	size := int64(len(req.Value))
+	if !sess.reserveQuota(req.Key, size) {
+		return
+	}
+	if nx {
+		_, err := store.Create(ctx, req)
+		if errors.Is(err, models.ErrKeyExists) {
+			sess.refundQuota(req.Key, size)
+			sess.w.null()
+			return
+		}
+		if err != nil {
+			sess.refundQuota(req.Key, size)
+			sess.storeError(err, "failed to create cache entry")
+			return
+		}
+		sess.w.simple("OK")
+		return
+	}
+
+	if _, _, err := store.Put(ctx, req); err != nil {
+		sess.refundQuota(req.Key, size)
+		sess.storeError(err, "failed to create cache entry")
+		return
+	}
+	sess.w.simple("OK")
+}
+
+// cmdSetNX is the older form of SET key value NX, which some clients still send
+func cmdSetNX(ctx context.Context, sess *session, args [][]byte) {
+	req := models.CreateRequest{Key: string(args[1]), Value: string(args[2])}
+	if req.Key == "" {
+		sess.errorf("ERR empty keys are not supported")
+		return
+	}
+	if err := validation.Key(req.Key); err != nil {
+		sess.errorf("ERR key %v", err)
+		return
+	}
+	if !sess.allowsKeys(args[1]) {
+		return
+	}
+	if limits := sess.server.valueLimits; limits != nil && len(req.Value) > limits.MaxValueBytesFor(models.Namespace(req.Key)) {
+		sess.errorf("ERR value exceeds maximum size")
+		return
+	}
+
+	size := int64(len(req.Value))
+	if !sess.reserveQuota(req.Key, size) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	_, err := sess.server.store.Create(ctx, req)
+	if errors.Is(err, models.ErrKeyExists) {
+		sess.refundQuota(req.Key, size)
+		sess.w.integer(0)
+		return
+	}
+	if err != nil {
+		sess.refundQuota(req.Key, size)
+		sess.storeError(err, "failed to create cache entry")
+		return
+	}
+	sess.w.integer(1)
+}
+
+func cmdDel(ctx context.Context, sess *session, args [][]byte) {
+	if !sess.allowsKeys(args[1:]...) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	var deleted int64
+	for _, key := range args[1:] {
+		err := sess.server.store.Delete(ctx, string(key))
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			sess.storeError(err, "failed to delete cache entry")
+			return
+		}
+		deleted++
+	}
+	sess.w.integer(deleted)
+}
+
+// cmdExists counts the keys that exist; like Redis, a key given twice counts twice
+func cmdExists(ctx context.Context, sess *session, args [][]byte) {
+	if !sess.allowsKeys(args[1:]...) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	var found int64
+	for _, key := range args[1:] {
+		exists, err := sess.server.exists(ctx, string(key))
+		if err != nil {
+			sess.storeError(err, "failed to get cache entry")
+			return
+		}
+		if exists {
+			found++
+		}
+	}
+	sess.w.integer(found)
+}
+
+func cmdTTL(ctx context.Context, sess *session, args [][]byte) {
+	if !sess.allowsKeys(args[1]) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	stream, err := sess.server.store.GetStream(ctx, string(args[1]))
+	if errors.Is(err, models.ErrNotFound) {
+		sess.w.integer(-2)
+		return
+	}
+	if err != nil {
+		sess.storeError(err, "failed to get cache entry")
+		return
+	}
+	stream.Close()
+
+	if stream.ExpiresAt == nil {
+		sess.w.integer(-1)
+		return
+	}
+	remaining := (time.Until(*stream.ExpiresAt) + 500*time.Millisecond) / time.Second
+	if remaining < 0 {
+		remaining = 0
+	}
+	sess.w.integer(int64(remaining))
+}
+
+// cmdExpire sets a key's TTL in place, keeping the rest of the entry. A TTL of zero or
+// less deletes the key, as in Redis.
+func cmdExpire(ctx context.Context, sess *session, args [][]byte) {
+	seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		sess.errorf("ERR value is not an integer or out of range")
+		return
+	}
+	if seconds > math.MaxInt32 {
+		sess.errorf("ERR invalid expire time in 'expire' command")
+		return
+	}
+	if !sess.allowsKeys(args[1]) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	store := sess.server.store
+	key := string(args[1])
+	if seconds <= 0 {
+		err := store.Delete(ctx, key)
+		if errors.Is(err, models.ErrNotFound) {
+			sess.w.integer(0)
+			return
+		}
+		if err != nil {
+			sess.storeError(err, "failed to delete cache entry")
+			return
+		}
+		sess.w.integer(1)
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(seconds) * time.Second)
+	err = store.Expire(ctx, key, &expiresAt)
+	if errors.Is(err, models.ErrNotFound) {
+		sess.w.integer(0)
+		return
+	}
+	if err != nil {
+		sess.storeError(err, "failed to expire cache entry")
+		return
+	}
+	sess.w.integer(1)
+}
+
+// scanCount is how many entries a SCAN examines when no COUNT is given, as in Redis
+const scanCount = 10
+
+// errScanPage stops an export once a SCAN has examined a page of entries
+var errScanPage = errors.New("scan page complete")
+
+// cmdScan supports SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]. The cursor is
+// the id of the last entry examined, so a scan walks entries in id order as Export does.
+// Each call examines up to COUNT entries, of which only those matching the pattern are
+// returned; the scan is complete when the cursor returned is 0.
+func cmdScan(ctx context.Context, sess *session, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil || cursor > math.MaxInt {
+		sess.errorf("ERR invalid cursor")
+		return
+	}
+
+	pattern := "*"
+	count := scanCount
+	typeMatches := true
+	for i := 2; i < len(args); i++ {
+		if i+1 == len(args) {
+			sess.errorf("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 1 {
+				sess.errorf("ERR syntax error")
+				return
+			}
+			count = n
+		case "TYPE":
+			typeMatches = strings.EqualFold(string(args[i+1]), "string")
+		default:
+			sess.errorf("ERR syntax error")
+			return
+		}
+		i++
+	}
+
+	var (
+		keys     []string
+		examined int
+		lastID   int
+	)
+	if typeMatches {
+		ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+		defer cancel()
+
+		err := sess.server.store.Export(ctx, globPrefix(pattern), int(cursor), func(entry *models.CacheEntry) error {
+			examined++
+			lastID = entry.ID
+			allowed := sess.apiKey == nil || sess.apiKey.AllowsNamespace(models.Namespace(entry.Key))
+			if allowed && matchGlob(pattern, entry.Key) {
+				keys = append(keys, entry.Key)
+			}
+			if examined == count {
+				return errScanPage
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errScanPage) {
+			sess.storeError(err, "failed to scan cache entries")
+			return
+		}
+	}
+
+	next := 0
+	if examined == count {
+		next = lastID
+	}
+	sess.w.array(2)
+	sess.w.bulk([]byte(strconv.Itoa(next)))
+	sess.w.array(len(keys))
+	for _, key := range keys {
+		sess.w.bulk([]byte(key))
+	}
+}
+
+func cmdMGet(ctx context.Context, sess *session, args [][]byte) {
+	if !sess.allowsKeys(args[1:]...) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	values := make([]*string, len(args)-1)
+	for i, key := range args[1:] {
+		entry, err := sess.server.store.Get(ctx, string(key))
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			sess.storeError(err, "failed to get cache entry")
+			return
+		}
+		values[i] = &entry.Value
+	}
+
+	sess.w.array(len(values))
+	for _, value := range values {
+		if value == nil {
+			sess.w.null()
+			continue
+		}
+		sess.w.bulk([]byte(*value))
+	}
+}
+
+// cmdAuth supports AUTH password and AUTH default password. With API keys in use, the
+// password is an API key or JWT.
+func cmdAuth(ctx context.Context, sess *session, args [][]byte) {
+	if len(args) > 3 {
+		sess.errorf("ERR syntax error")
+		return
+	}
+	if sess.server.password == "" && sess.server.authn == nil {
+		sess.errorf("ERR AUTH called without any password configured")
+		return
+	}
+
+	password := args[len(args)-1]
+	if len(args) == 3 && string(args[1]) != "default" {
+		sess.errorf("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+
+	if authn := sess.server.authn; authn != nil {
+		ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+		defer cancel()
+
+		key, err := authn.Authenticate(ctx, string(password))
+		if err != nil {
+			sess.errorf("WRONGPASS invalid username-password pair or user is disabled.")
+			return
+		}
+		sess.apiKey = key
+	} else if !sess.server.checkPassword(password) {
+		sess.errorf("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+	sess.authed = true
+	sess.w.simple("OK")
+}
+
+func cmdQuit(_ context.Context, sess *session, _ [][]byte) {
+	sess.quit = true
+	sess.w.simple("OK")
+}
+
+func cmdPing(_ context.Context, sess *session, args [][]byte) {
+	switch len(args) {
+	case 1:
+		sess.w.simple("PONG")
+	case 2:
+		sess.w.bulk(args[1])
+	default:
+		sess.errorf("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(_ context.Context, sess *session, args [][]byte) {
+	sess.w.bulk(args[1])
+}
+
+// cmdSelect accepts database 0 only; there is a single keyspace
+func cmdSelect(_ context.Context, sess *session, args [][]byte) {
+	if string(args[1]) != "0" {
+		sess.errorf("ERR DB index is out of range")
+		return
+	}
+	sess.w.simple("OK")
+}
+
+// cmdClient accepts the connection naming commands clients send on connect
+func cmdClient(_ context.Context, sess *session, args [][]byte) {
+	switch strings.ToUpper(string(args[1])) {
+	case "SETNAME", "SETINFO":
+		sess.w.simple("OK")
+	default:
+		sess.errorf("ERR unknown subcommand '%s'", args[1])
+	}
+}
+
+// cmdCommand replies with no command documentation, which clients treat as unknown
+func cmdCommand(_ context.Context, sess *session, _ [][]byte) {
+	sess.w.array(0)
+}
+
+// exists reports whether key has a live entry, without reading its value
+func (s *Server) exists(ctx context.Context, key string) (bool, error) {
+	stream, err := s.store.GetStream(ctx, key)
+	if errors.Is(err, models.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	stream.Close()
+	return true, nil
+}
@@ -0,0 +1,80 @@
+package resp
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+)
+
+// UseRateLimits limits the command rate of each client, and what it stores per day, with
+// limiter, counting commands against the same clients as HTTP requests
+func (s *Server) UseRateLimits(limiter *ratelimit.Limiter) {
+	s.limiter = limiter
+}
+
+// allowCommand takes a command needing scope from the rate limit of the client. key is
+// the entry key the command is for, if any. It reports false, after replying with an
+// error, once the client has run out of commands.
+func (sess *session) allowCommand(ctx context.Context, scope, key string) bool {
+	limiter := sess.server.limiter
+	if limiter == nil {
+		return true
+	}
+	var class ratelimit.Class
+	switch scope {
+	case models.ScopeRead:
+		class = ratelimit.Read
+	case models.ScopeWrite:
+		class = ratelimit.Write
+	default:
+		return true
+	}
+
+	d, limited := limiter.Allow(class, sess.rateLimitClient(key), time.Now())
+	if !limited {
+		return true
+	}
+	metrics.RecordRateLimit(ctx, string(class), d.Allowed)
+	if !d.Allowed {
+		sess.errorf("ERR rate limit exceeded, retry in %d seconds", ceilSeconds(d.RetryAfter))
+	}
+	return d.Allowed
+}
+
+// reserveQuota counts storing size bytes under key against the client's daily quotas. It
+// reports false, after replying with an error, if that would exceed one. A reservation for
+// a write that fails or stores nothing is returned with refundQuota.
+func (sess *session) reserveQuota(key string, size int64) bool {
+	limiter := sess.server.limiter
+	if limiter == nil || !limiter.HasQuotas() {
+		return true
+	}
+	quota, reset := limiter.Reserve(sess.rateLimitClient(key), size, time.Now())
+	if quota == "" {
+		return true
+	}
+	metrics.RecordQuotaRejection(context.Background(), quota)
+	sess.errorf("ERR daily %s quota exceeded, retry in %d seconds", quota, ceilSeconds(reset))
+	return false
+}
+
+// refundQuota returns what reserveQuota counted for size bytes under key
+func (sess *session) refundQuota(key string, size int64) {
+	if limiter := sess.server.limiter; limiter != nil && limiter.HasQuotas() {
+		limiter.Adjust(sess.rateLimitClient(key), -1, -size, time.Now())
+	}
+}
+
+// rateLimitClient identifies the client a command for key is counted against
+func (sess *session) rateLimitClient(key string) string {
+	return sess.server.limiter.Client(key, sess.apiKey, sess.ip)
+}
+
+// ceilSeconds returns d in whole seconds, rounded up
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,137 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxArgs bounds the number of arguments in one command
+const maxArgs = 1024 * 1024
+
+// errProtocol is returned for input that is not valid RESP. The connection is closed
+// after replying, since the stream can no longer be framed.
+var errProtocol = errors.New("protocol error")
+
+// reader reads commands sent as RESP arrays of bulk strings, or as inline commands
+type reader struct {
+	br *bufio.Reader
+
+	// maxBulk bounds the length of a single argument
+	maxBulk int
+}
+
+// readCommand reads the next command and its arguments
+func (r *reader) readCommand() ([][]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		arg, err := r.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads one bulk string
+func (r *reader) readBulk() ([]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return nil, fmt.Errorf("%w: expected '$'", errProtocol)
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+	}
+	if n > r.maxBulk {
+		return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+	}
+
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r.br, buf); err != nil {
+		return nil, err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, fmt.Errorf("%w: expected CRLF after bulk string", errProtocol)
+	}
+	return buf[:n], nil
+}
+
+// readLine reads a line terminated by CRLF (or a bare LF, for inline commands) without
+// its terminator
+func (r *reader) readLine() ([]byte, error) {
+	line, err := r.br.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("%w: line too long", errProtocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+	return line, nil
+}
+
+// writer writes RESP2 replies
+type writer struct {
+	bw *bufio.Writer
+}
+
+func (w *writer) simple(s string) {
+	w.bw.WriteByte('+')
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) error(msg string) {
+	w.bw.WriteByte('-')
+	w.bw.WriteString(msg)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.bw.WriteByte(':')
+	w.bw.WriteString(strconv.FormatInt(n, 10))
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) bulk(b []byte) {
+	w.bw.WriteByte('$')
+	w.bw.WriteString(strconv.Itoa(len(b)))
+	w.bw.WriteString("\r\n")
+	w.bw.Write(b)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	w.bw.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.bw.WriteByte('*')
+	w.bw.WriteString(strconv.Itoa(n))
+	w.bw.WriteString("\r\n")
+}
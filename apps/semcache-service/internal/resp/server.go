@@ -0,0 +1,270 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+)
+
+// defaultMaxBulk bounds arguments when no value limits are configured
+const defaultMaxBulk = 10 << 20
+
+// ValueLimits reports the maximum value size per namespace
+type ValueLimits interface {
+	MaxValueBytesFor(namespace string) int
+	LargestMaxValueBytes() int
+}
+
+// Authenticator checks an API key or JWT sent with AUTH
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*models.APIKey, error)
+}
+
+// Server speaks the Redis RESP2 protocol over a store, so Redis clients can use it for
+// exact-key caching
+type Server struct {
+	store       models.Store
+	valueLimits ValueLimits
+	password    string
+	authn       Authenticator
+	maxBulk     int
+
+	// limiter applies rate limits and daily quotas, if enabled (see UseRateLimits)
+	limiter *ratelimit.Limiter
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New creates a new RESP server over store
+func New(store models.Store) *Server {
+	return &Server{
+		store:   store,
+		maxBulk: defaultMaxBulk,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// UseValueLimits rejects values over the size limit of their key's namespace, and bounds
+// every argument by the largest limit
+func (s *Server) UseValueLimits(limits ValueLimits) {
+	s.valueLimits = limits
+	s.maxBulk = limits.LargestMaxValueBytes()
+}
+
+// UsePassword requires clients to AUTH with password before running commands
+func (s *Server) UsePassword(password string) {
+	s.password = password
+}
+
+// UseAPIKeys requires clients to AUTH with an API key or JWT instead of a password. Commands
+// then need the scope the matching HTTP route does, and keys must be in the namespaces the
+// API key allows.
+func (s *Server) UseAPIKeys(authn Authenticator) {
+	s.authn = authn
+}
+
+// Serve accepts connections on lis until Close is called
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.listener = lis
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes open ones and waits for their commands to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// session is the state of one client connection
+type session struct {
+	server *Server
+	r      *reader
+	w      *writer
+	authed bool
+	apiKey *models.APIKey // the key the session authenticated with, if API keys are used
+	ip     string         // the client IP, which rate limits may count commands against
+	failed bool           // whether the current command replied with an error
+	quit   bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	sess := &session{
+		server: s,
+		r:      &reader{br: bufio.NewReader(conn), maxBulk: s.maxBulk},
+		w:      &writer{bw: bufio.NewWriter(conn)},
+		authed: s.password == "" && s.authn == nil,
+	}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		sess.ip = addr.IP.String()
+	}
+
+	for !sess.quit {
+		args, err := sess.r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				sess.w.error("ERR " + err.Error())
+				sess.w.bw.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		sess.run(args)
+
+		// Flush once a pipeline of commands has been answered
+		if sess.r.br.Buffered() == 0 || sess.quit {
+			if err := sess.w.bw.Flush(); err != nil {
+				logger.Logger.Debug(fmt.Sprintf("RESP connection write error: %v", err))
+				return
+			}
+		}
+	}
+}
+
+// run executes one command and records its metrics
+func (sess *session) run(args [][]byte) {
+	name := strings.ToLower(string(args[0]))
+	handler, ok := commands[name]
+	if !ok {
+		sess.errorf("ERR unknown command '%s'", args[0])
+		metrics.RecordRESPCommand(context.Background(), "unknown", false, 0)
+		return
+	}
+	// Connections outlive keys and tokens, so sessions end with them
+	if sess.apiKey != nil && sess.apiKey.Expired(time.Now()) {
+		sess.authed, sess.apiKey = false, nil
+	}
+	if !sess.authed && !handler.noAuth {
+		sess.errorf("NOAUTH Authentication required.")
+		return
+	}
+	if sess.apiKey != nil && handler.scope != "" && !sess.apiKey.HasScope(handler.scope) {
+		sess.errorf("NOPERM this user has no permissions to run the '%s' command", name)
+		return
+	}
+	if handler.arity > 0 && len(args) != handler.arity || handler.arity < 0 && len(args) < -handler.arity {
+		sess.errorf("ERR wrong number of arguments for '%s' command", name)
+		return
+	}
+
+	ctx, span := otel.Tracer("semcache-service").Start(context.Background(), "RESP "+strings.ToUpper(name),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", name)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	sess.failed = false
+	var key string
+	if handler.keyed {
+		key = string(args[1])
+	}
+	if sess.allowCommand(ctx, handler.scope, key) {
+		handler.run(ctx, sess, args)
+	}
+	metrics.RecordRESPCommand(ctx, name, !sess.failed, time.Since(start))
+}
+
+func (sess *session) errorf(format string, args ...interface{}) {
+	sess.failed = true
+	sess.w.error(fmt.Sprintf(format, args...))
+}
+
+// storeError replies to a store failure
+func (sess *session) storeError(err error, msg string) {
+	if errors.Is(err, models.ErrUnavailable) {
+		sess.errorf("ERR cache storage unavailable")
+		return
+	}
+	sess.errorf("ERR %s", msg)
+}
+
+// allowsKeys reports whether the session's API key, if there is one, may access every key,
+// replying with an error if not
+func (sess *session) allowsKeys(keys ...[]byte) bool {
+	if sess.apiKey == nil {
+		return true
+	}
+	for _, key := range keys {
+		if !sess.apiKey.AllowsNamespace(models.Namespace(string(key))) {
+			sess.errorf("NOPERM this user has no permissions to access one of the keys used as arguments")
+			return false
+		}
+	}
+	return true
+}
+
+// checkPassword compares password to the configured one in constant time
+func (s *Server) checkPassword(password []byte) bool {
+	return subtle.ConstantTimeCompare(password, []byte(s.password)) == 1
+}
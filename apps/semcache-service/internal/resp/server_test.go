@@ -0,0 +1,328 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"github.com/nextinterfaces/semcache-service/internal/ratelimit"
+)
+
+// testClient sends commands to a RESP server and reads its replies
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// startServer serves the RESP protocol over a memory store, set up with setup, and returns
+// the store and a connected client
+func startServer(t *testing.T, setup func(*Server)) (*models.MemoryStore, *testClient) {
+	t.Helper()
+
+	store := models.NewMemoryStore(0, 0)
+	t.Cleanup(func() { store.Close() })
+
+	srv := New(store)
+	if setup != nil {
+		setup(srv)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return store, &testClient{t: t, conn: conn, br: bufio.NewReader(conn)}
+}
+
+// do sends a command and returns its reply, with arrays flattened to space-separated
+// elements, nulls as "(nil)" and errors prefixed with "-"
+func (c *testClient) do(args ...string) string {
+	c.t.Helper()
+
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(cmd)); err != nil {
+		c.t.Fatalf("failed to send %v: %v", args, err)
+	}
+	return c.reply()
+}
+
+func (c *testClient) reply() string {
+	c.t.Helper()
+
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("failed to read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:]
+	case '-':
+		return line
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)"
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			c.t.Fatalf("failed to read bulk: %v", err)
+		}
+		return string(buf[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		elems := make([]string, n)
+		for i := range elems {
+			elems[i] = c.reply()
+		}
+		return strings.Join(elems, " ")
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return ""
+}
+
+// testAuthenticator accepts the API keys it holds
+type testAuthenticator map[string]*models.APIKey
+
+func (a testAuthenticator) Authenticate(_ context.Context, credential string) (*models.APIKey, error) {
+	if key, ok := a[credential]; ok {
+		return key, nil
+	}
+	return nil, errors.New("invalid API key")
+}
+
+func TestAPIKeys(t *testing.T) {
+	authn := testAuthenticator{
+		"sck_team": {Name: "team", Scopes: []string{models.ScopeRead, models.ScopeWrite}, Namespaces: []string{"team"}},
+		"sck_read": {Name: "read", Scopes: []string{models.ScopeRead}},
+	}
+	store, c := startServer(t, func(s *Server) {
+		s.UsePassword("secret")
+		s.UseAPIKeys(authn)
+	})
+	if _, err := store.Create(context.Background(), models.CreateRequest{Key: "other:a", Value: "v"}); err != nil {
+		t.Fatalf("failed to create entry: %v", err)
+	}
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"GET", "team:a"}, "-NOAUTH Authentication required."},
+		{[]string{"AUTH", "secret"}, "-WRONGPASS invalid username-password pair or user is disabled."},
+		{[]string{"AUTH", "sck_team"}, "OK"},
+		{[]string{"SET", "team:a", "1"}, "OK"},
+		{[]string{"GET", "team:a"}, "1"},
+		{[]string{"SET", "other:b", "1"}, "-NOPERM this user has no permissions to access one of the keys used as arguments"},
+		{[]string{"GET", "other:a"}, "-NOPERM this user has no permissions to access one of the keys used as arguments"},
+		{[]string{"MGET", "team:a", "other:a"}, "-NOPERM this user has no permissions to access one of the keys used as arguments"},
+		{[]string{"DEL", "other:a"}, "-NOPERM this user has no permissions to access one of the keys used as arguments"},
+		{[]string{"SCAN", "0"}, "0 team:a"},
+		{[]string{"AUTH", "default", "sck_read"}, "OK"},
+		{[]string{"GET", "other:a"}, "v"},
+		{[]string{"SET", "other:a", "2"}, "-NOPERM this user has no permissions to run the 'set' command"},
+		{[]string{"DEL", "other:a"}, "-NOPERM this user has no permissions to run the 'del' command"},
+	}
+	for _, step := range steps {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	_, c := startServer(t, func(s *Server) { s.UsePassword("secret") })
+
+	for _, step := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "a", "1"}, "-NOAUTH Authentication required."},
+		{[]string{"AUTH", "wrong"}, "-WRONGPASS invalid username-password pair or user is disabled."},
+		{[]string{"AUTH", "secret"}, "OK"},
+		{[]string{"SET", "a", "1"}, "OK"},
+	} {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+}
+
+func TestSetReplaces(t *testing.T) {
+	store, c := startServer(t, nil)
+	ctx := context.Background()
+
+	for _, step := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "a", "1", "EX", "100"}, "OK"},
+		{[]string{"SET", "a", "2"}, "OK"},
+		{[]string{"GET", "a"}, "2"},
+		{[]string{"TTL", "a"}, "-1"},
+		{[]string{"SET", "a", "3", "XX"}, "OK"},
+		{[]string{"SET", "b", "1", "XX"}, "(nil)"},
+	} {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil || stats.Entries != 1 {
+		t.Errorf("got %+v entries (%v), want 1", stats, err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	store, c := startServer(t, nil)
+	ctx := context.Background()
+
+	created, err := store.Create(ctx, models.CreateRequest{Key: "a", Value: "v", Metadata: "m"})
+	if err != nil {
+		t.Fatalf("failed to create entry: %v", err)
+	}
+
+	for _, step := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"EXPIRE", "a", "100"}, "1"},
+		{[]string{"TTL", "a"}, "100"},
+		{[]string{"EXPIRE", "missing", "100"}, "0"},
+	} {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	entry, err := store.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("failed to get entry: %v", err)
+	}
+	if entry.ID != created.ID || !entry.CreatedAt.Equal(created.CreatedAt) || entry.Value != "v" || entry.Metadata != "m" {
+		t.Errorf("got %+v, want %+v with only the expiry changed", entry, created)
+	}
+
+	if got := c.do("EXPIRE", "a", "0"); got != "1" {
+		t.Errorf("EXPIRE a 0: got %q, want 1", got)
+	}
+	if _, err := store.Get(ctx, "a"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("got %v after EXPIRE 0, want ErrNotFound", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	store, c := startServer(t, nil)
+
+	want := map[string]bool{}
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		if _, err := store.Create(context.Background(), models.CreateRequest{Key: key, Value: "v"}); err != nil {
+			t.Fatalf("failed to create entry: %v", err)
+		}
+		want[key] = true
+		if i%5 == 0 {
+			store.Create(context.Background(), models.CreateRequest{Key: fmt.Sprintf("other:%d", i), Value: "v"})
+		}
+	}
+
+	got := map[string]bool{}
+	cursor, calls := "0", 0
+	for {
+		calls++
+		if calls > 10 {
+			t.Fatalf("scan did not finish")
+		}
+		fields := strings.Fields(c.do("SCAN", cursor, "MATCH", "user:*", "COUNT", "7"))
+		cursor = fields[0]
+		if len(fields)-1 > 7 {
+			t.Errorf("got %d keys, want at most COUNT", len(fields)-1)
+		}
+		for _, key := range fields[1:] {
+			if got[key] {
+				t.Errorf("%s returned twice", key)
+			}
+			got[key] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("scanned %d keys, want %d", len(got), len(want))
+	}
+	for key := range want {
+		if !got[key] {
+			t.Errorf("%s not scanned", key)
+		}
+	}
+
+	if reply := c.do("SCAN", "0", "TYPE", "hash"); reply != "0 " {
+		t.Errorf("SCAN TYPE hash: got %q, want no keys", reply)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	limiter := ratelimit.New(&config.RateLimitConfig{Enabled: true, By: config.RateLimitByIP, WriteRate: 1, WriteBurst: 2})
+	_, c := startServer(t, func(s *Server) { s.UseRateLimits(limiter) })
+
+	for _, step := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "a", "1"}, "OK"},
+		{[]string{"SET", "b", "1"}, "OK"},
+		{[]string{"GET", "a"}, "1"},
+		{[]string{"SET", "c", "1"}, "-ERR rate limit exceeded, retry in 1 seconds"},
+	} {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	limiter := ratelimit.New(&config.RateLimitConfig{Enabled: true, By: config.RateLimitByIP, DailyEntries: 2})
+	_, c := startServer(t, func(s *Server) { s.UseRateLimits(limiter) })
+
+	// Writes that store nothing are refunded, so only two entries count
+	for _, step := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "a", "1"}, "OK"},
+		{[]string{"SET", "a", "1", "NX"}, "(nil)"},
+		{[]string{"SETNX", "a", "1"}, "0"},
+		{[]string{"SET", "b", "1", "XX"}, "(nil)"},
+		{[]string{"SETNX", "b", "1"}, "1"},
+	} {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+	if got := c.do("SET", "c", "1"); !strings.HasPrefix(got, "-ERR daily entries quota exceeded") {
+		t.Errorf("SET over quota: got %q", got)
+	}
+}
@@ -9,5 +9,18 @@ func RedactedConfig(cfg *config.Config) *config.Config {
 	copy.Database.Database = "***"
 	copy.Database.User = "***"
 	copy.Database.Password = "***"
+	copy.Database.ReadReplicas = make([]string, len(cfg.Database.ReadReplicas))
+	for i := range copy.Database.ReadReplicas {
+		copy.Database.ReadReplicas[i] = "***"
+	}
+	if copy.Storage.EncryptionKeys != "" {
+		copy.Storage.EncryptionKeys = "***"
+	}
+	if copy.Auth.AdminKey != "" {
+		copy.Auth.AdminKey = "***"
+	}
+	if copy.Server.RESPPassword != "" {
+		copy.Server.RESPPassword = "***"
+	}
 	return &copy
 }
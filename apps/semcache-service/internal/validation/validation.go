@@ -0,0 +1,277 @@
+// Package validation checks request structs against their validate struct tags. It is
+// registered as Echo's validator, so handlers call c.Validate after binding a request.
+//
+// A tag holds comma-separated rules:
+//
+//	required       the field is not its zero value (or nil, or empty)
+//	omitempty      skip the remaining rules when the field is its zero value
+//	min=N, max=N   bounds on a number, or on the length of a string (in characters) or slice
+//	maxbytes=N     bound on the length of a string in bytes
+//	oneof=A B      the string is one of the space-separated values
+//	key            the string is a valid cache key (see Key)
+//
+// Fields are reported by their json tag name, or failing that their query, param or
+// header tag.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxKeyLength is the longest key in characters, the size of the key column
+const MaxKeyLength = 255
+
+// FieldError is a field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors are the fields of a request that failed validation
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator validates structs by their validate tags. It implements echo.Validator.
+type Validator struct {
+	// fields caches the parsed rules of each struct type
+	fields sync.Map // reflect.Type -> []field
+}
+
+// New creates a Validator
+func New() *Validator {
+	return &Validator{}
+}
+
+// field is a struct field with validate rules
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// rule is one parsed validate rule
+type rule struct {
+	name  string
+	param string
+}
+
+// Validate checks i, a struct or pointer to one, returning Errors if any field fails
+func (v *Validator) Validate(i interface{}) error {
+	value := reflect.ValueOf(i)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate %T", i)
+	}
+
+	fields, err := v.fieldsOf(value.Type())
+	if err != nil {
+		return err
+	}
+
+	var errs Errors
+	for _, f := range fields {
+		if msg := checkField(value.Field(f.index), f.rules); msg != "" {
+			errs = append(errs, FieldError{Field: f.name, Message: msg})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldsOf returns the fields of t that have rules, parsing them on first use
+func (v *Validator) fieldsOf(t reflect.Type) ([]field, error) {
+	if cached, ok := v.fields.Load(t); ok {
+		return cached.([]field), nil
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || !sf.IsExported() {
+			continue
+		}
+
+		f := field{index: i, name: fieldName(sf)}
+		for _, part := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(part, "=")
+			r := rule{name: name, param: param}
+			if err := checkRule(r); err != nil {
+				return nil, fmt.Errorf("invalid validate tag on %s.%s: %w", t.Name(), sf.Name, err)
+			}
+			f.rules = append(f.rules, r)
+		}
+		fields = append(fields, f)
+	}
+
+	v.fields.Store(t, fields)
+	return fields, nil
+}
+
+// fieldName is the name a field is reported under
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "query", "param", "header"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// checkRule reports rules that are unknown or have a bad parameter
+func checkRule(r rule) error {
+	switch r.name {
+	case "required", "omitempty", "key":
+		return nil
+	case "min", "max", "maxbytes":
+		if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+			return fmt.Errorf("rule %s needs a number: %w", r.name, err)
+		}
+		return nil
+	case "oneof":
+		if r.param == "" {
+			return errors.New("rule oneof needs values")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown rule %q", r.name)
+}
+
+// checkField applies rules to value, returning a message for the first that fails
+func checkField(value reflect.Value, rules []rule) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if hasRule(rules, "required") {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	for _, r := range rules {
+		switch r.name {
+		case "required":
+			if value.IsZero() || (value.Kind() == reflect.Slice && value.Len() == 0) {
+				return "is required"
+			}
+		case "omitempty":
+			if value.IsZero() {
+				return ""
+			}
+		case "min", "max":
+			if msg := checkBound(value, r); msg != "" {
+				return msg
+			}
+		case "maxbytes":
+			if limit, _ := strconv.Atoi(r.param); value.Kind() == reflect.String && value.Len() > limit {
+				return fmt.Sprintf("must be at most %d bytes", limit)
+			}
+		case "oneof":
+			if value.Kind() == reflect.String && !slices.Contains(strings.Fields(r.param), value.String()) {
+				return "must be one of: " + strings.Join(strings.Fields(r.param), ", ")
+			}
+		case "key":
+			if value.Kind() == reflect.String && value.String() != "" {
+				if err := Key(value.String()); err != nil {
+					return err.Error()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// checkBound applies a min or max rule to a number, or to the length of a string or slice
+func checkBound(value reflect.Value, r rule) string {
+	bound, _ := strconv.ParseFloat(r.param, 64)
+
+	var n float64
+	var unit string
+	switch value.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice:
+		n, unit = float64(value.Len()), " elements"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = value.Float()
+	default:
+		return ""
+	}
+
+	if unit != "" {
+		if r.name == "min" && n < bound {
+			return fmt.Sprintf("must be at least %s%s", r.param, unit)
+		}
+		if r.name == "max" && n > bound {
+			return fmt.Sprintf("must be at most %s%s", r.param, unit)
+		}
+		return ""
+	}
+	if r.name == "min" && n < bound {
+		return "must be at least " + r.param
+	}
+	if r.name == "max" && n > bound {
+		return "must be at most " + r.param
+	}
+	return ""
+}
+
+// hasRule reports whether rules includes one named name
+func hasRule(rules []rule, name string) bool {
+	for _, r := range rules {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Key checks that key can be stored: valid UTF-8 of at most MaxKeyLength characters,
+// without control characters. Postgres rejects invalid UTF-8 and NUL bytes, and control
+// characters cannot be written safely in logs and event streams.
+func Key(key string) error {
+	if !utf8.ValidString(key) {
+		return errors.New("must be valid UTF-8")
+	}
+	if utf8.RuneCountInString(key) > MaxKeyLength {
+		return fmt.Errorf("must be at most %d characters", MaxKeyLength)
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) {
+			return errors.New("must not contain control characters")
+		}
+	}
+	return nil
+}
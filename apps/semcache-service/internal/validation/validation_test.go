@@ -0,0 +1,134 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// request has each rule the handlers rely on, tagged as in the request types
+type request struct {
+	Key           string    `json:"key" validate:"required,key"`
+	Value         string    `json:"value" validate:"required"`
+	Name          string    `json:"name" validate:"max=255"`
+	Metadata      string    `json:"metadata,omitempty" validate:"maxbytes=2048"`
+	TTL           *int      `json:"ttl,omitempty" validate:"min=0,max=315360000"`
+	Threshold     float64   `json:"threshold,omitempty" validate:"min=-1,max=1"`
+	ValueEncoding string    `json:"value_encoding,omitempty" validate:"omitempty,oneof=base64"`
+	Embedding     []float32 `json:"embedding" validate:"required"`
+}
+
+// valid returns a request that passes validation
+func valid() request {
+	return request{Key: "user:1", Value: "v", Embedding: []float32{1}}
+}
+
+func TestValidate(t *testing.T) {
+	ttl := func(n int) *int { return &n }
+
+	tests := []struct {
+		name   string
+		modify func(r *request)
+		want   Errors
+	}{
+		{"valid", func(r *request) {}, nil},
+		{"required string", func(r *request) { r.Value = "" }, Errors{{"value", "is required"}}},
+		{"required slice", func(r *request) { r.Embedding = []float32{} }, Errors{{"embedding", "is required"}}},
+		{"required nil slice", func(r *request) { r.Embedding = nil }, Errors{{"embedding", "is required"}}},
+		{"key and required", func(r *request) { r.Key = "" }, Errors{{"key", "is required"}}},
+		{"key invalid UTF-8", func(r *request) { r.Key = "user:\xff" }, Errors{{"key", "must be valid UTF-8"}}},
+		{"key control character", func(r *request) { r.Key = "user:\n1" }, Errors{{"key", "must not contain control characters"}}},
+		{"key too long", func(r *request) { r.Key = strings.Repeat("é", 256) }, Errors{{"key", "must be at most 255 characters"}}},
+		{"key at the limit", func(r *request) { r.Key = strings.Repeat("é", 255) }, nil},
+		{"max characters", func(r *request) { r.Name = strings.Repeat("a", 256) }, Errors{{"name", "must be at most 255 characters"}}},
+		{"max counts characters, not bytes", func(r *request) { r.Name = strings.Repeat("é", 255) }, nil},
+		{"maxbytes", func(r *request) { r.Metadata = strings.Repeat("é", 1025) }, Errors{{"metadata", "must be at most 2048 bytes"}}},
+		{"maxbytes at the limit", func(r *request) { r.Metadata = strings.Repeat("é", 1024) }, nil},
+		{"nil pointer skipped", func(r *request) { r.TTL = nil }, nil},
+		{"pointer min", func(r *request) { r.TTL = ttl(-1) }, Errors{{"ttl", "must be at least 0"}}},
+		{"pointer max", func(r *request) { r.TTL = ttl(315360001) }, Errors{{"ttl", "must be at most 315360000"}}},
+		{"pointer zero", func(r *request) { r.TTL = ttl(0) }, nil},
+		{"float min", func(r *request) { r.Threshold = -1.5 }, Errors{{"threshold", "must be at least -1"}}},
+		{"float max", func(r *request) { r.Threshold = 1.01 }, Errors{{"threshold", "must be at most 1"}}},
+		{"float in range", func(r *request) { r.Threshold = -1 }, nil},
+		{"oneof", func(r *request) { r.ValueEncoding = "hex" }, Errors{{"value_encoding", "must be one of: base64"}}},
+		{"oneof match", func(r *request) { r.ValueEncoding = "base64" }, nil},
+		{"several fields in order", func(r *request) { r.Key, r.Threshold = "", 2 }, Errors{{"key", "is required"}, {"threshold", "must be at most 1"}}},
+	}
+	v := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(&r)
+
+			err := v.Validate(&r)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate = %v, want no error", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate = %v, want Errors", err)
+			}
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Fatalf("Validate = %+v, want %+v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	// Path, query and header fields are reported by the name the client sent
+	var r struct {
+		Key         string `param:"key" validate:"required"`
+		Metadata    string `query:"metadata" validate:"required"`
+		ContentType string `header:"Content-Type" validate:"required"`
+		Ignored     string `json:"-" validate:"required"`
+		Name        string `json:"name,omitempty" validate:"required"`
+	}
+	want := Errors{
+		{"key", "is required"},
+		{"metadata", "is required"},
+		{"Content-Type", "is required"},
+		{"Ignored", "is required"},
+		{"name", "is required"},
+	}
+	var errs Errors
+	if err := New().Validate(&r); !errors.As(err, &errs) || !reflect.DeepEqual(errs, want) {
+		t.Fatalf("Validate = %v, want %v", err, want)
+	}
+	if got := errs.Error(); got != "key is required; metadata is required; Content-Type is required; Ignored is required; name is required" {
+		t.Fatalf("Error() = %q", got)
+	}
+}
+
+func TestInvalidTags(t *testing.T) {
+	// A bad tag is a programming error, not a client one, so it is never reported as Errors
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"unknown rule", &struct {
+			A string `validate:"email"`
+		}{}},
+		{"min without a number", &struct {
+			A int `validate:"min=x"`
+		}{}},
+		{"oneof without values", &struct {
+			A string `validate:"oneof="`
+		}{}},
+		{"not a struct", "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Validate(tt.value)
+			var errs Errors
+			if err == nil || errors.As(err, &errs) {
+				t.Fatalf("Validate = %v, want an error other than Errors", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// Package watch records entry events in the change log and streams new changes to
+// watchers. Changes made through other replicas are picked up by polling the log.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+const (
+	// pageSize is how many changes are written to or read from the log at a time
+	pageSize = 1000
+
+	// subscriberBuffer is how many changes a watcher may fall behind before it is
+	// disconnected; it can then resume from the log
+	subscriberBuffer = 256
+
+	// trimInterval is how often changes past retention are removed
+	trimInterval = time.Minute
+)
+
+// ErrClosed is returned when subscribing to a hub that has been closed
+var ErrClosed = errors.New("watch hub closed")
+
+// Subscription receives the changes broadcast after it was created
+type Subscription struct {
+	// C delivers changes in sequence order. It is closed when the watcher falls too far
+	// behind, or when the hub is closed.
+	C <-chan *models.Change
+	c chan *models.Change
+
+	// Head is the latest change broadcast before subscribing
+	Head int64
+}
+
+// Hub appends published entry events to the change log and broadcasts new changes to its
+// subscriptions
+type Hub struct {
+	log models.ChangeLog
+	cfg *config.WatchConfig
+
+	queue chan models.EntryEvent
+	wake  chan struct{}
+
+	// mu guards subs, head, loaded and closed
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	head   int64
+	loaded bool
+	closed bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a hub writing to and polling log. Call Load before subscribing.
+func New(log models.ChangeLog, cfg *config.WatchConfig) *Hub {
+	h := &Hub{
+		log:   log,
+		cfg:   cfg,
+		queue: make(chan models.EntryEvent, cfg.MaxPending),
+		wake:  make(chan struct{}, 1),
+		subs:  make(map[*Subscription]struct{}),
+		stop:  make(chan struct{}),
+	}
+
+	h.wg.Add(2)
+	go h.writeLoop()
+	go h.pollLoop()
+
+	return h
+}
+
+// Load reads the latest sequence in the log, from which broadcasting starts. It is
+// retried on every poll until it succeeds.
+func (h *Hub) Load(ctx context.Context) error {
+	_, latest, err := h.log.ChangeBounds(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.loaded {
+		h.head = latest
+		h.loaded = true
+	}
+	return nil
+}
+
+// Publish queues event to be appended to the log. It never blocks, so stores may call it
+// while holding locks; events are dropped when MaxPending are already queued.
+func (h *Hub) Publish(event models.EntryEvent) {
+	select {
+	case h.queue <- event:
+	default:
+		metrics.RecordDroppedChanges(context.Background(), 1)
+		logger.Logger.Warn(fmt.Sprintf("Dropping %s change for key %q: change log queue full", event.Type, event.Key))
+	}
+}
+
+// Subscribe starts receiving changes. It fails with models.ErrUnavailable until the log
+// has been loaded.
+func (h *Hub) Subscribe() (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if !h.loaded {
+		return nil, models.ErrUnavailable
+	}
+
+	c := make(chan *models.Change, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, Head: h.head}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Unsubscribe stops delivering changes to sub
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// Watchers returns the number of subscriptions
+func (h *Hub) Watchers() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return int64(len(h.subs))
+}
+
+// ChangesSince returns up to limit changes from the log after the given sequence
+func (h *Hub) ChangesSince(ctx context.Context, after int64, limit int) ([]*models.Change, error) {
+	return h.log.ChangesSince(ctx, after, limit)
+}
+
+// ChangeBounds returns the oldest and latest sequence in the log
+func (h *Hub) ChangeBounds(ctx context.Context) (int64, int64, error) {
+	return h.log.ChangeBounds(ctx)
+}
+
+// Close disconnects every subscription, writes the events still queued and stops
+func (h *Hub) Close() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	for sub := range h.subs {
+		h.drop(sub)
+	}
+	close(h.stop)
+	h.mu.Unlock()
+
+	h.wg.Wait()
+}
+
+// drop removes sub and closes its channel; callers hold h.mu
+func (h *Hub) drop(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
+
+// writeLoop appends queued events to the log in batches until Close is called
+func (h *Hub) writeLoop() {
+	defer h.wg.Done()
+
+	for {
+		select {
+		case event := <-h.queue:
+			h.write(h.batch(event))
+		case <-h.stop:
+			for len(h.queue) > 0 {
+				h.write(h.batch(<-h.queue))
+			}
+			return
+		}
+	}
+}
+
+// batch collects first and whatever else is queued, up to pageSize events
+func (h *Hub) batch(first models.EntryEvent) []models.EntryEvent {
+	batch := []models.EntryEvent{first}
+	for len(batch) < pageSize {
+		select {
+		case event := <-h.queue:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// write appends batch to the log and wakes the poller to broadcast it
+func (h *Hub) write(batch []models.EntryEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.log.AppendChanges(ctx, batch); err != nil {
+		metrics.RecordDroppedChanges(ctx, len(batch))
+		logger.Logger.Warn(fmt.Sprintf("Dropping %d changes: %v", len(batch), err))
+		return
+	}
+
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pollLoop broadcasts new changes every PollInterval, or as soon as this replica writes
+// some, and trims the log until Close is called
+func (h *Hub) pollLoop() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+	trim := time.NewTicker(trimInterval)
+	defer trim.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.poll()
+		case <-h.wake:
+			h.poll()
+		case <-trim.C:
+			h.trim()
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// poll reads the changes after the last one broadcast and broadcasts them. The log assigns
+// sequences in commit order, so no change can appear later below the last one broadcast.
+func (h *Hub) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h.mu.Lock()
+	loaded, head := h.loaded, h.head
+	h.mu.Unlock()
+
+	if !loaded {
+		if err := h.Load(ctx); err != nil && !errors.Is(err, models.ErrUnavailable) {
+			logger.Logger.Warn(fmt.Sprintf("Failed to load change log: %v", err))
+		}
+		return
+	}
+
+	for {
+		changes, err := h.log.ChangesSince(ctx, head, pageSize)
+		if err != nil {
+			if !errors.Is(err, models.ErrUnavailable) {
+				logger.Logger.Warn(fmt.Sprintf("Failed to poll change log: %v", err))
+			}
+			return
+		}
+		if len(changes) == 0 {
+			return
+		}
+
+		h.broadcast(changes)
+		head = changes[len(changes)-1].Seq
+		if len(changes) < pageSize {
+			return
+		}
+	}
+}
+
+// broadcast sends changes to every subscription, dropping those that have fallen behind
+func (h *Hub) broadcast(changes []*models.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+	deliver:
+		for _, change := range changes {
+			select {
+			case sub.c <- change:
+			default:
+				h.drop(sub)
+				break deliver
+			}
+		}
+	}
+	h.head = changes[len(changes)-1].Seq
+}
+
+// trim removes changes past retention
+func (h *Hub) trim() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := h.log.TrimChanges(ctx, h.cfg.Retention); err != nil && !errors.Is(err, models.ErrUnavailable) {
+		logger.Logger.Warn(fmt.Sprintf("Failed to trim change log: %v", err))
+	}
+}
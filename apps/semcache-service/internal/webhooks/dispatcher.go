@@ -0,0 +1,402 @@
+// Package webhooks delivers entry events to the endpoints registered for them, signed
+// with each webhook's secret and retried with backoff before being dead-lettered.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/metrics"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event is the JSON body of a delivery
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Key       string      `json:"key"`
+	Namespace string      `json:"namespace"`
+	Time      time.Time   `json:"time"`
+	Entry     *EventEntry `json:"entry,omitempty"`
+}
+
+// EventEntry describes the entry written by a created or updated event. Values are not
+// included; receivers fetch them from the API if they need them.
+type EventEntry struct {
+	ID          int        `json:"id"`
+	ContentType string     `json:"content_type,omitempty"`
+	Metadata    string     `json:"metadata,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// delivery is an event on its way to one webhook
+type delivery struct {
+	webhook *models.Webhook
+	event   *Event
+	body    []byte
+
+	attempts   int
+	lastStatus int
+	lastErr    error
+}
+
+// Dispatcher delivers entry events to the webhooks subscribed to them. Webhooks are
+// cached in memory and reloaded periodically, so Publish never waits on the store.
+type Dispatcher struct {
+	store  models.WebhookStore
+	cfg    *config.WebhookConfig
+	client *http.Client
+
+	// mu guards webhooks, and closed so that no delivery starts once Close is waiting
+	mu       sync.RWMutex
+	webhooks []*models.Webhook
+	closed   bool
+
+	// slots bounds requests in flight; pending counts deliveries not yet finished
+	slots   chan struct{}
+	pending atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a dispatcher and starts reloading webhooks from store every
+// cfg.RefreshInterval. Call Reload to load them straight away.
+func New(store models.WebhookStore, cfg *config.WebhookConfig) *Dispatcher {
+	d := &Dispatcher{
+		store: store,
+		cfg:   cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// A redirect is a misconfigured endpoint; following it could leak events elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		slots: make(chan struct{}, cfg.Concurrency),
+		stop:  make(chan struct{}),
+	}
+
+	if cfg.RefreshInterval > 0 {
+		d.wg.Add(1)
+		go d.refreshLoop(cfg.RefreshInterval)
+	}
+
+	return d
+}
+
+// Reload reads the registered webhooks from the store
+func (d *Dispatcher) Reload(ctx context.Context) error {
+	webhooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.webhooks = webhooks
+	d.mu.Unlock()
+	return nil
+}
+
+// Pending returns the number of deliveries waiting to be sent or retried
+func (d *Dispatcher) Pending() int64 {
+	return d.pending.Load()
+}
+
+// Publish queues event for every webhook subscribed to it. It never blocks, so stores may
+// call it while holding locks.
+func (d *Dispatcher) Publish(event models.EntryEvent) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	var matched []*models.Webhook
+	for _, webhook := range d.webhooks {
+		if webhook.Matches(event.Type, event.Key) {
+			matched = append(matched, webhook)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	payload, body, err := newEvent(event)
+	if err != nil {
+		logger.Logger.Error(fmt.Sprintf("Failed to encode webhook event for key %q: %v", event.Key, err))
+		return
+	}
+
+	for _, webhook := range matched {
+		dv := &delivery{webhook: webhook, event: payload, body: body}
+
+		d.wg.Add(1)
+		if d.pending.Add(1) > int64(d.cfg.MaxPending) {
+			dv.lastErr = errors.New("too many pending deliveries")
+			go func() {
+				defer d.wg.Done()
+				defer d.pending.Add(-1)
+				d.deadLetter(dv)
+			}()
+			continue
+		}
+		go d.deliver(dv)
+	}
+}
+
+// Close stops reloading webhooks and dead-letters deliveries waiting for a retry. It
+// waits for requests in flight to finish.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.stop)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// refreshLoop reloads webhooks until Close is called, picking up those registered
+// through other replicas
+func (d *Dispatcher) refreshLoop(interval time.Duration) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := d.Reload(ctx); err != nil {
+				logger.Logger.Warn(fmt.Sprintf("Failed to reload webhooks: %v", err))
+			}
+			cancel()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// deliver sends dv until it succeeds, fails permanently or runs out of attempts
+func (d *Dispatcher) deliver(dv *delivery) {
+	defer d.wg.Done()
+	defer d.pending.Add(-1)
+
+	backoff := d.cfg.MinBackoff
+	for {
+		retryAfter, err := d.attempt(dv)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, errShutdown) || !retryable(dv.lastStatus) || dv.attempts >= d.cfg.MaxAttempts {
+			d.deadLetter(dv)
+			return
+		}
+
+		wait := time.Duration(float64(backoff) * (0.5 + rand.Float64()))
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if wait > d.cfg.MaxBackoff {
+			wait = d.cfg.MaxBackoff
+		}
+		backoff = min(2*backoff, d.cfg.MaxBackoff)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-d.stop:
+			timer.Stop()
+			dv.shutDown()
+			d.deadLetter(dv)
+			return
+		}
+	}
+}
+
+// errShutdown fails deliveries still pending when the dispatcher is closed
+var errShutdown = errors.New("dispatcher shut down")
+
+// shutDown records that dv was abandoned on shutdown
+func (dv *delivery) shutDown() {
+	if dv.lastErr == nil {
+		dv.lastErr = errShutdown
+	} else {
+		dv.lastErr = fmt.Errorf("%w before retry: %w", errShutdown, dv.lastErr)
+	}
+}
+
+// attempt sends dv once, recording the outcome on it. On failure, it returns how long
+// the endpoint asked to wait before retrying, if it did.
+func (d *Dispatcher) attempt(dv *delivery) (time.Duration, error) {
+	select {
+	case d.slots <- struct{}{}:
+	case <-d.stop:
+		dv.shutDown()
+		return 0, errShutdown
+	}
+	defer func() { <-d.slots }()
+
+	dv.attempts++
+
+	ctx, span := otel.Tracer("semcache-service").Start(context.Background(), "webhook "+dv.event.Type,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("webhook.id", dv.webhook.ID),
+			attribute.String("webhook.event_id", dv.event.ID),
+			attribute.Int("webhook.attempt", dv.attempts),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	status, retryAfter, err := d.send(ctx, dv)
+	dv.lastStatus, dv.lastErr = status, err
+
+	outcome := "delivered"
+	switch {
+	case err == nil:
+	case retryable(status) && dv.attempts < d.cfg.MaxAttempts:
+		outcome = "retry"
+	default:
+		outcome = "failed"
+	}
+	metrics.RecordWebhookDelivery(ctx, dv.event.Type, outcome, time.Since(start))
+
+	if status != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return retryAfter, err
+	}
+	return 0, nil
+}
+
+// send posts the signed event and returns the response status, or 0 if none was received
+func (d *Dispatcher) send(ctx context.Context, dv *delivery) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dv.webhook.URL, bytes.NewReader(dv.body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "semcache-webhooks")
+	req.Header.Set(EventHeader, dv.event.Type)
+	req.Header.Set(DeliveryHeader, dv.event.ID)
+	req.Header.Set(SignatureHeader, Sign(dv.webhook.Secret, time.Now(), dv.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, 0, nil
+	}
+	return resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// deadLetter records a delivery that was given up on
+func (d *Dispatcher) deadLetter(dv *delivery) {
+	metrics.RecordWebhookDeadLetter(context.Background(), dv.event.Type)
+
+	lastError := ""
+	if dv.lastErr != nil {
+		lastError = dv.lastErr.Error()
+	}
+	logger.Logger.Warn(fmt.Sprintf("Dead-lettering %s event %s for webhook %d after %d attempts: %s",
+		dv.event.Type, dv.event.ID, dv.webhook.ID, dv.attempts, lastError))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := d.store.AddDeadLetter(ctx, &models.DeadLetter{
+		WebhookID:  dv.webhook.ID,
+		EventID:    dv.event.ID,
+		EventType:  dv.event.Type,
+		Key:        dv.event.Key,
+		Payload:    string(dv.body),
+		Attempts:   dv.attempts,
+		LastStatus: dv.lastStatus,
+		LastError:  lastError,
+	})
+	if err != nil {
+		logger.Logger.Error(fmt.Sprintf("Failed to record dead letter for event %s: %v", dv.event.ID, err))
+	}
+}
+
+// newEvent builds the delivery body of an entry event
+func newEvent(event models.EntryEvent) (*Event, []byte, error) {
+	id, err := newEventID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := &Event{
+		ID:        id,
+		Type:      event.Type,
+		Key:       event.Key,
+		Namespace: models.Namespace(event.Key),
+		Time:      event.Time,
+	}
+	if entry := event.Entry; entry != nil {
+		payload.Entry = &EventEntry{
+			ID:          entry.ID,
+			ContentType: entry.ContentType,
+			Metadata:    entry.Metadata,
+			CreatedAt:   entry.CreatedAt,
+			ExpiresAt:   entry.ExpiresAt,
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payload, body, nil
+}
+
+// retryable reports whether a failed attempt with status (0 when no response was
+// received) may succeed later
+func retryable(status int) bool {
+	switch {
+	case status == 0:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= 500
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
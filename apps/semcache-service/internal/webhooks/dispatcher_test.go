@@ -0,0 +1,236 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nextinterfaces/semcache-service/internal/config"
+	"github.com/nextinterfaces/semcache-service/internal/logger"
+	"github.com/nextinterfaces/semcache-service/internal/models"
+)
+
+// fakeStore serves a fixed list of webhooks and records dead letters
+type fakeStore struct {
+	webhooks []*models.Webhook
+
+	mu          sync.Mutex
+	deadLetters []*models.DeadLetter
+}
+
+func (s *fakeStore) CreateWebhook(context.Context, models.CreateWebhookRequest) (*models.Webhook, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) ListWebhooks(context.Context) ([]*models.Webhook, error) {
+	return s.webhooks, nil
+}
+
+func (s *fakeStore) DeleteWebhook(context.Context, int) error {
+	return nil
+}
+
+func (s *fakeStore) AddDeadLetter(_ context.Context, dl *models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters = append(s.deadLetters, dl)
+	return nil
+}
+
+func (s *fakeStore) ListDeadLetters(context.Context, int, int) ([]*models.DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*models.DeadLetter(nil), s.deadLetters...), nil
+}
+
+// newTestDispatcher starts a dispatcher delivering to a webhook at url, retrying quickly
+func newTestDispatcher(t *testing.T, url string) (*Dispatcher, *fakeStore) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+
+	store := &fakeStore{webhooks: []*models.Webhook{
+		{ID: 1, Namespace: models.AllNamespaces, URL: url, Secret: "whsec_test"},
+	}}
+	d := New(store, &config.WebhookConfig{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+		Timeout:     time.Second,
+		MaxPending:  10,
+		Concurrency: 2,
+	})
+	t.Cleanup(d.Close)
+	if err := d.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	return d, store
+}
+
+// waitIdle waits until d has no pending deliveries
+func waitIdle(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d deliveries still pending", d.Pending())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.Header, body}
+	}))
+	defer srv.Close()
+
+	d, store := newTestDispatcher(t, srv.URL)
+	d.Publish(models.EntryEvent{Type: models.EventDeleted, Key: "user:1", Time: time.Now().UTC()})
+
+	var req received
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+	}
+	waitIdle(t, d)
+
+	var event Event
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("invalid body %q: %v", req.body, err)
+	}
+	if event.Type != models.EventDeleted || event.Key != "user:1" || event.Namespace != "user" {
+		t.Errorf("event = %+v, want a deleted event for user:1 in namespace user", event)
+	}
+	if got := req.header.Get(EventHeader); got != models.EventDeleted {
+		t.Errorf("%s = %q, want %q", EventHeader, got, models.EventDeleted)
+	}
+	if got := req.header.Get(DeliveryHeader); got != event.ID {
+		t.Errorf("%s = %q, want the event id %q", DeliveryHeader, got, event.ID)
+	}
+
+	// The receiver recomputes the signature from the timestamp it was given
+	signature := req.header.Get(SignatureHeader)
+	var timestamp int64
+	if _, err := fmt.Sscanf(signature, "t=%d,", &timestamp); err != nil {
+		t.Fatalf("malformed %s %q: %v", SignatureHeader, signature, err)
+	}
+	if want := Sign("whsec_test", time.Unix(timestamp, 0), req.body); signature != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+	}
+
+	if dls, _ := store.ListDeadLetters(context.Background(), 1, 10); len(dls) != 0 {
+		t.Errorf("dead letters = %d, want none", len(dls))
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // statuses returned in turn, the last one repeating
+		attempts int
+		dead     bool
+	}{
+		{"success", []int{http.StatusNoContent}, 1, false},
+		{"retried until success", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, 3, false},
+		{"attempts exhausted", []int{http.StatusInternalServerError}, 3, true},
+		{"not retryable", []int{http.StatusBadRequest}, 1, true},
+		{"redirect", []int{http.StatusFound}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A redirect must never be followed, as it could leak events elsewhere
+			var followed atomic.Int32
+			target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				followed.Add(1)
+			}))
+			defer target.Close()
+
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(hits.Add(1))
+				status := tt.statuses[min(n, len(tt.statuses))-1]
+				if status == http.StatusFound {
+					w.Header().Set("Location", target.URL)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			d, store := newTestDispatcher(t, srv.URL)
+			d.Publish(models.EntryEvent{Type: models.EventCreated, Key: "user:1", Time: time.Now().UTC()})
+			waitIdle(t, d)
+
+			if got := int(hits.Load()); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+			if followed.Load() != 0 {
+				t.Error("redirect was followed")
+			}
+
+			dls, _ := store.ListDeadLetters(context.Background(), 1, 10)
+			if !tt.dead {
+				if len(dls) != 0 {
+					t.Fatalf("dead letters = %+v, want none", dls)
+				}
+				return
+			}
+			if len(dls) != 1 {
+				t.Fatalf("dead letters = %d, want 1", len(dls))
+			}
+			dl := dls[0]
+			want := tt.statuses[len(tt.statuses)-1]
+			if dl.WebhookID != 1 || dl.Attempts != tt.attempts || dl.LastStatus != want || dl.Key != "user:1" {
+				t.Errorf("dead letter = %+v, want webhook 1, %d attempts, last status %d", dl, tt.attempts, want)
+			}
+		})
+	}
+}
+
+func TestDeliverUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	d, store := newTestDispatcher(t, url)
+	d.Publish(models.EntryEvent{Type: models.EventCreated, Key: "user:1", Time: time.Now().UTC()})
+	waitIdle(t, d)
+
+	dls, _ := store.ListDeadLetters(context.Background(), 1, 10)
+	if len(dls) != 1 || dls[0].Attempts != 3 || dls[0].LastStatus != 0 || dls[0].LastError == "" {
+		t.Fatalf("dead letters = %+v, want one after 3 attempts without a response", dls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Receivers compute HMAC-SHA256("<t>.<body>") with the secret; this value was computed
+	// independently, so changing the format breaks the test as it would break receivers
+	got := Sign("whsec_test", time.Unix(1700000000, 0), []byte(`{"id":"evt_1"}`))
+	want := "t=1700000000,v1=c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925"
+	if got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+
+	if other := Sign("other", time.Unix(1700000000, 0), []byte(`{"id":"evt_1"}`)); other == want {
+		t.Fatal("signatures with different secrets are equal")
+	}
+	if later := Sign("whsec_test", time.Unix(1700000001, 0), []byte(`{"id":"evt_1"}`)); later == want {
+		t.Fatal("signatures at different times are equal")
+	}
+}
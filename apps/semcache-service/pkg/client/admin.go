@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Stats reports what the cache holds
+type Stats struct {
+	Entries        int64    `json:"entries"`     // live entries
+	Expired        int64    `json:"expired"`     // expired entries not yet removed
+	ValueBytes     int64    `json:"value_bytes"` // total size of live values
+	L1             *L1Stats `json:"l1,omitempty"`
+	CircuitBreaker string   `json:"circuit_breaker,omitempty"`
+}
+
+// L1Stats reports the contents of the L1 tier of the replica that answered
+type L1Stats struct {
+	Entries int64 `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Stats returns entry counts and sizes
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	err := c.doJSON(ctx, request{op: "Stats", method: http.MethodGet, path: "/v1/admin/stats", idempotent: true}, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Export calls fn with every live entry whose key starts with prefix, as the service
+// streams them. It stops at the first error fn returns.
+func (c *Client) Export(ctx context.Context, prefix string, fn func(*Entry) error) error {
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+
+	resp, err := c.do(ctx, request{op: "Export", method: http.MethodGet, path: "/v1/admin/export", query: query, idempotent: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxExportLine)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("failed to decode exported entry: %w", err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	return nil
+}
+
+// maxExportLine bounds one exported entry, which holds a base64-encoded value
+const maxExportLine = 1 << 30
+
+// Invalidate evicts key from the L1 tier of every replica; the entry stays in the store
+func (c *Client) Invalidate(ctx context.Context, key string) error {
+	return c.invalidate(ctx, map[string]interface{}{"key": key})
+}
+
+// InvalidateAll empties the L1 tier of every replica
+func (c *Client) InvalidateAll(ctx context.Context) error {
+	return c.invalidate(ctx, map[string]interface{}{"all": true})
+}
+
+func (c *Client) invalidate(ctx context.Context, req map[string]interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	return c.doJSON(ctx, request{op: "Invalidate", method: http.MethodPost, path: "/v1/admin/invalidate", body: body, idempotent: true}, nil)
+}
@@ -0,0 +1,396 @@
+// Package client is a Go client for the semcache-service HTTP API.
+//
+// Requests carry the caller's trace context using the global OpenTelemetry propagator,
+// are retried with exponential backoff when the service is unavailable, and fail with an
+// *APIError that matches ErrNotFound, ErrKeyExists and the other sentinel errors.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/nextinterfaces/semcache-service/pkg/client"
+
+// Client calls the semcache-service HTTP API. It is safe for concurrent use once
+// configured.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// maxRetries is how many times a failed request is retried, waiting minBackoff after
+	// the first failure and doubling up to maxBackoff
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	batchConcurrency int
+
+	// apiKey is sent as a bearer token, if set
+	apiKey string
+}
+
+// New creates a client for the service at baseURL, such as "http://semcache-service"
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	return &Client{
+		baseURL:          strings.TrimRight(baseURL, "/"),
+		httpClient:       &http.Client{},
+		maxRetries:       3,
+		minBackoff:       100 * time.Millisecond,
+		maxBackoff:       2 * time.Second,
+		batchConcurrency: 8,
+	}, nil
+}
+
+// UseHTTPClient sends requests with httpClient instead of a default client without timeouts
+func (c *Client) UseHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// UseRetries retries failed requests up to maxRetries times, waiting minBackoff after the
+// first failure and doubling up to maxBackoff. Zero maxRetries disables retries.
+func (c *Client) UseRetries(maxRetries int, minBackoff, maxBackoff time.Duration) {
+	c.maxRetries = maxRetries
+	c.minBackoff = minBackoff
+	c.maxBackoff = maxBackoff
+}
+
+// UseAPIKey authenticates requests with an API key or JWT, needed when the service has
+// AUTH_ENABLED set
+func (c *Client) UseAPIKey(key string) {
+	c.apiKey = key
+}
+
+// UseBatchConcurrency sets how many creates Batch runs at once
+func (c *Client) UseBatchConcurrency(n int) {
+	c.batchConcurrency = n
+}
+
+// Create creates a cache entry
+func (c *Client) Create(ctx context.Context, req CreateRequest) (*Entry, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var entry wireEntry
+	err = c.doJSON(ctx, request{op: "Create", method: http.MethodPost, path: "/v1/create", body: body}, &entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry.entry()
+}
+
+// Get returns the entry stored under key
+func (c *Client) Get(ctx context.Context, key string) (*Entry, error) {
+	var entry wireEntry
+	err := c.doJSON(ctx, request{op: "Get", method: http.MethodGet, path: entryPath(key), idempotent: true}, &entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry.entry()
+}
+
+// Delete removes the entry stored under key
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.doJSON(ctx, request{op: "Delete", method: http.MethodDelete, path: entryPath(key), idempotent: true}, nil)
+}
+
+// Search returns entries whose key and metadata contain the requested substrings
+func (c *Client) Search(ctx context.Context, req SearchRequest) ([]*Entry, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var wire []wireEntry
+	err = c.doJSON(ctx, request{op: "Search", method: http.MethodPost, path: "/v1/search", body: body, idempotent: true}, &wire)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, len(wire))
+	for i := range wire {
+		if entries[i], err = wire[i].entry(); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Lookup returns the entries whose embeddings are most similar to req.Embedding
+func (c *Client) Lookup(ctx context.Context, req LookupRequest) ([]*LookupResult, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	var wire []wireEntry
+	err = c.doJSON(ctx, request{op: "Lookup", method: http.MethodPost, path: "/v1/lookup", body: body, idempotent: true}, &wire)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*LookupResult, len(wire))
+	for i := range wire {
+		entry, err := wire[i].entry()
+		if err != nil {
+			return nil, err
+		}
+		results[i] = &LookupResult{Entry: entry, Score: wire[i].Score}
+	}
+	return results, nil
+}
+
+// BatchResult is the outcome of one create in a batch
+type BatchResult struct {
+	Entry *Entry
+	Err   error
+}
+
+// Batch creates many entries, running up to the batch concurrency of creates at once.
+// Results are in the order of reqs; a failed create does not stop the others.
+func (c *Client) Batch(ctx context.Context, reqs []CreateRequest) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+
+	concurrency := c.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Entry, results[i].Err = c.Create(ctx, reqs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Health reports the service health
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	var health HealthResponse
+	err := c.doJSON(ctx, request{op: "Health", method: http.MethodGet, path: "/v1/health", idempotent: true}, &health)
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Ready reports whether the service is ready to serve requests. A service that is up
+// but not ready fails with ErrUnavailable.
+func (c *Client) Ready(ctx context.Context) (*HealthResponse, error) {
+	var health HealthResponse
+	err := c.doJSON(ctx, request{op: "Ready", method: http.MethodGet, path: "/v1/ready"}, &health)
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// request describes one API call
+type request struct {
+	op     string // operation name, used for the span
+	method string
+	path   string // escaped path, relative to the base URL
+	query  url.Values
+	header http.Header
+
+	// body is sent as JSON; stream is sent as-is and cannot be retried
+	body   []byte
+	stream io.Reader
+
+	// idempotent requests are also retried after transport errors and gateway timeouts,
+	// as repeating them is harmless even if the first attempt reached the service
+	idempotent bool
+}
+
+// doJSON sends req and decodes a successful response into out, if it is not nil
+func (c *Client) doJSON(ctx context.Context, req request, out interface{}) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", req.op, err)
+	}
+	return nil
+}
+
+// do sends req, retrying as configured, and returns the successful response. Responses
+// with an error status are returned as *APIError.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "semcache."+req.op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.method),
+			attribute.String("url.path", req.path),
+		),
+	)
+	defer span.End()
+
+	maxRetries := c.maxRetries
+	if req.stream != nil {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req)
+		if err == nil && resp.StatusCode < 300 {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			return resp, nil
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+
+			apiErr := newAPIError(resp.StatusCode, body)
+			// A daily quota does not reset within any reasonable backoff
+			if !retryableStatus(resp.StatusCode, req.idempotent) || apiErr.Code == CodeQuotaExceeded {
+				span.SetStatus(codes.Error, apiErr.Error())
+				return nil, apiErr
+			}
+			err = apiErr
+		} else if !req.idempotent || ctx.Err() != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("semcache %s request failed: %w", req.op, err)
+		}
+
+		if attempt >= maxRetries {
+			span.SetStatus(codes.Error, err.Error())
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				return nil, apiErr
+			}
+			return nil, fmt.Errorf("semcache %s request failed: %w", req.op, err)
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = min(retryAfter, c.maxBackoff)
+		}
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1)))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			span.SetStatus(codes.Error, ctx.Err().Error())
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt at req, injecting the trace context into its headers
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	body := req.stream
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	return c.httpClient.Do(httpReq)
+}
+
+// backoff returns how long to wait before retry attempt+1, with full jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.minBackoff << attempt
+	if wait > c.maxBackoff || wait <= 0 {
+		wait = c.maxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(wait))) + 1
+}
+
+// retryableStatus reports whether a response status is worth retrying. Requests that are
+// not idempotent are only retried when the service certainly did not act on them.
+func retryableStatus(status int, idempotent bool) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// entryPath returns the path of the entry stored under key
+func entryPath(key string) string {
+	return "/v1/entries/" + url.PathEscape(key)
+}
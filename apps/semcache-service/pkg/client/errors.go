@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors matched by errors.Is against the *APIError returned for failed requests
+var (
+	ErrNotFound      = errors.New("semcache: cache entry not found")
+	ErrKeyExists     = errors.New("semcache: cache entry already exists")
+	ErrInvalid       = errors.New("semcache: invalid request")
+	ErrUnauthorized  = errors.New("semcache: missing or invalid API key")
+	ErrForbidden     = errors.New("semcache: forbidden by API key")
+	ErrValueTooLarge = errors.New("semcache: value too large")
+	ErrRateLimited   = errors.New("semcache: rate limited")
+	ErrQuotaExceeded = errors.New("semcache: daily quota exceeded")
+	ErrUnavailable   = errors.New("semcache: service unavailable")
+)
+
+// Problem codes reported by the service in APIError.Code. They are stable, unlike messages.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeKeyConflict      = "key_conflict"
+	CodeValueTooLarge    = "value_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeDBUnavailable    = "db_unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// APIError is an error response from the service
+type APIError struct {
+	StatusCode int
+	Code       string // the problem code, if the service reported one
+	Message    string // the problem detail or error message from the response body, if any
+
+	// Errors lists the request fields that failed validation, for invalid_request problems
+	Errors []FieldError
+}
+
+// FieldError is a request field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("semcache: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("semcache: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the sentinel error for the problem code, or failing that the response
+// status, if there is one
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case CodeNotFound:
+		return ErrNotFound
+	case CodeKeyConflict:
+		return ErrKeyExists
+	case CodeInvalidRequest:
+		return ErrInvalid
+	case CodeUnauthorized:
+		return ErrUnauthorized
+	case CodeForbidden:
+		return ErrForbidden
+	case CodeValueTooLarge:
+		return ErrValueTooLarge
+	case CodeRateLimited:
+		return ErrRateLimited
+	case CodeQuotaExceeded:
+		return ErrQuotaExceeded
+	case CodeDBUnavailable:
+		return ErrUnavailable
+	}
+
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrKeyExists
+	case http.StatusBadRequest:
+		return ErrInvalid
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusRequestEntityTooLarge:
+		return ErrValueTooLarge
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a response status and its body, either an RFC 7807
+// problem or, from older servers, {"error": "..."}
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	var payload struct {
+		Code   string       `json:"code"`
+		Title  string       `json:"title"`
+		Detail string       `json:"detail"`
+		Errors []FieldError `json:"errors"`
+		Error  string       `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Code = payload.Code
+		apiErr.Errors = payload.Errors
+		switch {
+		case payload.Detail != "":
+			apiErr.Message = payload.Detail
+		case payload.Title != "":
+			apiErr.Message = payload.Title
+		default:
+			apiErr.Message = payload.Error
+		}
+	}
+	return apiErr
+}
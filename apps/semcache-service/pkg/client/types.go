@@ -0,0 +1,181 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Entry is a cache entry. Value holds the raw bytes; base64 encoding on the wire is
+// handled by the client.
+type Entry struct {
+	ID          int
+	Key         string
+	Value       []byte
+	ContentType string
+	Metadata    string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	Embedding   []float32
+}
+
+// CreateRequest is the request to create a cache entry
+type CreateRequest struct {
+	Key         string
+	Value       []byte
+	ContentType string
+	Metadata    string
+	TTL         time.Duration // rounded down to whole seconds; zero means no expiry
+	Embedding   []float32
+}
+
+// SearchRequest searches entries whose key and metadata contain the given substrings
+type SearchRequest struct {
+	Key      string `json:"key,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// LookupRequest is a semantic lookup by embedding similarity
+type LookupRequest struct {
+	Embedding []float32 `json:"embedding"`
+	Threshold float64   `json:"threshold,omitempty"` // minimum cosine similarity
+	Limit     int       `json:"limit,omitempty"`
+}
+
+// LookupResult is a cache entry together with its similarity score
+type LookupResult struct {
+	*Entry
+	Score float64
+}
+
+// HealthResponse is the service health as reported by Health and Ready
+type HealthResponse struct {
+	Status         string `json:"status"`
+	Timestamp      string `json:"timestamp"`
+	CommitSHA      string `json:"commit_sha"`
+	Database       string `json:"database"`
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+}
+
+// valueEncodingBase64 marks a JSON value as base64-encoded bytes
+const valueEncodingBase64 = "base64"
+
+// wireEntry is an entry as it is sent in JSON
+type wireEntry struct {
+	ID            int        `json:"id"`
+	Key           string     `json:"key"`
+	Value         string     `json:"value"`
+	ValueEncoding string     `json:"value_encoding,omitempty"`
+	ContentType   string     `json:"content_type,omitempty"`
+	Metadata      string     `json:"metadata,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Embedding     []float32  `json:"embedding,omitempty"`
+	Score         float64    `json:"score,omitempty"`
+}
+
+// entry decodes a wire entry
+func (w *wireEntry) entry() (*Entry, error) {
+	value := []byte(w.Value)
+	switch w.ValueEncoding {
+	case "":
+	case valueEncodingBase64:
+		var err error
+		if value, err = base64.StdEncoding.DecodeString(w.Value); err != nil {
+			return nil, fmt.Errorf("invalid base64 value for key %q: %w", w.Key, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown value_encoding %q for key %q", w.ValueEncoding, w.Key)
+	}
+
+	return &Entry{
+		ID:          w.ID,
+		Key:         w.Key,
+		Value:       value,
+		ContentType: w.ContentType,
+		Metadata:    w.Metadata,
+		CreatedAt:   w.CreatedAt,
+		ExpiresAt:   w.ExpiresAt,
+		Embedding:   w.Embedding,
+	}, nil
+}
+
+// MarshalJSON encodes the entry as the service sends it, base64-encoding values that are
+// not valid UTF-8
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newWireEntry(e))
+}
+
+// UnmarshalJSON decodes an entry as the service sends it
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var wire wireEntry
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	entry, err := wire.entry()
+	if err != nil {
+		return err
+	}
+	*e = *entry
+	return nil
+}
+
+// MarshalJSON encodes the result as the lookup endpoint returns it: the entry with its score
+func (r *LookupResult) MarshalJSON() ([]byte, error) {
+	wire := newWireEntry(r.Entry)
+	wire.Score = r.Score
+	return json.Marshal(wire)
+}
+
+// newWireEntry encodes an entry for JSON
+func newWireEntry(e *Entry) *wireEntry {
+	wire := &wireEntry{
+		ID:          e.ID,
+		Key:         e.Key,
+		Value:       string(e.Value),
+		ContentType: e.ContentType,
+		Metadata:    e.Metadata,
+		CreatedAt:   e.CreatedAt,
+		ExpiresAt:   e.ExpiresAt,
+		Embedding:   e.Embedding,
+	}
+	if !utf8.Valid(e.Value) {
+		wire.Value = base64.StdEncoding.EncodeToString(e.Value)
+		wire.ValueEncoding = valueEncodingBase64
+	}
+	return wire
+}
+
+// wireCreateRequest is a create request as it is sent in JSON
+type wireCreateRequest struct {
+	Key           string    `json:"key"`
+	Value         string    `json:"value"`
+	ValueEncoding string    `json:"value_encoding,omitempty"`
+	ContentType   string    `json:"content_type,omitempty"`
+	Metadata      string    `json:"metadata,omitempty"`
+	TTL           *int      `json:"ttl,omitempty"`
+	Embedding     []float32 `json:"embedding,omitempty"`
+}
+
+// MarshalJSON encodes the request as the create endpoint expects it, base64-encoding
+// values that are not valid UTF-8
+func (r CreateRequest) MarshalJSON() ([]byte, error) {
+	wire := wireCreateRequest{
+		Key:         r.Key,
+		Value:       string(r.Value),
+		ContentType: r.ContentType,
+		Metadata:    r.Metadata,
+		Embedding:   r.Embedding,
+	}
+	if !utf8.Valid(r.Value) {
+		wire.Value = base64.StdEncoding.EncodeToString(r.Value)
+		wire.ValueEncoding = valueEncodingBase64
+	}
+	if seconds := int(r.TTL / time.Second); seconds > 0 {
+		wire.TTL = &seconds
+	}
+	return json.Marshal(wire)
+}
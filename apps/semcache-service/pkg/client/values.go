@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PutValueOptions are the optional settings of PutValue
+type PutValueOptions struct {
+	ContentType string // defaults to application/octet-stream
+	Metadata    string
+	TTL         time.Duration // rounded down to whole seconds; zero means no expiry
+}
+
+// Value is a raw value being read from the service. The caller must close it.
+type Value struct {
+	io.ReadCloser
+
+	ContentType string
+	Size        int64
+	ExpiresAt   *time.Time
+}
+
+// PutValue creates an entry from the raw bytes read from value, which are streamed to the
+// service rather than buffered, so large values can be stored. It is never retried.
+func (c *Client) PutValue(ctx context.Context, key string, value io.Reader, opts *PutValueOptions) error {
+	if opts == nil {
+		opts = &PutValueOptions{}
+	}
+
+	query := url.Values{}
+	if opts.Metadata != "" {
+		query.Set("metadata", opts.Metadata)
+	}
+	if seconds := int(opts.TTL / time.Second); seconds > 0 {
+		query.Set("ttl", strconv.Itoa(seconds))
+	}
+
+	header := http.Header{}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+
+	resp, err := c.do(ctx, request{
+		op:     "PutValue",
+		method: http.MethodPut,
+		path:   entryPath(key) + "/value",
+		query:  query,
+		header: header,
+		stream: value,
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetValue opens the raw value stored under key
+func (c *Client) GetValue(ctx context.Context, key string) (*Value, error) {
+	resp, err := c.do(ctx, request{op: "GetValue", method: http.MethodGet, path: entryPath(key) + "/value", idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+
+	value := &Value{
+		ReadCloser:  resp.Body,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}
+	if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		value.ExpiresAt = &expires
+	}
+	return value, nil
+}